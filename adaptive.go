@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type transferOutcome int
+
+const (
+	outcomeOK        transferOutcome = iota // Completed; counts towards throughput
+	outcomeThrottled                        // Timeout, 429 or 5xx; backs off
+	outcomeNeutral                          // Cancelled or failed for reasons the host isn't to blame for
+)
+
+// hostLimiter is the adaptive concurrency state of a single host
+type hostLimiter struct {
+	limit       int
+	inFlight    int
+	wake        chan struct{} // Closed and replaced whenever a slot frees up or the limit changes
+	windowStart time.Time
+	windowBytes int64
+	windowDone  int
+	lastRate    float64 // Throughput of the previous window in bytes/s
+}
+
+// AdaptiveConcurrency tunes per-host parallelism: it grows while throughput improves
+// and halves on timeouts, 429s and 5xx responses (AIMD).
+type AdaptiveConcurrency struct {
+	mutex sync.Mutex
+	hosts map[string]*hostLimiter
+	max   int
+}
+
+func NewAdaptiveConcurrency(maxLimit int) *AdaptiveConcurrency {
+	if maxLimit < 1 {
+		maxLimit = 1
+	}
+	return &AdaptiveConcurrency{hosts: make(map[string]*hostLimiter), max: maxLimit}
+}
+
+// host returns the limiter for a host, creating it with a conservative starting limit. Caller holds the mutex.
+func (a *AdaptiveConcurrency) host(host string) *hostLimiter {
+	h, ok := a.hosts[host]
+	if !ok {
+		h = &hostLimiter{limit: min(2, a.max), wake: make(chan struct{}), windowStart: time.Now()}
+		a.hosts[host] = h
+	}
+	return h
+}
+
+// Acquire blocks until host has a free slot or ctx is cancelled
+func (a *AdaptiveConcurrency) Acquire(ctx context.Context, host string) error {
+	for {
+		a.mutex.Lock()
+		h := a.host(host)
+		if h.inFlight < h.limit {
+			h.inFlight++
+			a.mutex.Unlock()
+			return nil
+		}
+		wake := h.wake
+		a.mutex.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release frees a slot and adjusts the host's limit based on how the transfer went
+func (a *AdaptiveConcurrency) Release(host string, outcome transferOutcome, bytes int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	h := a.host(host)
+	h.inFlight--
+	oldLimit := h.limit
+
+	switch outcome {
+	case outcomeThrottled:
+		h.limit = max(1, h.limit/2)
+		h.windowStart, h.windowBytes, h.windowDone, h.lastRate = time.Now(), 0, 0, 0
+	case outcomeOK:
+		h.windowBytes += bytes
+		h.windowDone++
+		// Judge a limit only after a full window of transfers ran at it
+		if h.windowDone >= h.limit {
+			rate := float64(h.windowBytes) / time.Since(h.windowStart).Seconds()
+			if rate > h.lastRate*1.05 && h.limit < a.max {
+				h.limit++
+			} else if rate < h.lastRate*0.8 && h.limit > 1 {
+				h.limit--
+			}
+			h.lastRate = rate
+			h.windowStart, h.windowBytes, h.windowDone = time.Now(), 0, 0
+		}
+	}
+
+	if h.limit != oldLimit {
+		stdoutMutex.Lock()
+		fmt.Printf("\nAdaptive concurrency for %s: %d -> %d\n", host, oldLimit, h.limit)
+		stdoutMutex.Unlock()
+	}
+
+	close(h.wake)
+	h.wake = make(chan struct{})
+}
+
+// isTimeout reports whether err is a network timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// adaptiveTransport gates every request through AdaptiveConcurrency.
+// The slot is held until the response body is closed so it covers the whole transfer.
+type adaptiveTransport struct {
+	base    http.RoundTripper
+	limiter *AdaptiveConcurrency
+}
+
+func NewAdaptiveTransport(base http.RoundTripper, maxConcurrent int) http.RoundTripper {
+	return &adaptiveTransport{base: base, limiter: NewAdaptiveConcurrency(maxConcurrent)}
+}
+
+func (t *adaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.limiter.Acquire(req.Context(), host); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		outcome := outcomeNeutral
+		if isTimeout(err) {
+			outcome = outcomeThrottled
+		}
+		t.limiter.Release(host, outcome, 0)
+		return nil, err
+	}
+
+	body := &adaptiveBody{ReadCloser: resp.Body, limiter: t.limiter, host: host, outcome: outcomeOK}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		body.outcome = outcomeThrottled
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// adaptiveBody counts bytes and releases the host slot on Close
+type adaptiveBody struct {
+	io.ReadCloser
+	limiter *AdaptiveConcurrency
+	host    string
+	outcome transferOutcome
+	bytes   int64
+	once    sync.Once
+}
+
+func (b *adaptiveBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	if err != nil && err != io.EOF && b.outcome == outcomeOK {
+		if isTimeout(err) {
+			b.outcome = outcomeThrottled
+		} else {
+			b.outcome = outcomeNeutral
+		}
+	}
+	return n, err
+}
+
+func (b *adaptiveBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.limiter.Release(b.host, b.outcome, b.bytes) })
+	return err
+}
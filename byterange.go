@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// byteRange is the slice of a remote file selected with --start-pos, --end-pos or --range
+type byteRange struct {
+	start int64
+	end   int64 // Inclusive; -1 means up to the end of the file
+}
+
+// parseByteRange builds the slice from --range ("START-END" or "START-") or from
+// --start-pos and --end-pos. Positions take size suffixes (e.g. 10m). It returns nil when none is set.
+func parseByteRange(rangeStr, startStr, endStr string) (*byteRange, error) {
+	if rangeStr != "" {
+		if startStr != "" || endStr != "" {
+			return nil, fmt.Errorf("--range can't be combined with --start-pos or --end-pos")
+		}
+		var ok bool
+		if startStr, endStr, ok = strings.Cut(rangeStr, "-"); !ok || startStr == "" {
+			return nil, fmt.Errorf("invalid --range: %s (want START-END or START-)", rangeStr)
+		}
+	}
+	if startStr == "" && endStr == "" {
+		return nil, nil
+	}
+
+	r := &byteRange{end: -1}
+	var err error
+	if r.start, err = parseByteSize(startStr); err != nil {
+		return nil, fmt.Errorf("invalid start position: %w", err)
+	}
+	if endStr != "" {
+		if r.end, err = parseByteSize(endStr); err != nil {
+			return nil, fmt.Errorf("invalid end position: %w", err)
+		}
+		if r.end < r.start {
+			return nil, fmt.Errorf("end position %d is before start position %d", r.end, r.start)
+		}
+	}
+	return r, nil
+}
+
+// header returns the Range header value requesting the slice
+func (r *byteRange) header() string {
+	if r.end < 0 {
+		return fmt.Sprintf("bytes=%d-", r.start)
+	}
+	return fmt.Sprintf("bytes=%d-%d", r.start, r.end)
+}
+
+// length returns the size of the slice, or -1 when it runs to the end of the file
+func (r *byteRange) length() int64 {
+	if r.end < 0 {
+		return -1
+	}
+	return r.end - r.start + 1
+}
+
+// DownloadRange saves only the bytes of r from urlStr. Servers that ignore the Range header
+// are read from the start with the bytes before the slice thrown away.
+func (w *WgetClone) DownloadRange(urlStr, outputPath, directory string, r *byteRange, rateLimit int64) error {
+	fmt.Printf("Starting download at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	finalOutputPath := w.outputPathFor(urlStr, outputPath, directory, false)
+
+	req, err := http.NewRequestWithContext(w.ctx, "GET", urlStr, nil)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Range", r.header())
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		if w.IsInterrupted() {
+			return fmt.Errorf("download interrupted")
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var reader io.Reader = resp.Body
+	total := r.length()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", r.start)) {
+			return fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		if total < 0 || (resp.ContentLength >= 0 && resp.ContentLength < total) {
+			total = resp.ContentLength // The slice ran past the end of the file
+		}
+	case http.StatusOK:
+		fmt.Printf("Server ignored the range request, skipping the first %s\n", formatBytes(r.start))
+		if _, err := io.CopyN(io.Discard, reader, r.start); err != nil {
+			return fmt.Errorf("file ends before start position %d: %w", r.start, err)
+		}
+		if total < 0 && resp.ContentLength >= 0 {
+			total = resp.ContentLength - r.start
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return fmt.Errorf("start position %d is beyond the end of the file", r.start)
+	default:
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+	if total >= 0 {
+		reader = io.LimitReader(reader, total)
+	}
+
+	fmt.Printf("Response received: %d %s\n", resp.StatusCode, resp.Status)
+	fmt.Printf("Saving bytes %s to '%s'\n", strings.TrimPrefix(r.header(), "bytes="), finalOutputPath)
+
+	if err := os.MkdirAll(filepath.Dir(finalOutputPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", filepath.Dir(finalOutputPath), err)
+	}
+	partPath := finalOutputPath + ".part"
+	file, err := os.Create(partPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%s': %w", partPath, err)
+	}
+	defer file.Close()
+
+	reader, done := w.status.Track(urlStr, total, reader)
+	defer done()
+	reader = NewRateLimitedReader(reader, rateLimit)
+	progress := NewProgressWriter(file, total, filepath.Base(finalOutputPath), false)
+	written, err := io.CopyBuffer(progress, reader, make([]byte, w.copySize()))
+	progress.Finish()
+
+	closeErr := file.Close()
+	if err == nil && total >= 0 && written < total {
+		err = fmt.Errorf("connection closed after %s of %s", formatBytes(written), formatBytes(total))
+	}
+	if err != nil {
+		os.Remove(partPath)
+		if w.IsInterrupted() {
+			return fmt.Errorf("download interrupted")
+		}
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if closeErr != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("failed to write file '%s': %w", partPath, closeErr)
+	}
+	if err := os.Rename(partPath, finalOutputPath); err != nil {
+		return fmt.Errorf("failed to move '%s' into place: %w", partPath, err)
+	}
+	w.recordWritten(finalOutputPath)
+
+	fmt.Printf("%s %s\n", colorize(colorGreen, "Downloaded successfully:"), urlStr)
+	fmt.Printf("Finished at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Total downloaded: %s\n", formatBytes(written))
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheEntry is the metadata stored next to a cached body
+type cacheEntry struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Stored time.Time   `json:"stored"`
+}
+
+// cacheTransport keeps full GET responses that carry an ETag or Last-Modified in an on-disk cache
+// keyed by URL. Later requests for the URL are revalidated, and a 304 is answered from the cache,
+// so unchanged files cost one round trip instead of a transfer.
+type cacheTransport struct {
+	base http.RoundTripper
+	dir  string
+}
+
+func newCacheTransport(base http.RoundTripper, dir string) (*cacheTransport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory '%s': %w", dir, err)
+	}
+	return &cacheTransport{base: base, dir: dir}, nil
+}
+
+// paths returns the metadata and body file names for urlStr
+func (t *cacheTransport) paths(urlStr string) (string, string) {
+	sum := sha256.Sum256([]byte(urlStr))
+	key := filepath.Join(t.dir, hex.EncodeToString(sum[:]))
+	return key + ".json", key + ".body"
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Partial and already-conditional requests (resumes, --watch) are their callers' business
+	if req.Method != "GET" || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	urlStr := req.URL.String()
+	metaPath, bodyPath := t.paths(urlStr)
+	entry := t.load(metaPath, urlStr)
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := entry.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		body, openErr := os.Open(bodyPath)
+		if openErr == nil {
+			info, _ := body.Stat()
+			resp.Body.Close()
+			fmt.Printf("Not modified, using cached copy of %s\n", urlStr)
+			header := entry.Header.Clone()
+			for name, values := range resp.Header { // A 304 may carry fresher validators
+				if name != "Content-Length" {
+					header[name] = values
+				}
+			}
+			header.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+			return &http.Response{
+				Status:        "200 OK",
+				StatusCode:    http.StatusOK,
+				Proto:         resp.Proto,
+				ProtoMajor:    resp.ProtoMajor,
+				ProtoMinor:    resp.ProtoMinor,
+				Header:        header,
+				Body:          body,
+				ContentLength: info.Size(),
+				Request:       resp.Request,
+			}, nil
+		}
+	}
+
+	cacheable := resp.StatusCode == http.StatusOK &&
+		(resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "") &&
+		!strings.Contains(resp.Header.Get("Cache-Control"), "no-store")
+	if cacheable {
+		if file, createErr := os.CreateTemp(t.dir, "fill-*"); createErr == nil {
+			resp.Body = &cacheFill{
+				ReadCloser: resp.Body,
+				file:       file,
+				metaPath:   metaPath,
+				bodyPath:   bodyPath,
+				entry:      cacheEntry{URL: urlStr, Header: resp.Header.Clone()},
+			}
+		}
+	}
+	return resp, nil
+}
+
+// load returns the cache entry for urlStr, or nil when there is none
+func (t *cacheTransport) load(metaPath, urlStr string) *cacheEntry {
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil || entry.URL != urlStr {
+		return nil
+	}
+	return &entry
+}
+
+// cacheFill copies a response body into the cache as it is read, and stores it only once the
+// whole body has arrived
+type cacheFill struct {
+	io.ReadCloser
+	file               *os.File
+	metaPath, bodyPath string
+	entry              cacheEntry
+	failed             bool
+}
+
+func (c *cacheFill) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 && !c.failed {
+		if _, werr := c.file.Write(p[:n]); werr != nil {
+			c.failed = true
+		}
+	}
+	if err == io.EOF && c.file != nil {
+		c.commit()
+	}
+	return n, err
+}
+
+// commit moves the complete body into place and writes its metadata
+func (c *cacheFill) commit() {
+	tmp := c.file.Name()
+	closeErr := c.file.Close()
+	c.file = nil
+	if c.failed || closeErr != nil {
+		os.Remove(tmp)
+		return
+	}
+	c.entry.Stored = time.Now()
+	data, err := json.Marshal(c.entry)
+	if err != nil || os.Rename(tmp, c.bodyPath) != nil {
+		os.Remove(tmp)
+		return
+	}
+	os.WriteFile(c.metaPath, data, 0o644)
+}
+
+func (c *cacheFill) Close() error {
+	if c.file != nil { // Closed before the end: don't keep a truncated copy
+		tmp := c.file.Name()
+		c.file.Close()
+		c.file = nil
+		os.Remove(tmp)
+	}
+	return c.ReadCloser.Close()
+}
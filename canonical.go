@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// errDuplicate marks a page not saved because it already is under the name it redirects to,
+// or the one it names as canonical
+var errDuplicate = fmt.Errorf("%w: already saved as", errFiltered)
+
+// canonicalURL returns the absolute URL of the page's <link rel="canonical">, or "" if it has none
+func canonicalURL(content, pageURL string) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	var found string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if found != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" {
+			var rel, href string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "rel":
+					rel = attr.Val
+				case "href":
+					href = attr.Val
+				}
+			}
+			for _, token := range strings.Fields(rel) {
+				if strings.EqualFold(token, "canonical") && href != "" {
+					if uri, err := toURI(href); err == nil {
+						href = uri
+					}
+					if ref, err := url.Parse(href); err == nil {
+						resolved := base.ResolveReference(ref)
+						resolved.Fragment, resolved.RawFragment = "", ""
+						found = resolved.String()
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return found
+}
+
+// linkAliases records pages saved under another URL's name (redirects, --canonical), keyed by
+// the local path each URL would have had, so links to either end up at the one saved file.
+// Paths are slash-separated and relative to the mirror directory. A nil *linkAliases holds nothing.
+type linkAliases struct {
+	mutex sync.Mutex
+	paths map[string]string
+}
+
+// add points links to the file at from to the file at to
+func (a *linkAliases) add(from, to string) {
+	from, to = filepath.ToSlash(from), filepath.ToSlash(to)
+	if from == to {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.paths == nil {
+		a.paths = make(map[string]string)
+	}
+	a.paths[from] = to
+}
+
+// resolve returns where the file meant to be at rel was saved, following a redirect to a
+// page that names another as canonical to the end
+func (a *linkAliases) resolve(rel string) string {
+	if a == nil {
+		return rel
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	key := filepath.ToSlash(rel)
+	for hops := 0; hops < 10; hops++ {
+		to, ok := a.paths[key]
+		if !ok {
+			break
+		}
+		key = to
+	}
+	return filepath.FromSlash(key)
+}
+
+// empty reports whether no alias was recorded
+func (a *linkAliases) empty() bool {
+	if a == nil {
+		return true
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return len(a.paths) == 0
+}
+
+// relink rewrites local links to aliased paths in the HTML pages under dir, for the pages
+// saved before the alias was known. prefix is the --convert-links absolute prefix links to
+// dir start with, if any. It returns the number of pages changed.
+func (a *linkAliases) relink(dir, prefix string) (int, error) {
+	if a.empty() {
+		return 0, nil
+	}
+	changed := 0
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(file))
+		if d.IsDir() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		doc, err := html.Parse(bytes.NewReader(content))
+		if err != nil {
+			return nil // Saved as served; nothing of ours to fix
+		}
+		if !a.relinkNode(doc, path.Dir(filepath.ToSlash(rel)), prefix) {
+			return nil
+		}
+		var buf bytes.Buffer
+		if err := html.Render(&buf, doc); err != nil {
+			return fmt.Errorf("failed to render '%s': %w", file, err)
+		}
+		if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", file, err)
+		}
+		changed++
+		return nil
+	})
+	return changed, err
+}
+
+// relinkNode points the local links under n, a page in directory pageDir, at their aliases
+func (a *linkAliases) relinkNode(n *html.Node, pageDir, prefix string) bool {
+	changed := false
+	if n.Type == html.ElementNode {
+		for i, attr := range n.Attr {
+			switch {
+			case (n.Data == "a" || n.Data == "link") && attr.Key == "href":
+			case (n.Data == "img" || n.Data == "script") && attr.Key == "src":
+			default:
+				continue
+			}
+			link, err := url.Parse(attr.Val)
+			if err != nil || link.Scheme != "" || link.Host != "" || link.Path == "" {
+				continue
+			}
+			target := strings.TrimPrefix(path.Join(pageDir, link.Path), "/")
+			if prefix != "" && strings.HasPrefix(link.Path, prefix) {
+				target = strings.TrimPrefix(link.Path, prefix)
+			} else if strings.HasPrefix(link.Path, "/") {
+				target = strings.TrimPrefix(link.Path, "/")
+			}
+			resolved := filepath.ToSlash(a.resolve(target))
+			if resolved == target {
+				continue
+			}
+			relPath, err := filepath.Rel(filepath.FromSlash(pageDir), filepath.FromSlash(resolved))
+			if err != nil {
+				continue
+			}
+			attr.Val = linkPath(relPath)
+			if prefix != "" {
+				attr.Val = prefix + strings.TrimPrefix(linkPath(resolved), "./")
+			}
+			if link.Fragment != "" {
+				attr.Val += "#" + link.EscapedFragment()
+			}
+			n.Attr[i] = attr
+			changed = true
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		changed = a.relinkNode(c, pageDir, prefix) || changed
+	}
+	return changed
+}
+
+// redirectChain returns the URLs resp was redirected from, the first request's first
+func redirectChain(resp *http.Response) []*url.URL {
+	var chain []*url.URL
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		if from := req.Response.Request; from != nil {
+			chain = append([]*url.URL{from.URL}, chain...)
+		}
+	}
+	return chain
+}
+
+// saveUnder records target as the name the page found under names is saved as, and reports
+// whether target was seen before, in which case its own fetch saves it. The names are marked
+// seen too, so none is fetched again.
+func (w *WgetClone) saveUnder(frontier *crawlFrontier, names []*url.URL, target *url.URL) bool {
+	targetPath := w.layout.pagePath(filePath(target))
+	for _, name := range names {
+		w.aliases.add(w.layout.pagePath(filePath(name)), targetPath)
+		frontier.claim(name.String())
+	}
+	return !frontier.claim(target.String())
+}
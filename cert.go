@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errCertExpiring fails a connection whose certificate runs out within --cert-min-days. It is not retried.
+var errCertExpiring = errors.New("server certificate expires too soon")
+
+// addConnectionCheck runs check on every TLS connection once the handshake verified the
+// server, after the checks added before it
+func addConnectionCheck(transport *http.Transport, check func(tls.ConnectionState) error) {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	previous := transport.TLSClientConfig.VerifyConnection
+	transport.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if previous != nil {
+			if err := previous(state); err != nil {
+				return err
+			}
+		}
+		return check(state)
+	}
+}
+
+// certInspector prints the certificate chain of each server once, for --show-cert, and
+// refuses certificates that expire within minDays days when that is above zero
+type certInspector struct {
+	show    bool
+	minDays int
+	mutex   sync.Mutex
+	shown   map[string]bool
+}
+
+func (c *certInspector) check(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	if c.show {
+		c.mutex.Lock()
+		if !c.shown[state.ServerName] {
+			c.shown[state.ServerName] = true
+			printCertChain(state)
+		}
+		c.mutex.Unlock()
+	}
+	if c.minDays > 0 {
+		leaf := state.PeerCertificates[0]
+		left := time.Until(leaf.NotAfter)
+		if left < time.Duration(c.minDays)*24*time.Hour {
+			return fmt.Errorf("%w: %s's certificate expires %s (in %d days; --cert-min-days is %d)",
+				errCertExpiring, state.ServerName, leaf.NotAfter.Format(time.DateOnly), int(left.Hours()/24), c.minDays)
+		}
+	}
+	return nil
+}
+
+// printCertChain writes the certificates the server sent, its own first
+func printCertChain(state tls.ConnectionState) {
+	fmt.Printf("\nCertificate chain of %s (%s):\n", state.ServerName, tls.VersionName(state.Version))
+	for i, cert := range state.PeerCertificates {
+		fmt.Printf(" %d subject: %s\n", i, cert.Subject)
+		fmt.Printf("   issuer:  %s\n", cert.Issuer)
+		if names := certNames(cert); names != "" {
+			fmt.Printf("   names:   %s\n", names)
+		}
+		days := int(time.Until(cert.NotAfter).Hours() / 24)
+		fmt.Printf("   valid:   %s to %s (%d days left)\n", cert.NotBefore.Format(time.DateOnly), cert.NotAfter.Format(time.DateOnly), days)
+		fmt.Printf("   key:     %s\n", keyType(cert))
+	}
+}
+
+// certNames lists the subject alternative names of cert
+func certNames(cert *x509.Certificate) string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// keyType describes the public key of cert, e.g. "RSA 2048" or "ECDSA P-256"
+func keyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
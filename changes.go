@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// snapshotName is the file in a mirror directory recording what each URL was saved as, so
+// the next run can tell what changed
+const snapshotName = ".wget-snapshot"
+
+// mirrorSnapshot is what a completed mirror run saved
+type mirrorSnapshot struct {
+	TakenAt time.Time                `json:"taken_at"`
+	Files   map[string]snapshotEntry `json:"files"` // By URL
+}
+
+type snapshotEntry struct {
+	Path   string `json:"path"` // Slash-separated, relative to the mirror directory
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// changeEntry is one added, modified or removed URL of a change report
+type changeEntry struct {
+	URL   string `json:"url"`
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`  // Now, or before for a removed URL
+	Delta int64  `json:"delta"` // Change in bytes
+}
+
+// changeReport is what changed on a site between two mirror runs
+type changeReport struct {
+	Since    time.Time     `json:"since"`
+	Until    time.Time     `json:"until"`
+	Added    []changeEntry `json:"added"`
+	Modified []changeEntry `json:"modified"`
+	Removed  []changeEntry `json:"removed"`
+}
+
+// takeSnapshot records the files of the URLs saved this run, as the url map has them
+func (w *WgetClone) takeSnapshot() *mirrorSnapshot {
+	snapshot := &mirrorSnapshot{TakenAt: time.Now(), Files: make(map[string]snapshotEntry)}
+	for urlStr, path := range w.urlMap.saved() {
+		var size int64
+		sum, err := fileSHA256(filepath.FromSlash(path))
+		if synced, ok := w.sync.removed(filepath.FromSlash(path)); ok && os.IsNotExist(err) {
+			size, sum, err = synced.size, synced.sha256, nil // Uploaded by --sync-to and removed
+		} else if err == nil {
+			var info os.FileInfo
+			if info, err = os.Stat(filepath.FromSlash(path)); err == nil {
+				size = info.Size()
+			}
+		}
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(w.mirrorBaseDir, filepath.FromSlash(path)); err == nil {
+			path = filepath.ToSlash(rel)
+		}
+		snapshot.Files[urlStr] = snapshotEntry{Path: path, Size: size, SHA256: sum}
+	}
+	return snapshot
+}
+
+// loadSnapshot reads the snapshot an earlier run left in dir; nil if there is none
+func loadSnapshot(dir string) (*mirrorSnapshot, error) {
+	data, err := os.ReadFile(filepath.Join(dir, snapshotName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot mirrorSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("corrupt %s: %w", snapshotName, err)
+	}
+	return &snapshot, nil
+}
+
+// save writes the snapshot into dir, replacing the one before
+func (s *mirrorSnapshot) save(dir string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, snapshotName)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", snapshotName, err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// compareSnapshots lists the URLs added, modified and removed from before to after
+func compareSnapshots(before, after *mirrorSnapshot) *changeReport {
+	report := &changeReport{Since: before.TakenAt, Until: after.TakenAt, Added: []changeEntry{}, Modified: []changeEntry{}, Removed: []changeEntry{}}
+	for urlStr, now := range after.Files {
+		was, ok := before.Files[urlStr]
+		switch {
+		case !ok:
+			report.Added = append(report.Added, changeEntry{URL: urlStr, Path: now.Path, Size: now.Size, Delta: now.Size})
+		case was.SHA256 != now.SHA256:
+			report.Modified = append(report.Modified, changeEntry{URL: urlStr, Path: now.Path, Size: now.Size, Delta: now.Size - was.Size})
+		}
+	}
+	for urlStr, was := range before.Files {
+		if _, ok := after.Files[urlStr]; !ok {
+			report.Removed = append(report.Removed, changeEntry{URL: urlStr, Path: was.Path, Size: was.Size, Delta: -was.Size})
+		}
+	}
+	for _, list := range [][]changeEntry{report.Added, report.Modified, report.Removed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].URL < list[j].URL })
+	}
+	return report
+}
+
+// empty reports whether nothing changed
+func (r *changeReport) empty() bool {
+	return len(r.Added)+len(r.Modified)+len(r.Removed) == 0
+}
+
+// summary is the one-line count of the changes
+func (r *changeReport) summary() string {
+	return fmt.Sprintf("%d added, %d modified, %d removed since %s", len(r.Added), len(r.Modified), len(r.Removed), r.Since.Format("2006-01-02 15:04:05"))
+}
+
+// Print lists every change, + added, ~ modified and - removed, with its byte delta
+func (r *changeReport) Print() {
+	fmt.Printf("Changes since the mirror of %s:\n", r.Since.Format("2006-01-02 15:04:05"))
+	for _, change := range [...]struct {
+		mark    string
+		entries []changeEntry
+	}{{"+", r.Added}, {"~", r.Modified}, {"-", r.Removed}} {
+		for _, e := range change.entries {
+			fmt.Printf("  %s %s (%s)\n", change.mark, e.URL, formatDelta(e.Delta))
+		}
+	}
+	fmt.Println(r.summary())
+}
+
+// WriteJSON saves the report to file
+func (r *changeReport) WriteJSON(file string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write change report: %w", err)
+	}
+	return nil
+}
+
+// formatDelta formats a change in bytes with its sign, e.g. +1.2 KB
+func formatDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatBytes(-delta)
+	}
+	return "+" + formatBytes(delta)
+}
+
+// reportChanges compares this completed mirror run with the snapshot of the last one, prints
+// the result (every change with --change-report, which also gets it as JSON) and stores
+// this run's snapshot for the next
+func (w *WgetClone) reportChanges() error {
+	before, err := loadSnapshot(w.mirrorBaseDir)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	after := w.takeSnapshot()
+	if before != nil {
+		// A URL that timed out, failed with a 5xx or wasn't reached this time isn't removed
+		for urlStr, was := range before.Files {
+			if _, ok := after.Files[urlStr]; !ok && !w.urlMap.gone(urlStr) {
+				after.Files[urlStr] = was
+			}
+		}
+		report := compareSnapshots(before, after)
+		if w.changeReport != "" {
+			report.Print()
+			if err := report.WriteJSON(w.changeReport); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote change report %s\n", w.changeReport)
+		} else if !report.empty() {
+			fmt.Printf("Changes: %s\n", report.summary())
+		}
+	}
+	return after.save(w.mirrorBaseDir)
+}
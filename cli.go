@@ -0,0 +1,497 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// stripFlags removes the named value flags (in any -name/--name/=value form) from args
+func stripFlags(args []string, names ...string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimLeft(arg, "-")
+		if !strings.HasPrefix(arg, "-") || name == "" {
+			kept = append(kept, arg)
+			continue
+		}
+
+		matched := false
+		for _, n := range names {
+			if name == n {
+				matched = true
+				i++ // Skip the value too
+				break
+			}
+			if strings.HasPrefix(name, n+"=") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// stripBoolFlags removes the named boolean flags (-name, --name, -name=value) from args
+func stripBoolFlags(args []string, names ...string) []string {
+	var kept []string
+	for _, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+
+		matched := false
+		if strings.HasPrefix(arg, "-") {
+			for _, n := range names {
+				if name == n {
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// pathValue is a string flag naming a local file or directory, which shell completion offers
+// paths for
+type pathValue struct {
+	value *string
+	kind  string // "file" or "dir"
+}
+
+func (p *pathValue) String() string {
+	if p.value == nil {
+		return ""
+	}
+	return *p.value
+}
+
+func (p *pathValue) Set(value string) error {
+	*p.value = value
+	return nil
+}
+
+// fileVar defines a string flag whose value is a file, like fs.StringVar
+func fileVar(fs *flag.FlagSet, p *string, name, value, usage string) {
+	*p = value
+	fs.Var(&pathValue{value: p, kind: "file"}, name, usage)
+}
+
+// dirVar defines a string flag whose value is a directory, like fs.StringVar
+func dirVar(fs *flag.FlagSet, p *string, name, value, usage string) {
+	*p = value
+	fs.Var(&pathValue{value: p, kind: "dir"}, name, usage)
+}
+
+// unlimitedDepth is the -l value of `-l inf` and `-l 0`
+const unlimitedDepth = math.MaxInt
+
+// depthValue is the -l flag: a number of levels, or inf (or 0, as in GNU wget) for no limit
+type depthValue int
+
+func (d *depthValue) String() string {
+	if *d == unlimitedDepth {
+		return "inf"
+	}
+	return strconv.Itoa(int(*d))
+}
+
+func (d *depthValue) Set(value string) error {
+	if value == "inf" || value == "0" {
+		*d = unlimitedDepth
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("want a number of levels or inf")
+	}
+	*d = depthValue(n)
+	return nil
+}
+
+// extractValue is --extract, which takes an optional directory: bare, it extracts next to
+// the archive into a directory named after it
+type extractValue struct {
+	enabled bool
+	dir     string
+}
+
+func (e *extractValue) String() string {
+	return e.dir
+}
+
+func (e *extractValue) Set(value string) error {
+	e.enabled = value != "false"
+	e.dir = ""
+	if value != "true" && value != "false" {
+		e.dir = value
+	}
+	return nil
+}
+
+func (e *extractValue) IsBoolFlag() bool { return true }
+
+// flagGroup selects which sets of flags a command accepts
+type flagGroup int
+
+const (
+	commonFlags      flagGroup = 1 << iota // Output directory, rate limit, background and scheduling
+	getFlags                               // Single-URL downloads
+	batchFlags                             // URL lists given with -i
+	mirrorFlags                            // Recursive mirroring
+	concurrencyFlags                       // Parallel downloads for batches and mirrors
+	legacyFlags                            // The flat interface's --mirror switch
+
+	allFlags = commonFlags | getFlags | batchFlags | mirrorFlags | concurrencyFlags | legacyFlags
+)
+
+// cliOptions holds the value of every download flag; commands register only the groups they use
+type cliOptions struct {
+	output        string
+	directory     string
+	rateLimit     string
+	rateBurst     string
+	ratePresets   string
+	background    bool
+	inputFile     string
+	forceHTML     bool
+	baseURL       string
+	mirror        bool
+	reject        string
+	exclude       string
+	excludeRegex  stringList
+	maxDepth      int
+	maxConcurrent int
+	adaptive      bool
+	verifyLinks   bool
+	extractData   bool
+	forceLock     bool
+	minFileSize   string
+	maxFileSize   string
+	acceptMime    string
+	rejectMime    string
+	loginURL      string
+	loginData     string
+	loadCookies   string
+	saveCookies   string
+	followLog     bool
+	continueDl    bool
+	startAt       string
+	schedule      string
+	watch         string
+	watchStamped  bool
+	queueFile     string
+	manifest      bool
+	urlMap        string
+	statusFifo    string
+	zsync         bool
+	metalink      string
+	sources       stringList
+	signatureURL  string
+	keyring       string
+	deleteBadSig  bool
+	proxy         string
+	proxyUser     string
+	notifyURL     string
+	debug         bool
+	stats         bool
+	statsJSON     string
+	timing        bool
+	progress      string
+	color         string
+	notifyDesktop bool
+	otlpEndpoint  string
+	otlpService   string
+	noKeepAlive   bool
+	idlePerHost   int
+	connsPerHost  int
+	tcpKeepAlive  string
+	tcpFastOpen   bool
+	torProxy      string
+	unixSocket    string
+	connectTo     stringList
+	hostName      string
+	pinnedKey     string
+	showCert      bool
+	certMinDays   int
+	eyeballDelay  string
+	noDNSPrefetch bool
+	splitSize     string
+	pipeTo        string
+	decompress    bool
+	extract       extractValue
+	deleteArchive bool
+	bufferSize    string
+	ignoreLength  bool
+	startPos      string
+	endPos        string
+	byteRange     string
+	head          bool
+	cacheDir      string
+	noHostDirs    bool
+	cutDirs       int
+	noDirs        bool
+	flatten       bool
+	backupOrig    bool
+	noConvert     bool
+	convertLinks  string
+	deleteAfter   bool
+	relativeOnly  bool
+	crawlOrder    string
+	bloomRate     float64
+	bloomCapacity int
+	memoryLimit   string
+	maxPages      int
+	crawlTimeout  string
+	rewriteRules  stringList
+	noRobotsMeta  bool
+	noCrawlDelay  bool
+	canonical     bool
+	sitemap       bool
+	sitemapURL    string
+	changeReport  string
+	syncTo        string
+	syncDelete    bool
+	restrictNames string
+	errorContent  bool
+	tries         int
+	downloadTime  string
+	readTimeout   string
+	retryCodes    string
+	uploadFile    string
+	uploadMethod  string
+	headers       stringList
+	language      string
+	dnt           bool
+	userAgent     string
+	userAgentFile string
+	rotateAgent   string
+	auth          string
+	oauthTokenURL string
+	oauthClientID string
+	oauthSecret   string
+	oauthScope    string
+	proxyPassword string
+	user          string
+	password      string
+	askPassword   bool
+	config        string
+	profile       string
+}
+
+// register defines the flags of the selected groups on fs, with their defaults
+func (o *cliOptions) register(fs *flag.FlagSet, groups flagGroup) {
+	if groups&commonFlags != 0 {
+		dirVar(fs, &o.directory, "P", "", "Directory to save files")
+		fs.StringVar(&o.rateLimit, "rate-limit", "", "Rate limit (e.g., 200k, 2M)")
+		fs.StringVar(&o.rateBurst, "rate-burst", "", "Most bytes let through at once under --rate-limit (default: a tenth of a second's worth)")
+		fs.StringVar(&o.ratePresets, "rate-presets", "200k,1M,off", "Rate limits SIGUSR2 steps through while running")
+		fs.BoolVar(&o.background, "B", false, "Download in background")
+		fs.BoolVar(&o.followLog, "follow-log", false, "With -B, stream the log file until the download finishes")
+		fs.BoolVar(&o.continueDl, "c", false, "Continue a partial download from its .part file")
+		fs.StringVar(&o.startAt, "start-at", "", "Delay the run until a time (HH:MM or YYYY-MM-DD HH:MM)")
+		fs.StringVar(&o.schedule, "schedule", "", "Repeat the run on a cron schedule (e.g. \"0 3 * * *\", @daily)")
+		fileVar(fs, &o.statusFifo, "status-fifo", "", "Named pipe that yields a status snapshot when read (SIGUSR1 prints one too)")
+		fs.StringVar(&o.proxy, "proxy", "", "Proxy URL for all requests (default: HTTP_PROXY/HTTPS_PROXY)")
+		fs.StringVar(&o.proxyUser, "proxy-user", "", "User name for proxies that require authentication (Basic or Digest)")
+		fs.StringVar(&o.proxyPassword, "proxy-password", "", "Password for --proxy-user (prompted for, or read from $WGET_PROXY_PASSWORD, when omitted)")
+		fs.StringVar(&o.user, "user", "", "User name for servers that ask for Basic authentication")
+		fs.StringVar(&o.password, "password", "", "Password for --user (prompted for, or read from $WGET_PASSWORD, when omitted)")
+		fs.BoolVar(&o.askPassword, "ask-password", false, "Prompt for the --user and --proxy-user passwords on the terminal even if configured")
+		fs.StringVar(&o.auth, "auth", "basic", "How to answer servers asking for authentication: basic or ntlm (with --user/--password), or negotiate (Kerberos, from the credential cache kinit fills)")
+		fs.StringVar(&o.oauthTokenURL, "oauth-token-url", "", "OAuth2 token endpoint: get a bearer token with the client-credentials grant and send it to the start URLs' hosts")
+		fs.StringVar(&o.oauthClientID, "oauth-client-id", "", "Client ID for --oauth-token-url")
+		fs.StringVar(&o.oauthSecret, "oauth-client-secret", "", "Client secret for --oauth-token-url (default: $WGET_OAUTH_CLIENT_SECRET)")
+		fs.StringVar(&o.oauthScope, "oauth-scope", "", "Space-separated scopes to ask --oauth-token-url for")
+		fileVar(fs, &o.config, "config", "", "Config file of flag defaults and profiles (default: $WGET_CONFIG or ~/.config/go-wget/config)")
+		fs.StringVar(&o.profile, "profile", "", "Apply the named [profile] section of the config file")
+		fs.StringVar(&o.minFileSize, "min-filesize", "", "Skip files smaller than this (e.g. 10k)")
+		fs.StringVar(&o.maxFileSize, "max-filesize", "", "Skip files larger than this (e.g. 500M, 2G)")
+		fs.StringVar(&o.acceptMime, "accept-mime", "", "Only save responses whose Content-Type matches (e.g. \"text/*,image/png\")")
+		fs.StringVar(&o.loginURL, "login-url", "", "POST --login-data here before downloading and keep the session cookies")
+		fs.StringVar(&o.loginData, "login-data", "", "Form body for --login-url (e.g. \"user=me&pass=secret\", or @file to read it)")
+		fileVar(fs, &o.loadCookies, "load-cookies", "", "Load cookies from a Netscape cookies.txt file")
+		fileVar(fs, &o.saveCookies, "save-cookies", "", "Save the session's cookies (including session cookies) to this file")
+		fs.StringVar(&o.progress, "progress", "bar", "Progress display: bar, dot (dot:mega for big files) or none")
+		fs.StringVar(&o.color, "color", "auto", "Color status messages: auto (when stdout is a terminal), always or never")
+		fs.BoolVar(&o.debug, "d", false, "Debug: log every request and response, headers and the start of bodies, to stderr")
+		fs.BoolVar(&o.debug, "debug", false, "Same as -d")
+		fs.BoolVar(&o.stats, "stats", false, "Print run totals at the end: time, bytes, speeds, file outcomes and HTTP status codes")
+		fileVar(fs, &o.statsJSON, "stats-json", "", "Write the run totals as JSON to this file (- for stdout)")
+		fs.BoolVar(&o.timing, "timing", false, "After each download, print how long DNS, connect, TLS, the server and the transfer took")
+		fs.StringVar(&o.notifyURL, "notify-url", "", "POST a JSON summary of the run here when it finishes or fails")
+		fs.BoolVar(&o.notifyDesktop, "notify-desktop", false, "Show a desktop notification when the run finishes or fails")
+		fs.StringVar(&o.otlpEndpoint, "otlp-endpoint", "", "Export a span per request and run metrics to this OpenTelemetry collector (OTLP/HTTP, e.g. http://localhost:4318)")
+		fs.StringVar(&o.otlpService, "otlp-service", "wget", "service.name reported with --otlp-endpoint")
+		fs.BoolVar(&o.noKeepAlive, "no-http-keep-alive", false, "Open a new connection for every request instead of reusing them")
+		fs.IntVar(&o.idlePerHost, "max-idle-conns-per-host", 0, "Idle connections kept open per host for reuse (default 2)")
+		fs.IntVar(&o.connsPerHost, "max-connections-per-host", 0, "Open at most this many connections to any one host, however high --max-concurrent is (0 = no limit)")
+		fs.StringVar(&o.tcpKeepAlive, "tcp-keepalive", "", "Interval between TCP keep-alive probes, e.g. 15s (0 disables; default 30s)")
+		dirVar(fs, &o.cacheDir, "cache-dir", "", "Keep downloads with an ETag or Last-Modified here and reuse them when the server answers 304")
+		fs.BoolVar(&o.ignoreLength, "ignore-length", false, "Ignore the Content-Length header, for servers that send a wrong one")
+		fs.StringVar(&o.bufferSize, "buffer-size", "", "Read and write in chunks of this size (e.g. 1m for 10 Gb links and fast disks; default 32k)")
+		fs.BoolVar(&o.tcpFastOpen, "tcp-fastopen", false, "Use TCP Fast Open to save a round trip when reconnecting (Linux)")
+		fs.BoolVar(&o.noDNSPrefetch, "no-dns-prefetch", false, "Resolve each host only when connecting to it, instead of looking up the hosts of queued URLs ahead")
+		fs.StringVar(&o.eyeballDelay, "happy-eyeballs-delay", defaultAttemptDelay.String(), "Head start of each connection attempt before the next address (IPv6 and IPv4 alternating) is tried in parallel")
+		fs.StringVar(&o.torProxy, "tor-proxy", defaultTorProxy, "Tor SOCKS proxy that .onion URLs go through; they fail instead of leaking when it's down")
+		fileVar(fs, &o.unixSocket, "unix-socket", "", "Connect to this Unix domain socket instead of the URL's host, e.g. /var/run/docker.sock")
+		fs.Var(&o.connectTo, "connect-to", "Connect to CONNECT-HOST:CONNECT-PORT for requests to HOST:PORT, keeping the URL's Host header and TLS name; empty fields match or keep any (repeatable)")
+		fs.StringVar(&o.hostName, "host", "", "Send this Host header and TLS server name (SNI) while connecting to the URL's address")
+		fileVar(fs, &o.pinnedKey, "pinned-pubkey", "", "Abort unless the server's public key matches: sha256//BASE64 pins separated by ';', or a file of pins, a public key or a certificate")
+		fs.BoolVar(&o.showCert, "show-cert", false, "Print each HTTPS server's certificate chain: subject, issuer, names, expiry and key type")
+		fs.IntVar(&o.certMinDays, "cert-min-days", 0, "Fail if the server's certificate expires within this many days")
+		fs.BoolVar(&o.deleteAfter, "delete-after", false, "Delete each file after downloading it (to prime a caching proxy); mirrors are still crawled")
+		fs.StringVar(&o.restrictNames, "restrict-file-names", "", "Characters to escape in local file names: unix, windows, nocontrol, ascii, lowercase, uppercase (comma-separated)")
+		fs.Var(&o.headers, "header", "Extra request header \"Name: value\", replacing the default of that name (repeatable)")
+		fs.StringVar(&o.language, "accept-language", "", "Accept-Language to send, e.g. \"de-DE,de;q=0.9\", to pick one language of a multilingual site")
+		fs.BoolVar(&o.dnt, "dnt", false, "Send DNT: 1 (Do Not Track)")
+		fs.StringVar(&o.userAgent, "user-agent", "", "User-Agent to send instead of "+defaultUserAgent+"; \"browser\" sends a current desktop browser's")
+		fileVar(fs, &o.userAgentFile, "user-agent-file", "", "File of User-Agents, one per line, to rotate through (with --user-agent, that one comes first)")
+		fs.StringVar(&o.rotateAgent, "rotate-user-agent", "request", "Move to the next User-Agent for every request, or keep one per host: request or host")
+		fs.BoolVar(&o.errorContent, "content-on-error", false, "Save the body of 4xx/5xx responses (the download still fails)")
+		fs.IntVar(&o.tries, "tries", 0, "Attempts per request, counting the first; network errors are retried (default 1, or 5 with --retry-on-http-error)")
+		fs.StringVar(&o.retryCodes, "retry-on-http-error", "", "HTTP status codes to retry as well, e.g. 500,502,503 (honors Retry-After)")
+		fs.StringVar(&o.downloadTime, "max-download-time", "", "Abort any single transfer still running after this long (e.g. 10m); with --tries it starts over")
+		fs.StringVar(&o.readTimeout, "read-timeout", "", "Fail a transfer that receives no data for this long (e.g. 30s), resuming it where the server allows; slow but steady ones go on")
+		fs.StringVar(&o.rejectMime, "reject-mime", "", "Skip responses whose Content-Type matches (e.g. \"video/*\")")
+	}
+	if groups&getFlags != 0 {
+		fileVar(fs, &o.output, "O", "", "Output filename")
+		fs.StringVar(&o.pipeTo, "pipe-to", "", "Stream the download into the standard input of this shell command instead of a file; wget exits with its status")
+		fs.BoolVar(&o.decompress, "auto-decompress", false, "Unpack .gz, .bz2, .xz and .zst downloads as they arrive, saving them without the extension")
+		fs.Var(&o.extract, "extract", "Extract downloaded .tar(.gz|.bz2|.xz|.zst) and .zip archives; --extract=DIR picks where (default: a directory named after the archive)")
+		fs.BoolVar(&o.deleteArchive, "delete-archive", false, "With --extract, delete each archive once it is extracted")
+		fs.StringVar(&o.splitSize, "split-size", "", "Save the download as numbered files of at most this size (e.g. 1G for FAT32), with a NAME.parts manifest to reassemble them")
+		fs.StringVar(&o.watch, "watch", "", "Poll the URL on an interval (e.g. 30s, 5m) and save it when it changes")
+		fs.BoolVar(&o.watchStamped, "watch-timestamped", false, "With --watch, keep every changed copy under a timestamped name")
+		fs.BoolVar(&o.zsync, "zsync", false, "Update an existing local copy using URL.zsync, fetching only changed blocks")
+		fileVar(fs, &o.metalink, "metalink", "", "Download the file described by a Metalink v4 document from all its mirrors")
+		fs.Var(&o.sources, "source", "Additional mirror URL of the same file, downloaded in parallel ranges (repeatable)")
+		fileVar(fs, &o.keyring, "keyring", "", "Verify the download's detached OpenPGP signature against these exported public keys")
+		fs.StringVar(&o.signatureURL, "signature-url", "", "URL of the detached signature for --keyring (default: URL.sig, then URL.asc)")
+		fs.BoolVar(&o.deleteBadSig, "delete-bad-signature", false, "With --keyring, delete the file when its signature does not verify")
+		fs.BoolVar(&o.head, "head", false, "Print the size, type, Last-Modified and final URL of a file without downloading it")
+		fileVar(fs, &o.uploadFile, "T", "", "Upload this local file to the URL instead of downloading (a URL ending in / gets the file name)")
+		fileVar(fs, &o.uploadFile, "upload-file", "", "Same as -T")
+		fs.StringVar(&o.uploadMethod, "upload-method", "PUT", "HTTP method for -T: PUT or POST")
+		fs.StringVar(&o.startPos, "start-pos", "", "Save the file from this byte offset on (e.g. 100m)")
+		fs.StringVar(&o.endPos, "end-pos", "", "Stop after this byte offset, inclusive")
+		fs.StringVar(&o.byteRange, "range", "", "Save only bytes START-END (or START- to the end) of the file")
+	}
+	if groups&batchFlags != 0 {
+		fileVar(fs, &o.inputFile, "i", "", "File containing URLs to download")
+		fs.BoolVar(&o.forceHTML, "force-html", false, "Treat the -i file as an HTML page and download the links it references")
+		fs.StringVar(&o.baseURL, "base", "", "Resolve relative links in the -i file against this URL")
+		fileVar(fs, &o.queueFile, "queue-file", "", "With -i, record progress here so a re-run only fetches unfinished URLs")
+	}
+	if groups&legacyFlags != 0 {
+		fs.BoolVar(&o.mirror, "mirror", false, "Mirror website")
+	}
+	if groups&mirrorFlags != 0 {
+		fs.StringVar(&o.reject, "R", "", "Comma-separated file extensions to reject")
+		fs.StringVar(&o.exclude, "X", "", "Comma-separated paths to exclude")
+		fs.Var(&o.excludeRegex, "exclude-regex", "Skip URLs whose full text (scheme, host, path and query) matches this regular expression (repeatable)")
+		o.maxDepth = 3
+		fs.Var((*depthValue)(&o.maxDepth), "l", "Max recursion depth for mirroring (inf or 0 for no limit; page requisites don't count)")
+		fs.BoolVar(&o.verifyLinks, "verify-links", false, "Check rewritten local links after mirroring")
+		fs.BoolVar(&o.extractData, "extract-data-uris", false, "Save data: URIs in HTML/CSS as files instead of leaving them inline")
+		fs.BoolVar(&o.forceLock, "force-lock", false, "Steal the mirror directory lock held by another run")
+		fs.BoolVar(&o.noHostDirs, "nH", false, "Mirror straight into -P instead of a directory named after the host")
+		fs.BoolVar(&o.noHostDirs, "no-host-directories", false, "Same as -nH")
+		fs.IntVar(&o.cutDirs, "cut-dirs", 0, "Drop this many leading directories of URL paths from saved file paths")
+		fs.BoolVar(&o.noDirs, "nd", false, "Save all mirrored files in one directory (clashing names get .1, .2, ...)")
+		fs.BoolVar(&o.noDirs, "no-directories", false, "Same as -nd")
+		fs.BoolVar(&o.flatten, "flatten", false, "Save all mirrored files in one directory, each name suffixed with a hash of its URL path (index-1a2b3c4d.html)")
+		fs.BoolVar(&o.backupOrig, "K", false, "Keep the original of each page whose links were rewritten, as FILE.orig")
+		fs.BoolVar(&o.backupOrig, "backup-converted", false, "Same as -K")
+		fs.BoolVar(&o.relativeOnly, "L", false, "Follow relative links only, to stay inside a section of the site")
+		fs.BoolVar(&o.relativeOnly, "relative", false, "Same as -L")
+		fs.BoolVar(&o.noCrawlDelay, "ignore-crawl-delay", false, "Don't wait between requests to a host for the Crawl-delay its robots.txt sets")
+		fs.BoolVar(&o.noRobotsMeta, "ignore-robots-meta", false, "Follow and save pages even when a robots meta tag or X-Robots-Tag says nofollow or noindex")
+		fs.StringVar(&o.crawlOrder, "crawl-order", "bfs", "Order to fetch mirrored URLs in: bfs (by depth), dfs (deepest first) or priority (pages before the files they use)")
+		fs.Float64Var(&o.bloomRate, "visited-bloom", 0, "Remember visited URLs in a Bloom filter with this false-positive rate (e.g. 0.001), so memory stays flat on huge crawls; a false positive skips a URL")
+		fs.IntVar(&o.bloomCapacity, "visited-bloom-capacity", defaultBloomCapacity, "Number of URLs to size --visited-bloom for; past it false positives grow")
+		fs.StringVar(&o.memoryLimit, "memory-limit", "", "Bytes of page bodies and queued URLs to hold at most (e.g. 256M); past it the mirror pauses link discovery")
+		fs.IntVar(&o.maxPages, "max-pages", 0, "Fetch at most this many pages and files, then finish what's in flight and stop (0 = no limit)")
+		fs.StringVar(&o.crawlTimeout, "crawl-timeout", "", "Start no new URLs after this long (e.g. 2h), saving the queue so the next run resumes")
+		fs.BoolVar(&o.canonical, "canonical", false, "Save a page under the same-host rel=canonical URL it declares, so duplicates collapse into one file")
+		fs.Var(&o.rewriteRules, "rewrite-rule", "Rewrite discovered URLs with 'regex=>replacement' before fetching them; $1 refers to a group (repeatable, applied in order)")
+		fs.BoolVar(&o.sitemap, "sitemap", false, "After mirroring, write sitemap.xml and an HTML index, sitemap.html, of the saved pages")
+		fs.StringVar(&o.sitemapURL, "sitemap-url", "", "URL the mirror will be published at, for the sitemap's links (implies --sitemap; default: the mirrored site)")
+		fileVar(fs, &o.changeReport, "change-report", "", "List the URLs added, modified and removed since the last run of the mirror, and write them to this file as JSON")
+		fs.StringVar(&o.syncTo, "sync-to", "", "Upload mirrored files as they are saved to s3://bucket/prefix, sftp://host/path, rsync://host/module or host:path (rsync over SSH)")
+		fs.BoolVar(&o.syncDelete, "sync-delete", false, "Remove each mirrored file once --sync-to has uploaded it, so the mirror needn't fit on local disk")
+		fs.BoolVar(&o.noConvert, "no-convert-links", false, "Save pages as served; rewrite their links later with wget convert-links")
+		fs.StringVar(&o.convertLinks, "convert-links", "relative", "How saved pages link to each other: relative, or absolute-prefix=PATH for a mirror published under PATH, e.g. absolute-prefix=/mirror/")
+	}
+	if groups&(batchFlags|mirrorFlags) != 0 {
+		fs.BoolVar(&o.manifest, "checksum-manifest", false, "Write a SHA256SUMS manifest of the saved files (in -P, or the mirror directory)")
+		fileVar(fs, &o.urlMap, "url-map", "", "Write each URL's local path, status and SHA-256 to this file, as CSV if it ends in .csv and JSON otherwise")
+	}
+	if groups&concurrencyFlags != 0 {
+		fs.IntVar(&o.maxConcurrent, "max-concurrent", 5, "Maximum concurrent downloads for -i and --mirror")
+		fs.BoolVar(&o.adaptive, "adaptive", false, "Tune per-host concurrency automatically, up to --max-concurrent")
+	}
+}
+
+// legacyFlagSet returns the flags of the flat interface
+func legacyFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("wget", flag.ContinueOnError)
+	(&cliOptions{}).register(fs, allFlags)
+	return fs
+}
+
+// downloadCommand is a subcommand that runs the download pipeline with a subset of the flags
+type downloadCommand struct {
+	name    string
+	usage   string
+	groups  flagGroup
+	example string
+}
+
+var downloadCommands = []downloadCommand{
+	{"get", "./wget get [options] URL", commonFlags | getFlags, "./wget get -O page.html https://example.com/"},
+	{"batch", "./wget batch [options] FILE", commonFlags | batchFlags | concurrencyFlags, "./wget batch -P downloads urls.txt"},
+	{"mirror", "./wget mirror [options] URL", commonFlags | mirrorFlags | concurrencyFlags, "./wget mirror -R png,jpg https://example.com/"},
+}
+
+// newCommandFlagSet returns the flag set of a download command, or false if name is not one
+func newCommandFlagSet(name string, opts *cliOptions) (*flag.FlagSet, bool) {
+	for _, cmd := range downloadCommands {
+		if cmd.name != name {
+			continue
+		}
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		opts.register(fs, cmd.groups)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage:\n  %s\n\nOptions:\n", cmd.usage)
+			fs.PrintDefaults()
+			fmt.Fprintf(fs.Output(), "\nExample:\n  %s\n", cmd.example)
+		}
+		return fs, true
+	}
+	return nil, false
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ANSI color codes for status messages
+const (
+	colorGreen  = "32"
+	colorRed    = "31"
+	colorYellow = "33"
+)
+
+// colorEnabled turns on colored status messages, set by --color
+var colorEnabled bool
+
+// setupColor applies --color: always, never, or auto to color only when stdout is a terminal
+// that supports it and $NO_COLOR is unset
+func setupColor(mode string) error {
+	switch mode {
+	case "always":
+		colorEnabled = true
+	case "never":
+		colorEnabled = false
+	case "", "auto":
+		colorEnabled = term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("TERM") != "dumb" && os.Getenv("NO_COLOR") == ""
+	default:
+		return fmt.Errorf("unknown color mode %q (use auto, always or never)", mode)
+	}
+	return nil
+}
+
+// colorize wraps s in the ANSI color code when colors are enabled
+func colorize(code, s string) string {
+	if !colorEnabled {
+		return s
+	}
+	return "\033[" + code + "m" + s + "\033[0m"
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const completionUsage = `Usage:
+  ./wget completion bash        Print a bash completion script
+  ./wget completion zsh         Print a zsh completion script
+  ./wget completion fish        Print a fish completion script
+
+Example:
+  source <(./wget completion bash)`
+
+// subcommand is a word accepted before any flags, with what it accepts next
+type subcommand struct {
+	name        string
+	description string
+	commands    []string             // Words accepted right after the subcommand
+	flags       func() *flag.FlagSet // Flags the subcommand accepts, nil if none
+	arg         string               // Kind of positional argument: "url", "file", "dir" or empty
+}
+
+// downloadFlags returns a constructor for the flag set of a download command
+func downloadFlags(name string) func() *flag.FlagSet {
+	return func() *flag.FlagSet {
+		fs, _ := newCommandFlagSet(name, &cliOptions{})
+		return fs
+	}
+}
+
+// subcommands lists the CLI's subcommands for completion; keep it in step with main()
+var subcommands = []subcommand{
+	{name: "get", description: "Download a single URL", flags: downloadFlags("get"), arg: "url"},
+	{name: "batch", description: "Download the URLs listed in a file", flags: downloadFlags("batch"), arg: "file"},
+	{name: "mirror", description: "Mirror a website recursively", flags: downloadFlags("mirror"), arg: "url"},
+	{name: "jobs", description: "Manage downloads started with -B", commands: []string{"list", "status", "stop", "log", "tail", "rate", "clean"}},
+	{name: "serve", description: "Browse a mirrored site over local HTTP", flags: newServeFlagSet, arg: "dir"},
+	{name: "verify", description: "Check a mirror for dangling local links", arg: "dir"},
+	{name: "convert-links", description: "Rewrite the links of a mirror saved with --no-convert-links", flags: newConvertFlagSet, arg: "dir"},
+	{name: "completion", description: "Print a shell completion script", commands: []string{"bash", "zsh", "fish"}},
+}
+
+// completionFlag is one flag as the completion scripts see it
+type completionFlag struct {
+	name       string
+	usage      string
+	takesValue bool
+	repeatable bool
+	pathKind   string // "file", "dir" or empty
+}
+
+// spelling is how the flag is written on the command line: -X for single letters, --name otherwise
+func (f completionFlag) spelling() string {
+	if len(f.name) == 1 {
+		return "-" + f.name
+	}
+	return "--" + f.name
+}
+
+// completionFlags collects every flag defined on fs, sorted by name
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		cf := completionFlag{name: f.Name, usage: f.Usage, takesValue: true}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			cf.takesValue = false
+		}
+		if _, ok := f.Value.(*stringList); ok {
+			cf.repeatable = true
+		}
+		if path, ok := f.Value.(*pathValue); ok {
+			cf.pathKind = path.kind
+		}
+		flags = append(flags, cf)
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+// completionFlags returns the flags of the subcommand, if it takes any
+func (sub subcommand) completionFlags() []completionFlag {
+	if sub.flags == nil {
+		return nil
+	}
+	return completionFlags(sub.flags())
+}
+
+// RunCompletionCommand implements the `completion` subcommand and returns the process exit code
+func RunCompletionCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Println(completionUsage)
+		return 1
+	}
+
+	program := filepath.Base(os.Args[0])
+	legacy := completionFlags(legacyFlagSet())
+
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion(program, legacy))
+	case "zsh":
+		fmt.Print(zshCompletion(program, legacy))
+	case "fish":
+		fmt.Print(fishCompletion(program, legacy))
+	default:
+		fmt.Printf("Error: unsupported shell %q\n%s\n", args[0], completionUsage)
+		return 1
+	}
+	return 0
+}
+
+// completionFuncName turns the program name into a valid shell function name
+func completionFuncName(program string) string {
+	return "_" + strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, program) + "_completion"
+}
+
+// spellings joins how each flag is written on the command line
+func spellings(flags []completionFlag) string {
+	var all []string
+	for _, f := range flags {
+		all = append(all, f.spelling())
+	}
+	return strings.Join(all, " ")
+}
+
+func bashCompletion(program string, legacy []completionFlag) string {
+	var sb strings.Builder
+	fn := completionFuncName(program)
+
+	// A flag name takes the same kind of value in every command
+	kinds := make(map[string]string)
+	addKinds := func(flags []completionFlag) {
+		for _, f := range flags {
+			switch {
+			case f.pathKind != "":
+				kinds[f.name] = f.pathKind
+			case f.takesValue:
+				kinds[f.name] = "value"
+			}
+		}
+	}
+	addKinds(legacy)
+	var names []string
+	for _, sub := range subcommands {
+		names = append(names, sub.name)
+		addKinds(sub.completionFlags())
+	}
+
+	fmt.Fprintf(&sb, "# bash completion for %s\n", program)
+	fmt.Fprintf(&sb, "%s() {\n", fn)
+	sb.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+	sb.WriteString("    if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then\n")
+	fmt.Fprintf(&sb, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+	sb.WriteString("        return\n    fi\n\n")
+
+	fmt.Fprintf(&sb, "    local flags=\"%s\"\n", spellings(legacy))
+	sb.WriteString("    case \"${COMP_WORDS[1]}\" in\n")
+	for _, sub := range subcommands {
+		fmt.Fprintf(&sb, "    %s)\n", sub.name)
+		if len(sub.commands) > 0 {
+			fmt.Fprintf(&sb, "        [[ $COMP_CWORD -eq 2 ]] && COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(sub.commands, " "))
+			sb.WriteString("        return ;;\n")
+			continue
+		}
+		fmt.Fprintf(&sb, "        flags=\"%s\" ;;\n", spellings(sub.completionFlags()))
+	}
+	sb.WriteString("    esac\n\n")
+
+	// Go accepts both -name and --name, so match either spelling of the previous word
+	var flagNames []string
+	for name := range kinds {
+		flagNames = append(flagNames, name)
+	}
+	sort.Strings(flagNames)
+	patterns := make(map[string][]string)
+	for _, name := range flagNames {
+		patterns[kinds[name]] = append(patterns[kinds[name]], "-"+name, "--"+name)
+	}
+	actions := []struct{ kind, action string }{
+		{"file", "COMPREPLY=($(compgen -f -- \"$cur\"))"},
+		{"dir", "COMPREPLY=($(compgen -d -- \"$cur\"))"},
+		{"value", "COMPREPLY=()"},
+	}
+	sb.WriteString("    case \"$prev\" in\n")
+	for _, a := range actions {
+		if len(patterns[a.kind]) > 0 {
+			fmt.Fprintf(&sb, "    %s)\n        %s\n        return ;;\n", strings.Join(patterns[a.kind], "|"), a.action)
+		}
+	}
+	sb.WriteString("    esac\n\n")
+
+	sb.WriteString("    if [[ $cur == -* ]]; then\n")
+	sb.WriteString("        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	sb.WriteString("    fi\n}\n")
+	fmt.Fprintf(&sb, "complete -o default -F %s %s\n", fn, program)
+	return sb.String()
+}
+
+// zshQuote escapes text for a single-quoted _arguments spec description
+func zshQuote(s string) string {
+	s = strings.ReplaceAll(s, "'", `'\''`)
+	s = strings.ReplaceAll(s, "[", `\[`)
+	s = strings.ReplaceAll(s, "]", `\]`)
+	return s
+}
+
+// zshArguments renders an _arguments call for flags followed by positional arguments of kind arg
+func zshArguments(flags []completionFlag, arg, indent string) string {
+	var sb strings.Builder
+	sb.WriteString(indent + "_arguments \\\n")
+	for _, f := range flags {
+		prefix := ""
+		if f.repeatable {
+			prefix = "*"
+		}
+		spec := fmt.Sprintf("%s%s[%s]", prefix, f.spelling(), zshQuote(f.usage))
+		if f.takesValue {
+			switch f.pathKind {
+			case "file":
+				spec += ":file:_files"
+			case "dir":
+				spec += ":directory:_files -/"
+			default:
+				spec += ":value: "
+			}
+		}
+		fmt.Fprintf(&sb, "%s    '%s' \\\n", indent, spec)
+	}
+	switch arg {
+	case "file":
+		fmt.Fprintf(&sb, "%s    '*:file:_files'\n", indent)
+	case "dir":
+		fmt.Fprintf(&sb, "%s    '*:directory:_files -/'\n", indent)
+	default:
+		fmt.Fprintf(&sb, "%s    '*:URL:_urls'\n", indent)
+	}
+	return sb.String()
+}
+
+func zshCompletion(program string, legacy []completionFlag) string {
+	var sb strings.Builder
+	fn := completionFuncName(program)
+
+	fmt.Fprintf(&sb, "#compdef %s\n\n", program)
+	fmt.Fprintf(&sb, "%s() {\n", fn)
+	sb.WriteString("    if (( CURRENT == 2 )) && [[ $words[CURRENT] != -* ]]; then\n")
+	sb.WriteString("        local -a commands=(\n")
+	for _, sub := range subcommands {
+		fmt.Fprintf(&sb, "            '%s:%s'\n", sub.name, strings.ReplaceAll(sub.description, "'", `'\''`))
+	}
+	sb.WriteString("        )\n        _describe -t commands command commands\n        _urls\n        return\n    fi\n\n")
+	sb.WriteString("    case $words[2] in\n")
+	for _, sub := range subcommands {
+		fmt.Fprintf(&sb, "    %s)\n", sub.name)
+		if len(sub.commands) > 0 {
+			fmt.Fprintf(&sb, "        (( CURRENT == 3 )) && compadd %s\n        return ;;\n", strings.Join(sub.commands, " "))
+			continue
+		}
+		// Drop the subcommand so _arguments parses its own flags
+		sb.WriteString("        shift words\n        (( CURRENT-- ))\n")
+		sb.WriteString(zshArguments(sub.completionFlags(), sub.arg, "        "))
+		sb.WriteString("        return ;;\n")
+	}
+	sb.WriteString("    esac\n\n")
+	sb.WriteString(zshArguments(legacy, "url", "    "))
+	sb.WriteString("}\n\n")
+	// Works both autoloaded from $fpath and sourced directly
+	fmt.Fprintf(&sb, "if [[ $zsh_eval_context[-1] == loadautofunc ]]; then\n    %s \"$@\"\nelse\n    compdef %s %s\nfi\n", fn, fn, program)
+	return sb.String()
+}
+
+// fishQuote escapes text for a single-quoted fish string
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "'", `\'`)
+}
+
+// fishFlags renders one complete line per flag, active while condition holds
+func fishFlags(program, condition string, flags []completionFlag) string {
+	var sb strings.Builder
+	for _, f := range flags {
+		option := "-l " + f.name
+		if len(f.name) == 1 {
+			option = "-o " + f.name
+		}
+		line := fmt.Sprintf("complete -c %s -n '%s' %s", program, condition, option)
+		if f.takesValue {
+			line += " -r"
+			switch f.pathKind {
+			case "file":
+				line += " -F"
+			case "dir":
+				line += " -a '(__fish_complete_directories)'"
+			default:
+				line += " -f"
+			}
+		}
+		fmt.Fprintf(&sb, "%s -d '%s'\n", line, fishQuote(f.usage))
+	}
+	return sb.String()
+}
+
+func fishCompletion(program string, legacy []completionFlag) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# fish completion for %s\n", program)
+	fmt.Fprintf(&sb, "complete -c %s -e\n", program)
+	var names []string
+	for _, sub := range subcommands {
+		names = append(names, sub.name)
+		fmt.Fprintf(&sb, "complete -c %s -n '__fish_use_subcommand' -f -a %s -d '%s'\n", program, sub.name, fishQuote(sub.description))
+	}
+	for _, sub := range subcommands {
+		condition := "__fish_seen_subcommand_from " + sub.name
+		if len(sub.commands) > 0 {
+			fmt.Fprintf(&sb, "complete -c %s -n '%s' -f -a '%s'\n", program, condition, strings.Join(sub.commands, " "))
+		}
+		sb.WriteString(fishFlags(program, condition, sub.completionFlags()))
+	}
+
+	sb.WriteString(fishFlags(program, "not __fish_seen_subcommand_from "+strings.Join(names, " "), legacy))
+	return sb.String()
+}
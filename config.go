@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configPath returns the config file to read: --config, then $WGET_CONFIG, then the user config dir
+func configPath(explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if env := os.Getenv("WGET_CONFIG"); env != "" {
+		return env
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-wget", "config")
+}
+
+// configEntry is one "flag = value" line of the config file
+type configEntry struct {
+	key   string
+	value string
+	line  int
+}
+
+// parseConfig reads the config file into its top-level defaults and named [profile] sections
+func parseConfig(path string) ([]configEntry, map[string][]configEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var defaults []configEntry
+	profiles := make(map[string][]configEntry)
+	section := ""
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			section = strings.TrimSpace(strings.TrimPrefix(section, "profile "))
+			if _, ok := profiles[section]; !ok {
+				profiles[section] = nil
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("%s:%d: expected \"flag = value\"", path, lineNo)
+		}
+		entry := configEntry{
+			key:   strings.TrimLeft(strings.TrimSpace(key), "-"),
+			value: strings.Trim(strings.TrimSpace(value), `"`),
+			line:  lineNo,
+		}
+		if section == "" {
+			defaults = append(defaults, entry)
+		} else {
+			profiles[section] = append(profiles[section], entry)
+		}
+	}
+	return defaults, profiles, scanner.Err()
+}
+
+// expandHome replaces a leading ~/ with the user's home directory
+func expandHome(value string) string {
+	if strings.HasPrefix(value, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, value[2:])
+		}
+	}
+	return value
+}
+
+// applyConfig fills flags not given on the command line from the config file:
+// top-level defaults first, then the selected profile on top of them
+func applyConfig(fs *flag.FlagSet, opts *cliOptions) error {
+	path := configPath(opts.config)
+	if path == "" {
+		return nil
+	}
+	defaults, profiles, err := parseConfig(path)
+	if err != nil {
+		if os.IsNotExist(err) && opts.config == "" && opts.profile == "" {
+			return nil // No config file is fine unless one was asked for
+		}
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	entries := defaults
+	if opts.profile != "" {
+		profile, ok := profiles[opts.profile]
+		if !ok {
+			return fmt.Errorf("profile %q not found in %s", opts.profile, path)
+		}
+		entries = append(entries, profile...)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	known := legacyFlagSet()
+
+	// Later entries win, except for repeatable flags which collect every value
+	values := make(map[string][]string)
+	var order []string
+	for _, e := range entries {
+		if e.key == "config" || e.key == "profile" {
+			return fmt.Errorf("%s:%d: %s cannot be set in the config file", path, e.line, e.key)
+		}
+		if known.Lookup(e.key) == nil {
+			return fmt.Errorf("%s:%d: unknown flag %q", path, e.line, e.key)
+		}
+		if _, seen := values[e.key]; !seen {
+			order = append(order, e.key)
+		}
+		if _, repeatable := known.Lookup(e.key).Value.(*stringList); repeatable {
+			values[e.key] = append(values[e.key], e.value)
+		} else {
+			values[e.key] = []string{e.value}
+		}
+	}
+
+	for _, key := range order {
+		// Flags the command doesn't accept (e.g. mirror options for get) are skipped
+		if explicit[key] || fs.Lookup(key) == nil {
+			continue
+		}
+		for _, value := range values[key] {
+			if err := fs.Set(key, expandHome(value)); err != nil {
+				return fmt.Errorf("config %s: invalid value for %s: %w", path, key, err)
+			}
+		}
+	}
+	return nil
+}
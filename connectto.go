@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// connectTarget sends connections for host:port to toHost:toPort, for --connect-to.
+// An empty host or port matches any, and an empty toHost or toPort keeps the original.
+type connectTarget struct {
+	host, port     string
+	toHost, toPort string
+}
+
+// parseConnectTo parses HOST:PORT:CONNECT-HOST:CONNECT-PORT specs; IPv6 addresses go in brackets
+func parseConnectTo(specs []string) ([]connectTarget, error) {
+	var targets []connectTarget
+	for _, spec := range specs {
+		fields := splitHostFields(spec)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid --connect-to '%s' (want HOST:PORT:CONNECT-HOST:CONNECT-PORT)", spec)
+		}
+		targets = append(targets, connectTarget{host: fields[0], port: fields[1], toHost: fields[2], toPort: fields[3]})
+	}
+	return targets, nil
+}
+
+// splitHostFields splits s at the colons outside brackets, dropping the brackets
+func splitHostFields(s string) []string {
+	var fields []string
+	var field strings.Builder
+	bracketed := false
+	for _, r := range s {
+		switch {
+		case r == '[':
+			bracketed = true
+		case r == ']':
+			bracketed = false
+		case r == ':' && !bracketed:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// redirect returns where a connection to addr goes, and whether a target matched it
+func (t connectTarget) redirect(addr string) (string, bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, false
+	}
+	if (t.host != "" && !strings.EqualFold(t.host, host)) || (t.port != "" && t.port != port) {
+		return addr, false
+	}
+	if t.toHost != "" {
+		host = t.toHost
+	}
+	if t.toPort != "" {
+		port = t.toPort
+	}
+	return net.JoinHostPort(host, port), true
+}
+
+// connectTo dials the first matching target instead of the URL's address. The URL still
+// supplies the Host header and the TLS server name, so certificates are checked as usual.
+func connectTo(transport *http.Transport, targets []connectTarget) {
+	dial := transport.DialContext // Set up by tuneTransport
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		for _, target := range targets {
+			if to, ok := target.redirect(addr); ok {
+				return dial(ctx, network, to)
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// presentHost makes the transport send name as the TLS server name (SNI) and check the
+// certificate against it, whatever host the URL connects to. hostTransport sets the Host header.
+func presentHost(transport *http.Transport, name string) {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.ServerName = name
+}
+
+// hostTransport sends every request with the Host header of --host
+type hostTransport struct {
+	base http.RoundTripper
+	host string
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = t.host
+	return t.base.RoundTrip(req)
+}
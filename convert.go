@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// convertLinks rewrites the links of every HTML page saved under dir for local browsing, as
+// mirroring does unless --no-convert-links is given. siteURL is the address the tree was
+// mirrored from; pages are taken to be in the default path/... layout under dir. Running it
+// again over converted pages changes nothing. It returns the number of pages rewritten.
+func convertLinks(dir, siteURL string, backup bool) (int, error) {
+	base, err := url.Parse(siteURL)
+	if err != nil || base.Host == "" {
+		return 0, fmt.Errorf("invalid site URL: %s", siteURL)
+	}
+
+	converted := 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		pageURL := base.ResolveReference(&url.URL{Path: "/" + filepath.ToSlash(rel)})
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rewritten, err := rewriteHTML(string(content), pageURL.String(), siteURL, nil, nil, nil)
+		if err != nil {
+			fmt.Printf("Skipping %s: %v\n", path, err)
+			return nil
+		}
+		if rewritten == string(content) {
+			return nil
+		}
+		// An existing .orig is the server's copy from an earlier run; don't replace it with a converted one
+		if backup && !fileExists(path+".orig") {
+			if err := os.WriteFile(path+".orig", content, 0o644); err != nil {
+				return fmt.Errorf("failed to save original of '%s': %w", path, err)
+			}
+		}
+		if err := os.WriteFile(path, []byte(rewritten), 0o644); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", path, err)
+		}
+		converted++
+		return nil
+	})
+	return converted, err
+}
+
+const convertUsage = `Usage:
+  ./wget convert-links [-K] DIR [URL]    Rewrite the links of a mirror saved with --no-convert-links
+
+URL is where DIR was mirrored from (default: http://<name of DIR>/).
+
+Options:`
+
+// newConvertFlagSet returns the flags of the convert-links subcommand
+func newConvertFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("convert-links", flag.ExitOnError)
+	fs.Bool("K", false, "Keep the original of each rewritten page as FILE.orig")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), convertUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// RunConvertLinksCommand implements the `convert-links` subcommand and returns the process exit code
+func RunConvertLinksCommand(args []string) int {
+	fs := newConvertFlagSet()
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return 1
+	}
+
+	dir := fs.Arg(0)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("Error: '%s' is not a directory\n", dir)
+		return 1
+	}
+	siteURL := "http://" + filepath.Base(filepath.Clean(dir)) + "/"
+	if fs.NArg() == 2 {
+		siteURL = fs.Arg(1)
+	}
+
+	converted, err := convertLinks(dir, siteURL, fs.Lookup("K").Value.String() == "true")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Converted links in %d pages.\n", converted)
+	return 0
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// persistentJar is a cookie jar that also remembers every cookie it was given,
+// so the session can be written back out in Netscape cookies.txt format
+type persistentJar struct {
+	*cookiejar.Jar
+	mutex   sync.Mutex
+	cookies map[string]storedCookie // Keyed by domain, path and name
+}
+
+// storedCookie is a cookie as accepted by the jar
+type storedCookie struct {
+	*http.Cookie
+	hostOnly bool // Set without a Domain attribute: sent to the exact host only
+}
+
+func newPersistentJar() *persistentJar {
+	jar, _ := cookiejar.New(nil)
+	return &persistentJar{Jar: jar, cookies: make(map[string]storedCookie)}
+}
+
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	for _, c := range cookies {
+		stored := *c
+		hostOnly := stored.Domain == ""
+		if hostOnly {
+			stored.Domain = u.Hostname() // Host-only cookie
+		}
+		if stored.Path == "" {
+			stored.Path = "/"
+		}
+		key := stored.Domain + "\t" + stored.Path + "\t" + stored.Name
+		if stored.MaxAge < 0 || (!stored.Expires.IsZero() && stored.Expires.Before(time.Now())) {
+			delete(j.cookies, key)
+			continue
+		}
+		if stored.MaxAge > 0 {
+			stored.Expires = time.Now().Add(time.Duration(stored.MaxAge) * time.Second)
+		}
+		j.cookies[key] = storedCookie{Cookie: &stored, hostOnly: hostOnly}
+	}
+}
+
+// Load reads a Netscape cookies.txt file (as written by browsers' export tools, curl and wget)
+func (j *persistentJar) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open cookie file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+
+		domain, subdomains, path, secure := fields[0], fields[1] == "TRUE", fields[2], fields[3] == "TRUE"
+		cookie := &http.Cookie{Name: fields[5], Value: fields[6], Path: path, Secure: secure, HttpOnly: httpOnly}
+		if expiry, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+			if cookie.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+		host := strings.TrimPrefix(domain, ".")
+		if subdomains {
+			cookie.Domain = host
+		}
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		j.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: path}, []*http.Cookie{cookie})
+	}
+	return scanner.Err()
+}
+
+// Save writes every live cookie, session cookies included, in Netscape cookies.txt format
+func (j *persistentJar) Save(path string) error {
+	j.mutex.Lock()
+	var keys []string
+	for key := range j.cookies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString("# Netscape HTTP Cookie File\n# Written by go-wget; session cookies have an expiry of 0\n\n")
+	for _, key := range keys {
+		c := j.cookies[key]
+		if !c.Expires.IsZero() && c.Expires.Before(time.Now()) {
+			continue
+		}
+		domain, subdomains := c.Domain, "FALSE"
+		if !c.hostOnly {
+			domain, subdomains = "."+strings.TrimPrefix(domain, "."), "TRUE"
+		}
+		secure, expiry := "FALSE", int64(0)
+		if c.Secure {
+			secure = "TRUE"
+		}
+		if !c.Expires.IsZero() {
+			expiry = c.Expires.Unix()
+		}
+		if c.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, subdomains, c.Path, secure, expiry, c.Name, c.Value)
+	}
+	j.mutex.Unlock()
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to save cookies: %w", err)
+	}
+	return nil
+}
+
+// Login submits loginData (a form-encoded body, or @file to read it from a file) to loginURL
+// so the session cookies it sets are sent with every later request
+func (w *WgetClone) Login(loginURL, loginData string) error {
+	if file, ok := strings.CutPrefix(loginData, "@"); ok {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read login data: %w", err)
+		}
+		loginData = strings.TrimSpace(string(data))
+	}
+
+	req, err := http.NewRequestWithContext(w.ctx, "POST", loginURL, strings.NewReader(loginData))
+	if err != nil {
+		return fmt.Errorf("invalid login URL: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("login request failed: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login failed: HTTP %d", resp.StatusCode)
+	}
+
+	loggedIn, _ := url.Parse(loginURL)
+	fmt.Printf("Logged in at %s (%d cookies in session)\n", loginURL, len(w.client.Jar.Cookies(loggedIn)))
+	return nil
+}
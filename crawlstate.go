@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// crawlStateName is the file in a mirror directory that holds a crawl cut short by --crawl-timeout
+const crawlStateName = ".wget-crawl-state"
+
+// crawlState is what a mirror needs to carry on where a run stopped: the URLs still
+// queued and every URL already seen, so finished pages aren't fetched again.
+type crawlState struct {
+	URL     string         `json:"url"`
+	SavedAt time.Time      `json:"saved_at"`
+	Queue   []savedItem    `json:"queue"`
+	Seen    []string       `json:"seen,omitempty"`
+	Bloom   *bloomSnapshot `json:"bloom,omitempty"` // Instead of Seen with --visited-bloom
+}
+
+// savedItem is a crawlItem in a state file
+type savedItem struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// saveCrawlState writes what is left of frontier's crawl of urlStr into dir
+func saveCrawlState(dir, urlStr string, frontier *crawlFrontier) (string, error) {
+	state := crawlState{URL: urlStr, SavedAt: time.Now()}
+	for _, item := range frontier.remaining() {
+		state.Queue = append(state.Queue, savedItem{URL: item.url, Depth: item.depth})
+	}
+	frontier.mutex.Lock()
+	switch seen := frontier.queued.(type) {
+	case exactSet:
+		for urlStr := range seen {
+			state.Seen = append(state.Seen, urlStr)
+		}
+	case *bloomSet:
+		state.Bloom = seen.snapshot()
+	}
+	frontier.mutex.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	statePath := filepath.Join(dir, crawlStateName)
+	// Write then rename, so a crash can't leave half a state file behind
+	if err := os.WriteFile(statePath+".tmp", data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to save crawl state: %w", err)
+	}
+	if err := os.Rename(statePath+".tmp", statePath); err != nil {
+		return "", fmt.Errorf("failed to save crawl state: %w", err)
+	}
+	return statePath, nil
+}
+
+// resumeCrawlState loads the state a stopped crawl of urlStr left in dir into frontier and
+// returns how many URLs it queued. A state left by another URL is ignored.
+func resumeCrawlState(dir, urlStr string, frontier *crawlFrontier) (int, error) {
+	statePath := filepath.Join(dir, crawlStateName)
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read crawl state: %w", err)
+	}
+	var state crawlState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return 0, fmt.Errorf("invalid crawl state '%s': %w", statePath, err)
+	}
+	if state.URL != urlStr {
+		fmt.Printf("Ignoring crawl state '%s' left by a mirror of %s\n", statePath, state.URL)
+		return 0, nil
+	}
+
+	frontier.mutex.Lock()
+	switch seen := frontier.queued.(type) {
+	case exactSet:
+		for _, urlStr := range state.Seen {
+			seen.add(urlStr)
+		}
+		if state.Bloom != nil {
+			err = fmt.Errorf("crawl state was saved with --visited-bloom")
+		}
+	case *bloomSet:
+		if state.Bloom == nil {
+			err = fmt.Errorf("crawl state was saved without --visited-bloom")
+		} else {
+			err = seen.restore(state.Bloom)
+		}
+	}
+	frontier.mutex.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("can't resume from '%s': %w (remove it to start over)", statePath, err)
+	}
+
+	items := make([]crawlItem, len(state.Queue))
+	for i, saved := range state.Queue {
+		items[i] = crawlItem{url: saved.URL, depth: saved.Depth}
+	}
+	frontier.requeue(items)
+	fmt.Printf("Resuming crawl stopped at %s: %d URLs still queued\n", state.SavedAt.Format("2006-01-02 15:04:05"), len(items))
+	return len(items), nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// isDataURI reports whether ref is an inline data: URI
+func isDataURI(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	return len(ref) >= 5 && strings.EqualFold(ref[:5], "data:")
+}
+
+// parseDataURI decodes an RFC 2397 data: URI into its media type and payload
+func parseDataURI(ref string) (string, []byte, error) {
+	ref = strings.TrimSpace(ref)
+	if !isDataURI(ref) {
+		return "", nil, fmt.Errorf("not a data: URI")
+	}
+	header, payload, ok := strings.Cut(ref[5:], ",")
+	if !ok {
+		return "", nil, fmt.Errorf("malformed data: URI")
+	}
+
+	isBase64 := false
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		isBase64 = true
+		header = header[:len(header)-len(";base64")]
+	}
+	mediaType := header
+	if mediaType == "" || strings.HasPrefix(mediaType, ";") {
+		mediaType = "text/plain" + mediaType
+	}
+
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed data: URI: %w", err)
+	}
+	if !isBase64 {
+		return mediaType, []byte(decoded), nil
+	}
+
+	// Whitespace inside base64 payloads is common in hand-written CSS
+	decoded = strings.Join(strings.Fields(decoded), "")
+	data, err := base64.StdEncoding.DecodeString(decoded)
+	if err != nil {
+		if data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(decoded, "=")); err != nil {
+			return "", nil, fmt.Errorf("invalid base64 in data: URI: %w", err)
+		}
+	}
+	return mediaType, data, nil
+}
+
+// dataURIExtension picks a file extension for a decoded data: URI
+func dataURIExtension(mediaType string) string {
+	base, _, _ := mime.ParseMediaType(mediaType)
+	switch base {
+	case "image/svg+xml":
+		return ".svg"
+	case "image/jpeg":
+		return ".jpg"
+	case "text/plain":
+		return ".txt"
+	}
+	if exts, err := mime.ExtensionsByType(base); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// materializeDataURI writes the payload of ref under the mirror's data/ directory and returns
+// its path relative to the page at pagePath. Files are named by content hash so duplicates share one file.
+func (w *WgetClone) materializeDataURI(ref, pagePath string) (string, error) {
+	mediaType, data, err := parseDataURI(ref)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	target := filepath.Join(w.mirrorBaseDir, "data", hex.EncodeToString(sum[:8])+dataURIExtension(mediaType))
+
+	if !fileExists(target) {
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return "", fmt.Errorf("failed to create directory '%s': %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			return "", fmt.Errorf("failed to write '%s': %w", target, err)
+		}
+	}
+	w.recordWritten(target)
+
+	rel, err := filepath.Rel(filepath.Dir(pagePath), target)
+	if err != nil {
+		return "", err
+	}
+	return linkPath(rel), nil
+}
+
+// extractDataURIsCSS replaces url(data:...) references in CSS with files saved next to the mirror
+func (w *WgetClone) extractDataURIsCSS(css, pagePath string) string {
+	return cssURLPattern.ReplaceAllStringFunc(css, func(match string) string {
+		ref := strings.TrimSpace(cssURLPattern.FindStringSubmatch(match)[1])
+		if !isDataURI(ref) {
+			return match
+		}
+		rel, err := w.materializeDataURI(ref, pagePath)
+		if err != nil {
+			fmt.Printf("Warning: leaving data: URI inline in %s: %v\n", pagePath, err)
+			return match
+		}
+		return "url(" + rel + ")"
+	})
+}
+
+// extractDataURIsHTML replaces data: URIs in src/href attributes and inline CSS with saved files
+func (w *WgetClone) extractDataURIsHTML(content, pagePath string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, a := range n.Attr {
+				switch {
+				case (a.Key == "src" || a.Key == "href") && isDataURI(a.Val):
+					rel, err := w.materializeDataURI(a.Val, pagePath)
+					if err != nil {
+						fmt.Printf("Warning: leaving data: URI inline in %s: %v\n", pagePath, err)
+						continue
+					}
+					n.Attr[i].Val = rel
+				case a.Key == "style":
+					n.Attr[i].Val = w.extractDataURIsCSS(a.Val, pagePath)
+				}
+			}
+			if n.Data == "style" && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				n.FirstChild.Data = w.extractDataURIsCSS(n.FirstChild.Data, pagePath)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return "", fmt.Errorf("failed to render modified HTML: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// errTransferDeadline marks a transfer cut off by --max-download-time
+	errTransferDeadline = errors.New("transfer exceeded --max-download-time")
+	// errTransferStalled marks a transfer that received nothing for --read-timeout
+	errTransferStalled = errors.New("no data received within --read-timeout")
+)
+
+// deadlineTransport gives every request, from sending it to closing its response body, at
+// most budget of wall-clock time, so a streaming endpoint or a tarpit that trickles bytes
+// can't hold a worker forever.
+type deadlineTransport struct {
+	base   http.RoundTripper
+	budget time.Duration
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.budget)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, t.explain(req.Context(), ctx, err)
+	}
+	resp.Body = &deadlineBody{ReadCloser: resp.Body, transport: t, parent: req.Context(), ctx: ctx, cancel: cancel}
+	return resp, nil
+}
+
+// explain turns an error caused by the budget running out into errTransferDeadline
+func (t *deadlineTransport) explain(parent, ctx context.Context, err error) error {
+	if parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w (%s)", errTransferDeadline, t.budget)
+	}
+	return err
+}
+
+// deadlineBody ends the request's budget when it is closed
+type deadlineBody struct {
+	io.ReadCloser
+	transport   *deadlineTransport
+	parent, ctx context.Context
+	cancel      context.CancelFunc
+	once        sync.Once
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.transport.explain(b.parent, b.ctx, err)
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.cancel)
+	return err
+}
+
+// stallTransport fails a response body that goes idle for timeout, however long the transfer
+// has been running, while slow bodies that keep delivering are left alone. A stalled download
+// reconnects from where it stopped when the server supports ranges (see resumableBody).
+type stallTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &stallBody{ReadCloser: resp.Body, timeout: t.timeout, cancel: cancel}
+	body.timer = time.AfterFunc(t.timeout, func() {
+		body.stalled.Store(true)
+		cancel()
+	})
+	resp.Body = body
+	return resp, nil
+}
+
+// stallBody cancels its request once no bytes were read from it for timeout
+type stallBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	stalled atomic.Bool
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.stalled.Load() {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && err != io.EOF && b.stalled.Load() {
+		err = fmt.Errorf("%w (%s)", errTransferStalled, b.timeout)
+	}
+	return n, err
+}
+
+func (b *stallBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.ReadCloser.Close()
+}
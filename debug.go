@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// debugBodyLimit is how much of each request and response body -d shows
+const debugBodyLimit = 512
+
+// debugTransport logs every request line and headers, and every response status and headers,
+// with the start of their bodies, to stderr. Credentials in Authorization headers are masked.
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dump, err := httputil.DumpRequestOut(req, false)
+	if err != nil {
+		dump = []byte(req.Method + " " + req.URL.String() + "\r\n")
+	}
+	debugDump("request", "", dump, requestBodyPreview(req))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		debugDump("response", time.Since(start).Round(time.Millisecond).String(), []byte(fmt.Sprintf("%s %s failed: %v", req.Method, req.URL, err)), nil)
+		return resp, err
+	}
+
+	dump, _ = httputil.DumpResponse(resp, false)
+	reader := bufio.NewReaderSize(resp.Body, debugBodyLimit)
+	preview, _ := reader.Peek(debugBodyLimit)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+	debugDump("response", time.Since(start).Round(time.Millisecond).String(), dump, preview)
+	return resp, nil
+}
+
+// requestBodyPreview returns the start of a replayable request body without consuming it
+func requestBodyPreview(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	preview, _ := io.ReadAll(io.LimitReader(body, debugBodyLimit))
+	return preview
+}
+
+// debugDump prints a header dump and body preview between wget-style markers, noting how long a response took
+func debugDump(kind, elapsed string, dump, body []byte) {
+	var sb strings.Builder
+	if elapsed != "" {
+		fmt.Fprintf(&sb, "---%s begin--- (%s)\n", kind, elapsed)
+	} else {
+		fmt.Fprintf(&sb, "---%s begin---\n", kind)
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(dump), "\r\n"), "\r\n") {
+		sb.WriteString(maskCredentials(line) + "\n")
+	}
+	if len(body) > 0 {
+		text, truncated := body, len(body) == debugBodyLimit
+		for i := 0; truncated && i < utf8.UTFMax-1 && !utf8.Valid(text); i++ {
+			text = text[:len(text)-1] // Don't mistake a character cut at the limit for binary data
+		}
+		if utf8.Valid(text) && !bytes.ContainsRune(text, 0) {
+			sb.WriteString("\n" + strings.TrimRight(string(text), "\r\n"))
+			if truncated {
+				sb.WriteString("\n[body truncated]")
+			}
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString("\n[binary body not shown]\n")
+		}
+	}
+	fmt.Fprintf(&sb, "---%s end---\n", kind)
+
+	stdoutMutex.Lock()
+	fmt.Fprint(os.Stderr, sb.String())
+	stdoutMutex.Unlock()
+}
+
+// maskCredentials hides the secret part of Authorization and Proxy-Authorization header lines
+func maskCredentials(line string) string {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok || (!strings.EqualFold(name, "Authorization") && !strings.EqualFold(name, "Proxy-Authorization")) {
+		return line
+	}
+	scheme, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+	return name + ": " + scheme + " <hidden>"
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// decompressor unpacks downloads whose name ends in ext, for --auto-decompress. The saved
+// file loses ext, or gets replace instead (.tgz becomes .tar).
+type decompressor struct {
+	ext     string
+	replace string
+	open    func(io.Reader) (io.ReadCloser, error)
+}
+
+func openGzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func openBzip2(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(bzip2.NewReader(r)), nil
+}
+
+func openXz(r io.Reader) (io.ReadCloser, error) {
+	reader, err := xz.NewReader(r)
+	return io.NopCloser(reader), err
+}
+
+func openZstd(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+var decompressors = []decompressor{
+	{".gz", "", openGzip},
+	{".tgz", ".tar", openGzip},
+	{".bz2", "", openBzip2},
+	{".tbz2", ".tar", openBzip2},
+	{".xz", "", openXz},
+	{".txz", ".tar", openXz},
+	{".zst", "", openZstd},
+	{".tzst", ".tar", openZstd},
+}
+
+// decompressorFor returns how to unpack the download of urlStr, or nil if it isn't one
+// --auto-decompress handles (or that wasn't given)
+func (w *WgetClone) decompressorFor(urlStr string, isMirroring bool) *decompressor {
+	if !w.autoDecompress || isMirroring {
+		return nil
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	for i := range decompressors {
+		if decompressors[i].ext == ext {
+			return &decompressors[i]
+		}
+	}
+	return nil
+}
+
+// target returns the name the unpacked file is saved as. A name without the compression
+// extension, as -O may give, is kept.
+func (d *decompressor) target(name string) string {
+	if !strings.EqualFold(filepath.Ext(name), d.ext) {
+		return name
+	}
+	return name[:len(name)-len(d.ext)] + d.replace
+}
+
+// decompressWriter unpacks what is written to it into out as it arrives
+type decompressWriter struct {
+	pipe *io.PipeWriter
+	done chan error
+	out  int64 // Unpacked bytes written to out
+}
+
+func newDecompressWriter(out io.Writer, open func(io.Reader) (io.ReadCloser, error)) *decompressWriter {
+	reader, writer := io.Pipe()
+	d := &decompressWriter{pipe: writer, done: make(chan error, 1)}
+	go func() {
+		unpacked, err := open(reader)
+		if err == nil {
+			d.out, err = io.Copy(out, unpacked)
+			unpacked.Close()
+		}
+		if err == nil {
+			// Trailing bytes after the compressed stream are as wrong as a truncated one
+			if n, _ := io.Copy(io.Discard, reader); n > 0 {
+				err = fmt.Errorf("%d bytes of trailing data", n)
+			}
+		}
+		reader.CloseWithError(err) // Unblocks the writer if unpacking stopped early
+		d.done <- err
+	}()
+	return d
+}
+
+func (d *decompressWriter) Write(p []byte) (int, error) {
+	return d.pipe.Write(p)
+}
+
+// Close ends the compressed stream and waits for the rest to be unpacked
+func (d *decompressWriter) Close() error {
+	d.pipe.Close()
+	return <-d.done
+}
+
+// downloadDecompressed downloads urlStr, unpacking it with dec into finalOutputPath
+func (w *WgetClone) downloadDecompressed(urlStr, finalOutputPath string, rateLimit int64, dec *decompressor) error {
+	fmt.Printf("Starting download at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	if err := os.MkdirAll(filepath.Dir(finalOutputPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", filepath.Dir(finalOutputPath), err)
+	}
+	partPath := finalOutputPath + ".part"
+	file, err := os.Create(partPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%s': %w", partPath, err)
+	}
+
+	unpack := newDecompressWriter(file, dec.open)
+	written, err := w.appendURL(unpack, filepath.Base(finalOutputPath), urlStr, rateLimit)
+	if unpackErr := unpack.Close(); err == nil && unpackErr != nil {
+		err = fmt.Errorf("failed to decompress %s: %w", urlStr, unpackErr)
+	}
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write file '%s': %w", partPath, closeErr)
+	}
+	if err != nil {
+		os.Remove(partPath)
+		if w.IsInterrupted() {
+			return fmt.Errorf("download interrupted")
+		}
+		return err
+	}
+	if err := os.Rename(partPath, finalOutputPath); err != nil {
+		return fmt.Errorf("failed to move '%s' into place: %w", partPath, err)
+	}
+	w.recordWritten(finalOutputPath)
+	if w.deleteAfter {
+		if err := os.Remove(finalOutputPath); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", finalOutputPath, err)
+		}
+	}
+
+	fmt.Printf("%s %s\n", colorize(colorGreen, "Downloaded successfully:"), urlStr)
+	fmt.Printf("Decompressed into %s\n", finalOutputPath)
+	fmt.Printf("Finished at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Total downloaded: %s (%s decompressed)\n", formatBytes(written), formatBytes(unpack.out))
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	dnsPrefetchTTL     = time.Minute // How long a prefetched answer is used; it's looked up again after
+	dnsPrefetchTimeout = 10 * time.Second
+	dnsPrefetchWorkers = 8 // Lookups running at once
+)
+
+// dnsPrefetcher resolves the hosts of URLs a run is about to fetch in the background, so the
+// first connection to each host finds its addresses ready instead of waiting for DNS in turn.
+// Hosts reached through a proxy or Tor aren't looked up, as their names must not leak to the
+// local resolver. A nil *dnsPrefetcher prefetches nothing.
+type dnsPrefetcher struct {
+	ctx       context.Context
+	transport *http.Transport // For its Proxy, as set up when the prefetch happens
+	resolve   func(ctx context.Context, host string) ([]netip.Addr, error)
+	workers   chan struct{}
+
+	mutex   sync.Mutex
+	answers map[string]*dnsAnswer
+}
+
+// dnsAnswer is one prefetched lookup; done is closed once ips and err are set
+type dnsAnswer struct {
+	done    chan struct{}
+	ips     []netip.Addr
+	err     error
+	expires time.Time
+}
+
+func newDNSPrefetcher(ctx context.Context, transport *http.Transport, dialer *happyDialer) *dnsPrefetcher {
+	return &dnsPrefetcher{ctx: ctx, transport: transport, resolve: dialer.resolve,
+		workers: make(chan struct{}, dnsPrefetchWorkers), answers: make(map[string]*dnsAnswer)}
+}
+
+// prefetch starts resolving the host of urlStr unless it was lately or needn't be
+func (p *dnsPrefetcher) prefetch(urlStr string) {
+	if p == nil {
+		return
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	host := u.Hostname()
+	if host == "" || isOnion(host) {
+		return
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return
+	}
+	if p.transport.Proxy != nil {
+		if proxyURL, err := p.transport.Proxy(&http.Request{URL: u}); err != nil || proxyURL != nil {
+			return
+		}
+	}
+
+	p.mutex.Lock()
+	if answer, ok := p.answers[host]; ok && time.Now().Before(answer.expires) {
+		p.mutex.Unlock()
+		return
+	}
+	answer := &dnsAnswer{done: make(chan struct{}), expires: time.Now().Add(dnsPrefetchTTL)}
+	p.answers[host] = answer
+	p.mutex.Unlock()
+
+	go func() {
+		defer close(answer.done)
+		select {
+		case p.workers <- struct{}{}:
+			defer func() { <-p.workers }()
+		case <-p.ctx.Done():
+			answer.err = p.ctx.Err()
+			return
+		}
+		ctx, cancel := context.WithTimeout(p.ctx, dnsPrefetchTimeout)
+		defer cancel()
+		answer.ips, answer.err = p.resolve(ctx, host)
+	}()
+}
+
+// lookup returns the prefetched addresses of host, waiting for a lookup under way. It reports
+// false when there are none to use, and the host has to be resolved as usual.
+func (p *dnsPrefetcher) lookup(ctx context.Context, host string) ([]netip.Addr, bool) {
+	if p == nil {
+		return nil, false
+	}
+	p.mutex.Lock()
+	answer, ok := p.answers[host]
+	p.mutex.Unlock()
+	if !ok || time.Now().After(answer.expires) {
+		return nil, false
+	}
+	select {
+	case <-answer.done:
+	case <-ctx.Done():
+		return nil, false
+	}
+	if answer.err != nil || len(answer.ips) == 0 {
+		return nil, false // Resolved again, for the error to come from the dial
+	}
+	return answer.ips, true
+}
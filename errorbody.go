@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// saveErrorBody writes the body of a 4xx/5xx response to path for --content-on-error. The
+// download still counts as failed; this only keeps what the server had to say about it.
+func (w *WgetClone) saveErrorBody(resp *http.Response, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", filepath.Dir(path), err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%s': %w", path, err)
+	}
+	written, err := io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to save error response to '%s': %w", path, err)
+	}
+	fmt.Printf("Saved HTTP %d response (%s) to '%s'\n", resp.StatusCode, formatBytes(written), path)
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveFormat tells how to read a downloaded archive: zip, or tar through an optional decompressor
+type archiveFormat struct {
+	suffix string
+	zip    bool
+	open   func(io.Reader) (io.ReadCloser, error) // nil for a plain tar
+}
+
+var archiveFormats = []archiveFormat{
+	{".zip", true, nil},
+	{".tar", false, nil},
+	{".tar.gz", false, openGzip},
+	{".tgz", false, openGzip},
+	{".tar.bz2", false, openBzip2},
+	{".tbz2", false, openBzip2},
+	{".tar.xz", false, openXz},
+	{".txz", false, openXz},
+	{".tar.zst", false, openZstd},
+	{".tzst", false, openZstd},
+}
+
+// archiveFormatOf returns the format of the archive at file, judged by its name, or nil
+func archiveFormatOf(file string) *archiveFormat {
+	name := strings.ToLower(file)
+	var found *archiveFormat
+	for i := range archiveFormats {
+		// The longest match wins, so .tar.gz isn't taken for .gz
+		if strings.HasSuffix(name, archiveFormats[i].suffix) && (found == nil || len(archiveFormats[i].suffix) > len(found.suffix)) {
+			found = &archiveFormats[i]
+		}
+	}
+	return found
+}
+
+// extractDownload extracts the archive saved at file for --extract, into w.extractDir or
+// else a directory named after the archive beside it, and deletes it with --delete-archive.
+// Files that aren't archives are left alone.
+func (w *WgetClone) extractDownload(file string) error {
+	format := archiveFormatOf(file)
+	if format == nil {
+		return nil
+	}
+	dest := w.extractDir
+	if dest == "" {
+		dest = file[:len(file)-len(format.suffix)]
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", dest, err)
+	}
+
+	var count int
+	var err error
+	if format.zip {
+		count, err = extractZip(file, dest)
+	} else {
+		count, err = extractTar(file, dest, format.open)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract '%s': %w", file, err)
+	}
+	fmt.Printf("Extracted %d files from %s into %s\n", count, filepath.Base(file), dest)
+	if w.deleteArchive {
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", file, err)
+		}
+		fmt.Printf("Removed '%s' (--delete-archive)\n", file)
+	}
+	return nil
+}
+
+// extractPath returns where the archive entry name goes under dest, refusing names that
+// would land outside it: absolute paths, .. components and paths through symlinks leading out
+func extractPath(dest, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || strings.HasPrefix(name, "/") ||
+		clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("entry '%s' escapes the extraction directory", name)
+	}
+	target := filepath.Join(dest, clean)
+
+	// A symlink extracted earlier must not carry later entries out of dest
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return "", err
+	}
+	dir := dest
+	for _, part := range strings.Split(filepath.Dir(clean), string(filepath.Separator)) {
+		if part == "." {
+			break
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			resolved, err := filepath.EvalSymlinks(dir)
+			if err != nil || !withinDir(realDest, resolved) {
+				return "", fmt.Errorf("entry '%s' goes through a symlink out of the extraction directory", name)
+			}
+		}
+	}
+	return target, nil
+}
+
+// withinDir reports whether path is dir or below it
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// checkLink refuses a symlink at target whose destination lies outside dest
+func checkLink(dest, target, linkname string) error {
+	if filepath.IsAbs(linkname) || strings.HasPrefix(linkname, "/") {
+		return fmt.Errorf("symlink '%s' points outside the extraction directory", linkname)
+	}
+	if !withinDir(dest, filepath.Join(filepath.Dir(target), filepath.FromSlash(linkname))) {
+		return fmt.Errorf("symlink '%s' points outside the extraction directory", linkname)
+	}
+	return nil
+}
+
+// writeEntry creates the file target with the contents of r
+func writeEntry(target string, r io.Reader, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	os.Remove(target) // Don't write through a symlink left at the name
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm()|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// extractTar extracts the tar file at file, unpacked through open unless that is nil, into
+// dest and returns the number of files written. Devices and FIFOs are skipped.
+func extractTar(file, dest string, open func(io.Reader) (io.ReadCloser, error)) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if open != nil {
+		unpacked, err := open(f)
+		if err != nil {
+			return 0, err
+		}
+		defer unpacked.Close()
+		r = unpacked
+	}
+
+	count := 0
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		target, err := extractPath(dest, header.Name)
+		if err != nil {
+			return count, err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0o755)
+		case tar.TypeReg:
+			err = writeEntry(target, archive, header.FileInfo().Mode())
+			count++
+		case tar.TypeSymlink:
+			if err = checkLink(dest, target, header.Linkname); err == nil {
+				os.MkdirAll(filepath.Dir(target), 0o755)
+				os.Remove(target)
+				err = os.Symlink(header.Linkname, target)
+				count++
+			}
+		case tar.TypeLink:
+			var source string
+			if source, err = extractPath(dest, header.Linkname); err == nil {
+				os.MkdirAll(filepath.Dir(target), 0o755)
+				os.Remove(target)
+				err = os.Link(source, target)
+				count++
+			}
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// extractZip extracts the zip file at file into dest and returns the number of files written
+func extractZip(file, dest string) (int, error) {
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	count := 0
+	for _, entry := range archive.File {
+		target, err := extractPath(dest, entry.Name)
+		if err != nil {
+			return count, err
+		}
+		mode := entry.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return count, err
+			}
+			continue
+		}
+		r, err := entry.Open()
+		if err != nil {
+			return count, err
+		}
+		if mode&fs.ModeSymlink != 0 {
+			var linkname []byte
+			if linkname, err = io.ReadAll(io.LimitReader(r, 4096)); err == nil {
+				if err = checkLink(dest, target, string(linkname)); err == nil {
+					os.MkdirAll(filepath.Dir(target), 0o755)
+					os.Remove(target)
+					err = os.Symlink(string(linkname), target)
+				}
+			}
+		} else {
+			err = writeEntry(target, r, mode)
+		}
+		r.Close()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// archiveEntry is one member of a test archive; linkname makes it a symlink
+type archiveEntry struct {
+	name     string
+	linkname string
+	body     string
+}
+
+func writeTestTar(t *testing.T, file string, entries []archiveEntry) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	archive := tar.NewWriter(f)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(entry.body))}
+		if entry.linkname != "" {
+			header = &tar.Header{Name: entry.name, Mode: 0o777, Typeflag: tar.TypeSymlink, Linkname: entry.linkname}
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestZip(t *testing.T, file string, entries []archiveEntry) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	archive := zip.NewWriter(f)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Store}
+		body := entry.body
+		header.SetMode(0o644)
+		if entry.linkname != "" {
+			header.SetMode(fs.ModeSymlink | 0o777)
+			body = entry.linkname
+		}
+		w, err := archive.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractStaysInDirectory(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "outside")
+	tests := []struct {
+		name     string
+		symlinks bool
+		entries  []archiveEntry
+	}{
+		{"dot-dot", false, []archiveEntry{
+			{name: "ok.txt", body: "ok"},
+			{name: "../x", body: "escaped"},
+		}},
+		{"nested dot-dot", false, []archiveEntry{
+			{name: "sub/../../x", body: "escaped"},
+		}},
+		{"absolute", false, []archiveEntry{
+			{name: filepath.ToSlash(filepath.Join(outside, "x")), body: "escaped"},
+		}},
+		{"symlink out", true, []archiveEntry{
+			{name: "link", linkname: outside},
+			{name: "link/x", body: "escaped"},
+		}},
+		{"relative symlink out", true, []archiveEntry{
+			{name: "sub/up", linkname: "../.."},
+			{name: "sub/up/x", body: "escaped"},
+		}},
+		{"symlink through symlink", true, []archiveEntry{
+			{name: "self", linkname: "."},
+			{name: "self/up", linkname: ".."},
+			{name: "up/x", body: "escaped"},
+		}},
+	}
+
+	for _, format := range []string{".tar", ".zip"} {
+		for _, test := range tests {
+			t.Run(format+" "+test.name, func(t *testing.T) {
+				if test.symlinks && runtime.GOOS == "windows" {
+					t.Skip("symlinks need privileges on Windows")
+				}
+				root := t.TempDir()
+				archive := filepath.Join(root, "archive"+format)
+				if format == ".zip" {
+					writeTestZip(t, archive, test.entries)
+				} else {
+					writeTestTar(t, archive, test.entries)
+				}
+				dest := filepath.Join(root, "dest")
+				os.RemoveAll(outside)
+				if err := os.MkdirAll(outside, 0o755); err != nil {
+					t.Fatal(err)
+				}
+
+				w := &WgetClone{extractDir: dest}
+				if err := w.extractDownload(archive); err == nil {
+					t.Errorf("extracting %v succeeded, want it refused", test.entries)
+				}
+
+				filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+					if err != nil {
+						t.Fatal(err)
+					}
+					if file != root && file != archive && !withinDir(dest, file) {
+						t.Errorf("'%s' was written outside the extraction directory", file)
+					}
+					return nil
+				})
+				if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+					t.Errorf("'%s' was written through to %s", entries[0].Name(), outside)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// errFiltered marks a resource skipped by the size or MIME type filters
+var errFiltered = errors.New("filtered out")
+
+// parseByteSize parses sizes like 500, 200k, 1.5M or 4G (binary units)
+func parseByteSize(sizeStr string) (int64, error) {
+	if sizeStr == "" {
+		return 0, nil
+	}
+	matches := regexp.MustCompile(`^(\d+(?:\.\d+)?)([kKmMgG]?)[bB]?$`).FindStringSubmatch(sizeStr)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size format: %s", sizeStr)
+	}
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.ToLower(matches[2]) {
+	case "k":
+		value *= 1024
+	case "m":
+		value *= 1024 * 1024
+	case "g":
+		value *= 1024 * 1024 * 1024
+	}
+	return int64(value), nil
+}
+
+// sizeFilter holds the --min-filesize/--max-filesize bounds; zero means unbounded
+type sizeFilter struct {
+	min int64
+	max int64
+}
+
+// check rejects a resource whose announced size is outside the bounds. Unknown sizes (-1) pass.
+func (f sizeFilter) check(size int64) error {
+	if size < 0 {
+		return nil
+	}
+	if f.min > 0 && size < f.min {
+		return fmt.Errorf("%w: %s is below --min-filesize %s", errFiltered, formatBytes(size), formatBytes(f.min))
+	}
+	if f.max > 0 && size > f.max {
+		return fmt.Errorf("%w: %s is above --max-filesize %s", errFiltered, formatBytes(size), formatBytes(f.max))
+	}
+	return nil
+}
+
+// limit enforces --max-filesize while reading a body of unknown size
+func (f sizeFilter) limit(reader io.Reader) io.Reader {
+	if f.max <= 0 {
+		return reader
+	}
+	return &maxSizeReader{reader: reader, remaining: f.max}
+}
+
+// maxSizeReader fails once more than its limit has been read
+type maxSizeReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, fmt.Errorf("%w: body exceeds --max-filesize", errFiltered)
+	}
+	return n, err
+}
+
+// mimeFilter holds the --accept-mime/--reject-mime patterns such as "text/*" or "image/png"
+type mimeFilter struct {
+	accept []string
+	reject []string
+}
+
+// parseMimeList splits a comma-separated list of MIME type patterns
+func parseMimeList(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// mimeMatches reports whether mediaType matches pattern, which may end in /* or be */*
+func mimeMatches(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return pattern == mediaType
+}
+
+// check rejects a response whose Content-Type is excluded by the filters.
+// With an accept list, responses without a Content-Type are rejected too.
+func (f mimeFilter) check(contentType string) error {
+	if len(f.accept) == 0 && len(f.reject) == 0 {
+		return nil
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, p := range f.reject {
+		if mimeMatches(p, mediaType) {
+			return fmt.Errorf("%w: Content-Type %s matches --reject-mime", errFiltered, mediaType)
+		}
+	}
+	if len(f.accept) == 0 {
+		return nil
+	}
+	for _, p := range f.accept {
+		if mediaType != "" && mimeMatches(p, mediaType) {
+			return nil
+		}
+	}
+	if mediaType == "" {
+		mediaType = "(none)"
+	}
+	return fmt.Errorf("%w: Content-Type %s is not in --accept-mime", errFiltered, mediaType)
+}
+
+// urlPatterns holds the --exclude-regex expressions, matched against whole URLs
+type urlPatterns []*regexp.Regexp
+
+// parseURLPatterns compiles one regular expression per --exclude-regex flag
+func parseURLPatterns(exprs []string) (urlPatterns, error) {
+	var patterns urlPatterns
+	for _, expr := range exprs {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --exclude-regex %q: %w", expr, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// match reports whether any pattern matches urlStr, scheme, host, path and query included
+func (p urlPatterns) match(urlStr string) bool {
+	for _, pattern := range p {
+		if pattern.MatchString(urlStr) {
+			return true
+		}
+	}
+	return false
+}
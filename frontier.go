@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// crawlOrders are the --crawl-order strategies
+var crawlOrders = []string{"bfs", "dfs", "priority"}
+
+// crawlItem is one URL waiting in the crawl frontier
+type crawlItem struct {
+	url   string
+	depth int
+}
+
+// crawlFrontier holds the URLs a mirror has found but not fetched yet, and hands them to the
+// workers in --crawl-order: breadth-first (by depth, in discovery order), depth-first (the
+// latest find first) or priority (pages before the files they use, breadth-first within each).
+type crawlFrontier struct {
+	mutex  sync.Mutex
+	wake   *sync.Cond
+	order  string
+	queues [2][]crawlItem // Pages, then other files; only priority uses the second
+	queued urlSet         // Every URL ever pushed, so none is fetched twice
+	popped int
+	active int // Items handed out and not yet done
+
+	stopped bool // --crawl-timeout passed: hand out nothing more
+
+	// --max-pages bookkeeping; items deeper than maxDepth are skipped unfetched, so don't count
+	maxItems int
+	maxDepth int
+	counted  int
+	cutoff   int // New URLs refused once maxItems were queued
+
+	// --memory-limit bookkeeping, see membudget.go
+	room     *sync.Cond
+	limit    int64
+	buffered int64 // Bodies held in memory by workers
+	pending  int64 // Estimated size of the queued items
+	held     int   // Workers waiting for room to queue their links
+	paused   bool  // The limit was hit and announced
+}
+
+func newCrawlFrontier(order string, queued urlSet) (*crawlFrontier, error) {
+	valid := false
+	for _, name := range crawlOrders {
+		valid = valid || order == name
+	}
+	if !valid {
+		return nil, fmt.Errorf("unknown crawl order '%s' (want %s)", order, strings.Join(crawlOrders, ", "))
+	}
+	f := &crawlFrontier{order: order, queued: queued}
+	f.wake = sync.NewCond(&f.mutex)
+	f.room = sync.NewCond(&f.mutex)
+	return f, nil
+}
+
+// looksLikePage guesses from its path whether urlStr is an HTML page
+func looksLikePage(urlStr string) bool {
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(path.Ext(parsed.Path)) {
+	case "", ".html", ".htm", ".xhtml", ".php", ".asp", ".aspx", ".jsp":
+		return true
+	}
+	return false
+}
+
+// push adds urlStr at depth unless it was queued before, and reports whether it was added
+func (f *crawlFrontier) push(urlStr string, depth int) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !f.queued.add(urlStr) {
+		return false
+	}
+	if f.maxItems > 0 && depth <= f.maxDepth {
+		if f.counted >= f.maxItems {
+			if f.cutoff == 0 {
+				fmt.Printf("Reached --max-pages %d: finishing queued URLs without queueing more\n", f.maxItems)
+			}
+			f.cutoff++
+			return false
+		}
+		f.counted++
+	}
+	f.enqueue(crawlItem{url: urlStr, depth: depth})
+	f.wake.Signal()
+	return true
+}
+
+// claim marks urlStr as seen without queueing it, for a page saved under that name by another
+// URL's fetch, and reports whether it was new
+func (f *crawlFrontier) claim(urlStr string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.queued.add(urlStr)
+}
+
+// enqueue files item in its queue; the caller holds f.mutex
+func (f *crawlFrontier) enqueue(item crawlItem) {
+	queue := 0
+	if f.order == "priority" && !looksLikePage(item.url) {
+		queue = 1
+	}
+	f.queues[queue] = append(f.queues[queue], item)
+	f.pending += entrySize(item.url)
+}
+
+// pop waits for the next item. It reports false once the frontier is empty and no worker
+// is still fetching, as nothing more can turn up then.
+func (f *crawlFrontier) pop() (crawlItem, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for len(f.queues[0])+len(f.queues[1]) == 0 && !f.stopped {
+		if f.active == 0 {
+			return crawlItem{}, false
+		}
+		f.wake.Wait()
+	}
+	if f.stopped {
+		return crawlItem{}, false
+	}
+
+	queue := &f.queues[0]
+	if len(*queue) == 0 {
+		queue = &f.queues[1]
+	}
+	var item crawlItem
+	if f.order == "dfs" {
+		item, *queue = (*queue)[len(*queue)-1], (*queue)[:len(*queue)-1]
+	} else {
+		item, *queue = (*queue)[0], (*queue)[1:]
+	}
+	f.active++
+	f.popped++
+	f.pending -= entrySize(item.url)
+	f.room.Broadcast()
+	return item, true
+}
+
+// refused is the number of new URLs --max-pages kept out of the frontier
+func (f *crawlFrontier) refused() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.cutoff
+}
+
+// visited is the number of items handed out so far
+func (f *crawlFrontier) visited() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.popped
+}
+
+// done marks an item from pop as finished, after its links were pushed
+func (f *crawlFrontier) done() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.active--
+	f.room.Broadcast() // One fewer worker that could drain the queue
+	if f.active == 0 && len(f.queues[0])+len(f.queues[1]) == 0 {
+		f.wake.Broadcast() // Let idle workers see that the crawl is over
+	}
+}
+
+// stop makes pop hand out nothing more; items already out still finish
+func (f *crawlFrontier) stop() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stopped = true
+	f.wake.Broadcast()
+}
+
+// timedOut reports whether stop was called
+func (f *crawlFrontier) timedOut() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.stopped
+}
+
+// requeue puts items saved by an earlier run back, whether or not the set has seen them
+func (f *crawlFrontier) requeue(items []crawlItem) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, item := range items {
+		f.queued.add(item.url)
+		f.enqueue(item)
+	}
+	f.wake.Broadcast()
+}
+
+// remaining is what is still queued, in an order requeue restores as it was
+func (f *crawlFrontier) remaining() []crawlItem {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append(append([]crawlItem{}, f.queues[0]...), f.queues[1]...)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"time"
+)
+
+const (
+	// defaultAttemptDelay is how long a connection attempt gets before the next address is
+	// tried alongside it (RFC 8305's Connection Attempt Delay)
+	defaultAttemptDelay = 250 * time.Millisecond
+	// resolutionDelay is how long to wait for IPv6 addresses once the IPv4 ones are in (RFC 8305 section 3)
+	resolutionDelay = 50 * time.Millisecond
+)
+
+// happyDialer connects to dual-stack hosts the RFC 8305 way: it resolves IPv6 and IPv4
+// addresses at once, interleaves them IPv6 first, and starts a new attempt every attemptDelay
+// (or as soon as one fails) while the earlier ones keep trying. The first to connect wins, so
+// a broken IPv6 route costs a fraction of a second instead of a connect timeout.
+type happyDialer struct {
+	dialer       *net.Dialer
+	attemptDelay time.Duration
+	prefetched   *dnsPrefetcher // Addresses looked up ahead of the dial; nil resolves every host when dialed
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+func (d *happyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || network != "tcp" {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	ips, ok := d.prefetched.lookup(ctx, host)
+	if !ok {
+		if ips, err = d.resolve(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+	if len(ips) == 1 {
+		return d.dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].String(), port))
+	}
+
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(ips))
+	next, pending := 0, 0
+	start := func() {
+		target := net.JoinHostPort(ips[next].String(), port)
+		next++
+		pending++
+		go func() {
+			conn, err := d.dialer.DialContext(attemptCtx, network, target)
+			results <- dialResult{conn, err}
+		}()
+	}
+	start()
+	timer := time.NewTimer(d.attemptDelay)
+	defer timer.Stop()
+
+	var firstErr error
+	for {
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				cancel()
+				// Close the connections of attempts that finish after the winner
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return result.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if next < len(ips) {
+				start()
+				timer.Reset(d.attemptDelay)
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		case <-timer.C:
+			if next < len(ips) {
+				start()
+				timer.Reset(d.attemptDelay)
+			}
+		}
+	}
+}
+
+// resolve looks up host's IPv6 and IPv4 addresses in parallel and returns them interleaved,
+// IPv6 first. IPv6 answers arriving more than resolutionDelay after the IPv4 ones are not waited for.
+func (d *happyDialer) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	resolver := d.dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	type answer struct {
+		ips []netip.Addr
+		err error
+	}
+	v6, v4 := make(chan answer, 1), make(chan answer, 1)
+	lookupCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		ips, err := resolver.LookupNetIP(lookupCtx, "ip6", host)
+		v6 <- answer{ips, err}
+	}()
+	go func() {
+		ips, err := resolver.LookupNetIP(lookupCtx, "ip4", host)
+		v4 <- answer{ips, err}
+	}()
+	defer cancel()
+
+	var six, four answer
+	select {
+	case six = <-v6:
+		four = <-v4
+	case four = <-v4:
+		select {
+		case six = <-v6:
+		case <-time.After(resolutionDelay):
+			if len(four.ips) == 0 {
+				six = <-v6 // Nothing to start with yet, so IPv6 is all there is
+			}
+		}
+	}
+	if len(six.ips) == 0 && len(four.ips) == 0 {
+		err := four.err
+		if err == nil {
+			err = six.err
+		}
+		if err == nil {
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return nil, err
+	}
+
+	ips := make([]netip.Addr, 0, len(six.ips)+len(four.ips))
+	for i := 0; i < max(len(six.ips), len(four.ips)); i++ {
+		if i < len(six.ips) {
+			ips = append(ips, six.ips[i])
+		}
+		if i < len(four.ips) {
+			ips = append(ips, four.ips[i].Unmap())
+		}
+	}
+	return ips, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// PrintHead shows what a download of urlStr would fetch without saving anything. It sends a HEAD
+// request, falling back to a GET whose body is never read for servers that don't allow HEAD.
+func (w *WgetClone) PrintHead(urlStr string) error {
+	resp, err := w.metadataRequest("HEAD", urlStr)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = w.metadataRequest("GET", urlStr)
+	}
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close() // Nothing of the body is needed, even after a GET
+
+	size := "unknown"
+	if resp.ContentLength >= 0 {
+		size = formatBytes(resp.ContentLength) + " (" + strconv.FormatInt(resp.ContentLength, 10) + " bytes)"
+	}
+	fmt.Printf("URL:            %s\n", urlStr)
+	if final := resp.Request.URL.String(); final != urlStr {
+		fmt.Printf("Final URL:      %s\n", final)
+	}
+	fmt.Printf("Status:         %s\n", resp.Status)
+	fmt.Printf("Size:           %s\n", size)
+	for _, field := range []struct{ label, header string }{
+		{"Content-Type:   ", "Content-Type"},
+		{"Last-Modified:  ", "Last-Modified"},
+		{"ETag:           ", "ETag"},
+		{"Accept-Ranges:  ", "Accept-Ranges"},
+	} {
+		if value := resp.Header.Get(field.header); value != "" {
+			fmt.Printf("%s%s\n", field.label, value)
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+func (w *WgetClone) metadataRequest(method, urlStr string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(w.ctx, method, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	return w.client.Do(req)
+}
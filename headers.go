@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headerTransport adds the --header, --accept-language and --dnt headers to every request,
+// then the headers of the request's -i line. They replace what the request already carries,
+// so a User-Agent given here wins too. Tokens such as {host} in their values are filled in
+// for each request, see expandHeader.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry, _ := req.Context().Value(entryHeadersKey{}).(*entryHeaders)
+	if entry != nil && entry.host != req.URL.Host {
+		entry = nil // Redirected to another host
+	}
+	if len(t.headers) == 0 && entry == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	now := time.Now()
+	for name, values := range t.headers {
+		req.Header[name] = expandHeaders(values, req, now)
+	}
+	if entry != nil {
+		for name, values := range entry.header {
+			req.Header[name] = expandHeaders(values, req, now)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// expandHeaders returns values with their tokens filled in for req
+func expandHeaders(values []string, req *http.Request, now time.Time) []string {
+	var expanded []string // Copied on the first token, as the configured values stay templates
+	for i, value := range values {
+		if !strings.Contains(value, "{") {
+			continue
+		}
+		if expanded == nil {
+			expanded = append([]string(nil), values...)
+		}
+		expanded[i] = expandHeader(value, req, now)
+	}
+	if expanded == nil {
+		return values
+	}
+	return expanded
+}
+
+// expandHeader fills in the tokens of a header value for req: {url}, {scheme}, {host},
+// {path}, {query}, {method}, {timestamp} (Unix seconds), {timestamp_ms}, {date} (HTTP date),
+// {iso8601} and {nonce} (16 random hex digits). {{ stands for a literal brace; other tokens
+// are left as they are.
+func expandHeader(value string, req *http.Request, now time.Time) string {
+	var sb strings.Builder
+	for len(value) > 0 {
+		open := strings.IndexByte(value, '{')
+		if open < 0 {
+			sb.WriteString(value)
+			break
+		}
+		sb.WriteString(value[:open])
+		value = value[open:]
+		if strings.HasPrefix(value, "{{") {
+			sb.WriteByte('{')
+			value = value[2:]
+			continue
+		}
+		end := strings.IndexByte(value, '}')
+		if end < 0 {
+			sb.WriteString(value)
+			break
+		}
+		token, known := headerToken(value[1:end], req, now)
+		if !known {
+			token = value[:end+1]
+		}
+		sb.WriteString(token)
+		value = value[end+1:]
+	}
+	return sb.String()
+}
+
+// headerToken returns the value of one expandHeader token
+func headerToken(name string, req *http.Request, now time.Time) (string, bool) {
+	switch name {
+	case "url":
+		return req.URL.String(), true
+	case "scheme":
+		return req.URL.Scheme, true
+	case "host":
+		return req.URL.Host, true
+	case "path":
+		return req.URL.EscapedPath(), true
+	case "query":
+		return req.URL.RawQuery, true
+	case "method":
+		return req.Method, true
+	case "timestamp":
+		return strconv.FormatInt(now.Unix(), 10), true
+	case "timestamp_ms":
+		return strconv.FormatInt(now.UnixMilli(), 10), true
+	case "date":
+		return now.UTC().Format(http.TimeFormat), true
+	case "iso8601":
+		return now.UTC().Format(time.RFC3339), true
+	case "nonce":
+		nonce := make([]byte, 8)
+		rand.Read(nonce)
+		return hex.EncodeToString(nonce), true
+	}
+	return "", false
+}
+
+// parseHeaders builds the default request headers from "Name: value" lines and the shortcut flags
+func parseHeaders(lines []string, acceptLanguage string, dnt bool) (http.Header, error) {
+	headers := make(http.Header)
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q (want \"Name: value\")", line)
+		}
+		headers.Add(textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value))
+	}
+	if acceptLanguage != "" {
+		headers.Set("Accept-Language", acceptLanguage)
+	}
+	if dnt {
+		headers.Set("DNT", "1")
+	}
+	return headers, nil
+}
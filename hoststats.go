@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hostStats tallies the requests a mirror sends each host, for the summary at the end of a
+// crawl. A nil *hostStats records nothing.
+type hostStats struct {
+	mutex sync.Mutex
+	hosts map[string]*hostTally
+}
+
+// hostTally is what one host served
+type hostTally struct {
+	requests int
+	errors   int           // Failed requests and 4xx/5xx responses
+	bytes    int64         // Response body bytes read
+	latency  time.Duration // Summed time to response headers, of the requests that got any
+	answered int
+}
+
+func newHostStats() *hostStats {
+	return &hostStats{hosts: make(map[string]*hostTally)}
+}
+
+// tally returns the counters of host; the caller holds s.mutex
+func (s *hostStats) tally(host string) *hostTally {
+	t, ok := s.hosts[host]
+	if !ok {
+		t = &hostTally{}
+		s.hosts[host] = t
+	}
+	return t
+}
+
+// request records one request to host, answered with resp after latency or failed with err
+func (s *hostStats) request(host string, resp *http.Response, latency time.Duration, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t := s.tally(host)
+	t.requests++
+	if err != nil || resp.StatusCode >= 400 {
+		t.errors++
+	}
+	if err == nil {
+		t.latency += latency
+		t.answered++
+	}
+}
+
+// received adds n body bytes read from host
+func (s *hostStats) received(host string, n int64) {
+	s.mutex.Lock()
+	s.tally(host).bytes += n
+	s.mutex.Unlock()
+}
+
+// Print writes one line per host, busiest first
+func (s *hostStats) Print() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.hosts) == 0 {
+		return
+	}
+	hosts := make([]string, 0, len(s.hosts))
+	width := len("Host")
+	for host := range s.hosts {
+		hosts = append(hosts, host)
+		width = max(width, len(host))
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		a, b := s.hosts[hosts[i]], s.hosts[hosts[j]]
+		if a.requests != b.requests {
+			return a.requests > b.requests
+		}
+		return hosts[i] < hosts[j]
+	})
+
+	fmt.Printf("%-*s %9s %11s %7s %12s\n", width, "Host", "Requests", "Bytes", "Errors", "Avg latency")
+	for _, host := range hosts {
+		t := s.hosts[host]
+		latency := "-"
+		if t.answered > 0 {
+			latency = (t.latency / time.Duration(t.answered)).Round(time.Millisecond).String()
+		}
+		fmt.Printf("%-*s %9d %11s %7d %12s\n", width, host, t.requests, formatBytes(t.bytes), t.errors, latency)
+	}
+}
+
+// hostStatsTransport feeds hostStats with every request and the body bytes of its response
+type hostStatsTransport struct {
+	base  http.RoundTripper
+	stats *hostStats
+}
+
+func (t *hostStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	t.stats.request(req.URL.Host, resp, time.Since(start), err)
+	if err == nil {
+		resp.Body = &hostCountingBody{ReadCloser: resp.Body, host: req.URL.Host, stats: t.stats}
+	}
+	return resp, err
+}
+
+// hostCountingBody counts the bytes read from a response body towards its host
+type hostCountingBody struct {
+	io.ReadCloser
+	host  string
+	stats *hostStats
+}
+
+func (b *hostCountingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.stats.received(b.host, int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// entryHeaders are the request headers given on one line of an -i file. They are sent only
+// to that line's host, so credentials don't follow a redirect elsewhere.
+type entryHeaders struct {
+	host   string
+	header http.Header
+}
+
+// entryHeadersKey is the request context key carrying a download's *entryHeaders
+type entryHeadersKey struct{}
+
+// readInputURLs returns the URLs listed in an -i file, with the headers of the lines that
+// carry header="Name: value" options or user:pass@ credentials. With forceHTML the file is
+// parsed as an HTML page and every link it references is returned instead. Relative entries
+// are resolved against base, or against the page's own <base href> when base is empty.
+func readInputURLs(path string, forceHTML bool, base string) ([]string, map[string]*entryHeaders, error) {
+	if forceHTML {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if base == "" {
+			base = htmlBaseHref(string(content))
+		}
+		if base == "" {
+			fmt.Println("Warning: no --base URL given; relative links in the page are skipped")
+		}
+		links, err := extractLinks(string(content), base, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse HTML: %w", err)
+		}
+		return links, nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var baseURL *url.URL
+	if base != "" {
+		if baseURL, err = url.Parse(base); err != nil {
+			return nil, nil, fmt.Errorf("invalid base URL: %w", err)
+		}
+	}
+
+	var urls []string
+	entries := make(map[string]*entryHeaders)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := splitInputLine(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		line, header, err := parseEntryOptions(fields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if uri, err := toURI(line); err == nil {
+			line = uri
+		}
+		if baseURL != nil {
+			if ref, err := url.Parse(line); err == nil {
+				line = baseURL.ResolveReference(ref).String()
+			}
+		}
+		if header != nil {
+			if parsed, err := url.Parse(line); err == nil {
+				entries[line] = &entryHeaders{host: parsed.Host, header: header}
+			}
+		}
+		urls = append(urls, line)
+	}
+	return urls, entries, scanner.Err()
+}
+
+// splitInputLine splits an -i line at spaces, keeping "double-quoted" runs together
+func splitInputLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted, inField := false, false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted, inField = !quoted, true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// parseEntryOptions reads the URL and the header= options of one -i line. Credentials in the
+// URL are moved into an Authorization header, so they don't show up in progress output.
+func parseEntryOptions(fields []string) (string, http.Header, error) {
+	var header http.Header
+	for _, option := range fields[1:] {
+		value, ok := strings.CutPrefix(option, "header=")
+		name, content, valid := strings.Cut(value, ":")
+		if !ok || !valid || strings.TrimSpace(name) == "" {
+			return "", nil, fmt.Errorf("invalid option %q (want header=\"Name: value\")", option)
+		}
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Add(textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name)), strings.TrimSpace(content))
+	}
+
+	rawURL := fields[0]
+	if parsed, err := url.Parse(rawURL); err == nil && parsed.User != nil {
+		password, _ := parsed.User.Password()
+		req := &http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(parsed.User.Username(), password)
+		if header == nil {
+			header = make(http.Header)
+		}
+		if header.Get("Authorization") == "" {
+			header.Set("Authorization", req.Header.Get("Authorization"))
+		}
+		// Cut the user:pass@ out of the text, as re-encoding the URL would mangle {a,b} patterns
+		scheme, rest, _ := strings.Cut(rawURL, "://")
+		authority := rest
+		if end := strings.IndexAny(rest, "/?#"); end >= 0 {
+			authority = rest[:end]
+		}
+		rawURL = scheme + "://" + rest[strings.LastIndex(authority, "@")+1:]
+	}
+	return rawURL, header, nil
+}
+
+// expandEntryHeaders gives every URL a pattern line expands to the headers of that line
+func expandEntryHeaders(entries map[string]*entryHeaders) error {
+	for pattern, entry := range entries {
+		if !hasURLPattern(pattern) {
+			continue
+		}
+		urls, err := ExpandURLPattern(pattern)
+		if err != nil {
+			return err
+		}
+		for _, urlStr := range urls {
+			entries[urlStr] = entry
+		}
+	}
+	return nil
+}
+
+// withEntryHeaders tags ctx with the -i line headers of urlStr, if it has any
+func (w *WgetClone) withEntryHeaders(ctx context.Context, urlStr string) context.Context {
+	if entry := w.entryHeaders[urlStr]; entry != nil {
+		return context.WithValue(ctx, entryHeadersKey{}, entry)
+	}
+	return ctx
+}
+
+// htmlBaseHref returns the href of the first <base> element in an HTML document, if any
+func htmlBaseHref(content string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) != "base" {
+				continue
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = tokenizer.TagAttr()
+				if string(key) == "href" {
+					return strings.TrimSpace(string(val))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// isASCII reports whether s holds only ASCII bytes
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// toURI turns an IRI, a URL that may hold Unicode, into the ASCII URI sent on the wire: the
+// host becomes punycode and the UTF-8 bytes anywhere else are percent-encoded. ASCII URLs are
+// returned untouched, so {a,b} and [1-9] patterns survive.
+func toURI(raw string) (string, error) {
+	if isASCII(raw) {
+		return raw, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if host := u.Hostname(); !isASCII(host) {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("invalid hostname '%s': %w", host, err)
+		}
+		raw = strings.Replace(raw, host, ascii, 1)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if c := raw[i]; c >= utf8.RuneSelf {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// fileNameRules is the --restrict-file-names mode: which characters of a URL are escaped as
+// %XX in local file names. The default keeps UTF-8 names and escapes only control characters,
+// plus the characters Windows forbids when running there.
+type fileNameRules struct {
+	windows   bool // Escape \ | : ? " * < > as well
+	ascii     bool // Escape every non-ASCII byte instead of keeping UTF-8 names
+	noControl bool // Keep control characters as they are
+	lowercase bool
+	uppercase bool
+}
+
+// parseFileNameRules parses a comma-separated list of unix, windows, nocontrol, ascii,
+// lowercase and uppercase
+func parseFileNameRules(list string) (*fileNameRules, error) {
+	rules := &fileNameRules{windows: runtime.GOOS == "windows"}
+	for _, mode := range strings.Split(list, ",") {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "unix":
+			rules.windows = false
+		case "windows":
+			rules.windows = true
+		case "nocontrol":
+			rules.noControl = true
+		case "ascii":
+			rules.ascii = true
+		case "lowercase":
+			rules.lowercase = true
+		case "uppercase":
+			rules.uppercase = true
+		default:
+			return nil, fmt.Errorf("unknown mode '%s' (want unix, windows, nocontrol, ascii, lowercase or uppercase)", mode)
+		}
+	}
+	if rules.lowercase && rules.uppercase {
+		return nil, fmt.Errorf("lowercase and uppercase can't be combined")
+	}
+	return rules, nil
+}
+
+// effective returns the rules in force, filling in the platform default for nil
+func (r *fileNameRules) effective() fileNameRules {
+	if r == nil {
+		return fileNameRules{windows: runtime.GOOS == "windows"}
+	}
+	return *r
+}
+
+// escape makes one path component safe to use as a file name. Bytes that aren't valid UTF-8
+// are always escaped so the name can be displayed and typed, and overlong names are shortened.
+func (r *fileNameRules) escape(name string) string {
+	rules := r.effective()
+
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		c := name[i]
+		_, size := utf8.DecodeRuneInString(name[i:])
+		invalid := c >= utf8.RuneSelf && size == 1
+		if (c < 0x20 || c == 0x7f) && !rules.noControl ||
+			rules.windows && strings.IndexByte(`\|:?"*<>`, c) >= 0 ||
+			c >= utf8.RuneSelf && (rules.ascii || invalid) {
+			for _, escaped := range []byte(name[i : i+size]) {
+				fmt.Fprintf(&b, "%%%02X", escaped)
+			}
+		} else {
+			b.WriteString(name[i : i+size])
+		}
+		i += size
+	}
+
+	result := b.String()
+	if rules.windows && result != "" {
+		result = windowsName(result)
+	}
+	switch {
+	case rules.lowercase:
+		result = strings.ToLower(result)
+	case rules.uppercase:
+		result = strings.ToUpper(result)
+	}
+	return shortenName(result)
+}
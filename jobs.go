@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Job is a background download recorded in the job registry
+type Job struct {
+	ID        int       `json:"id"`
+	PID       int       `json:"pid"`
+	PIDStart  string    `json:"pid_start,omitempty"` // processStartTime of PID, to spot a reused PID
+	URL       string    `json:"url"`
+	Args      []string  `json:"args"`
+	LogFile   string    `json:"log_file"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// alive reports whether the job's process is still running. A process that has its PID but
+// started at another time took the PID over after the job exited.
+func (j *Job) alive() bool {
+	if !processAlive(j.PID) {
+		return false
+	}
+	if j.PIDStart == "" {
+		return true // Recorded before start times were
+	}
+	started, err := processStartTime(j.PID)
+	return err == nil && started == j.PIDStart
+}
+
+// State reports whether the job's process is still alive
+func (j *Job) State() string {
+	if j.alive() {
+		return "running"
+	}
+	return "finished"
+}
+
+// jobsDir returns the registry directory, honoring WGET_JOBS_DIR
+func jobsDir() (string, error) {
+	if dir := os.Getenv("WGET_JOBS_DIR"); dir != "" {
+		return dir, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate job registry: %w", err)
+	}
+	return filepath.Join(cacheDir, "go-wget", "jobs"), nil
+}
+
+// loadJobs reads all job records sorted by ID
+func loadJobs() ([]*Job, error) {
+	dir, err := jobsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read job registry: %w", err)
+	}
+
+	var jobs []*Job
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+// registerJob stores a record for a freshly started background process and returns its ID
+func registerJob(pid int, urlStr string, args []string, logFile string) (*Job, error) {
+	dir, err := jobsDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create job registry: %w", err)
+	}
+
+	jobs, err := loadJobs()
+	if err != nil {
+		return nil, err
+	}
+	id := 1
+	if len(jobs) > 0 {
+		id = jobs[len(jobs)-1].ID + 1
+	}
+
+	if abs, err := filepath.Abs(logFile); err == nil {
+		logFile = abs
+	}
+	job := &Job{ID: id, PID: pid, URL: redactURL(urlStr), Args: redactSecrets(args), LogFile: logFile, StartedAt: time.Now()}
+	if started, err := processStartTime(pid); err == nil {
+		job.PIDStart = started
+	}
+
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, strconv.Itoa(id)+".json"), data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to record job: %w", err)
+	}
+	return job, nil
+}
+
+// secretFlags take values that must not be written to the job registry, mapped to what they
+// are replaced with
+var secretFlags = map[string]func(value string) string{
+	"password":            redactValue,
+	"proxy-password":      redactValue,
+	"oauth-client-secret": redactValue,
+	"login-data":          redactLoginData,
+	"header":              redactHeader,
+}
+
+// redactValue hides a secret value entirely
+func redactValue(string) string { return "REDACTED" }
+
+// redactLoginData hides a --login-data form body, but not the name of the @file holding one
+func redactLoginData(value string) string {
+	if strings.HasPrefix(value, "@") {
+		return value
+	}
+	return redactValue(value)
+}
+
+// redactHeader hides the value of a --header that carries credentials or a session
+func redactHeader(value string) string {
+	name, _, ok := strings.Cut(value, ":")
+	lower := strings.ToLower(name)
+	for _, sensitive := range []string{"auth", "cookie", "token", "secret", "key"} {
+		if ok && strings.Contains(lower, sensitive) {
+			return name + ": REDACTED"
+		}
+	}
+	return value
+}
+
+// redactURL hides the password of a URL with user:password@ in it
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") || !strings.Contains(value, "@") {
+		return value
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return value
+}
+
+// redactSecrets returns args with the values of secretFlags masked, given as -flag=value
+// or as -flag value, and the passwords of URLs among them
+func redactSecrets(args []string) []string {
+	redacted := make([]string, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		redacted[i] = redactURL(arg)
+		if !strings.HasPrefix(arg, "-") || arg == "--" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		redact, ok := secretFlags[name]
+		if !ok {
+			continue
+		}
+		if hasValue {
+			redacted[i] = arg[:len(arg)-len(value)] + redact(value)
+		} else if i+1 < len(args) {
+			i++
+			redacted[i] = redact(args[i])
+		}
+	}
+	return redacted
+}
+
+// createJobLog creates a log file no other job writes to: base if it doesn't exist yet,
+// otherwise base.1, base.2, ... like GNU wget
+func createJobLog(base string) (*os.File, string, error) {
+	for n := 0; ; n++ {
+		name := base
+		if n > 0 {
+			name = base + "." + strconv.Itoa(n)
+		}
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return file, name, nil
+		}
+		if !os.IsExist(err) {
+			return nil, "", err
+		}
+	}
+}
+
+// findJob looks up a job by the ID given on the command line
+func findJob(idStr string) (*Job, error) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job id: %s", idStr)
+	}
+	jobs, err := loadJobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("no such job: %d", id)
+}
+
+// lastLines returns up to n trailing lines of a file
+func lastLines(filename string, n int) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Progress updates are \r-separated; keep only the latest one
+		line := scanner.Text()
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		lines = append(lines, strings.TrimPrefix(line, "\033[K"))
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// followLog streams a log file to stdout like `tail -f` until running reports false or ctx is cancelled
+func followLog(ctx context.Context, logFile string, running func() bool) error {
+	file, err := os.Open(logFile)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		// Check before draining so output written right before exit is not lost
+		alive := running()
+		if _, err := io.Copy(os.Stdout, file); err != nil {
+			return fmt.Errorf("failed to read log file: %w", err)
+		}
+		if !alive {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+const jobsUsage = `Usage:
+  ./wget jobs list              List background downloads
+  ./wget jobs status <id>       Show a job's details and latest output
+  ./wget jobs stop <id>         Stop a running job
+  ./wget jobs log <id>          Print a job's log file
+  ./wget jobs tail <id>         Stream a job's log file until it finishes
+  ./wget jobs rate <id> <rate>  Change a running job's rate limit (e.g. 200k, or off)
+  ./wget jobs clean             Forget finished jobs`
+
+// RunJobsCommand implements the `jobs` subcommand and returns the process exit code
+func RunJobsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(jobsUsage)
+		return 1
+	}
+
+	if err := runJobsCommand(args[0], args[1:]); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runJobsCommand(command string, args []string) error {
+	switch command {
+	case "list":
+		jobs, err := loadJobs()
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No background jobs")
+			return nil
+		}
+		fmt.Printf("%-4s %-8s %-9s %-19s %s\n", "ID", "PID", "STATE", "STARTED", "URL")
+		for _, job := range jobs {
+			fmt.Printf("%-4d %-8d %-9s %-19s %s\n",
+				job.ID, job.PID, job.State(), job.StartedAt.Format("2006-01-02 15:04:05"), job.URL)
+		}
+		return nil
+
+	case "clean":
+		jobs, err := loadJobs()
+		if err != nil {
+			return err
+		}
+		dir, err := jobsDir()
+		if err != nil {
+			return err
+		}
+		removed := 0
+		for _, job := range jobs {
+			if job.State() == "finished" {
+				os.Remove(filepath.Join(dir, strconv.Itoa(job.ID)+".json"))
+				removed++
+			}
+		}
+		fmt.Printf("Removed %d finished jobs\n", removed)
+		return nil
+
+	case "status", "stop", "log", "tail":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: ./wget jobs %s <id>", command)
+		}
+		job, err := findJob(args[0])
+		if err != nil {
+			return err
+		}
+		return runJobCommand(command, job)
+
+	case "rate":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: ./wget jobs rate <id> <rate>")
+		}
+		job, err := findJob(args[0])
+		if err != nil {
+			return err
+		}
+		presets, err := parseRatePresets(args[1])
+		if err != nil || len(presets) != 1 {
+			return fmt.Errorf("invalid rate: %s", args[1])
+		}
+		if job.State() != "running" {
+			return fmt.Errorf("job %d is not running", job.ID)
+		}
+		if err := requestRate(job.PID, presets[0]); err != nil {
+			return fmt.Errorf("failed to change the rate of job %d: %w", job.ID, err)
+		}
+		fmt.Printf("Rate limit of job %d set to %s\n", job.ID, formatRate(presets[0]))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown jobs command: %s\n%s", command, jobsUsage)
+	}
+}
+
+func runJobCommand(command string, job *Job) error {
+	switch command {
+	case "status":
+		fmt.Printf("Job:     %d\n", job.ID)
+		fmt.Printf("PID:     %d\n", job.PID)
+		fmt.Printf("State:   %s\n", job.State())
+		fmt.Printf("URL:     %s\n", job.URL)
+		fmt.Printf("Started: %s\n", job.StartedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Log:     %s\n", job.LogFile)
+		if lines, err := lastLines(job.LogFile, 5); err == nil && len(lines) > 0 {
+			fmt.Println("Latest output:")
+			for _, line := range lines {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+
+	case "stop":
+		if job.State() != "running" {
+			return fmt.Errorf("job %d is not running", job.ID)
+		}
+		if err := stopProcess(job.PID); err != nil {
+			return fmt.Errorf("failed to stop job %d: %w", job.ID, err)
+		}
+		fmt.Printf("Stop signal sent to job %d (PID: %d)\n", job.ID, job.PID)
+
+	case "log":
+		file, err := os.Open(job.LogFile)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		defer file.Close()
+		if _, err := io.Copy(os.Stdout, file); err != nil {
+			return fmt.Errorf("failed to read log file: %w", err)
+		}
+
+	case "tail":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return followLog(ctx, job.LogFile, job.alive)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+// mirrorLayout decides where mirrored files go under the mirror directory (-nH, --cut-dirs, -nd)
+// and how they are named (--restrict-file-names). A nil *mirrorLayout is the default
+// host/path/... tree.
+type mirrorLayout struct {
+	noHostDirs bool           // Save directly under -P instead of -P/<hostname>
+	cutDirs    int            // Leading URL path components to drop
+	noDirs     bool           // Save every file in one directory
+	flatten    bool           // Like noDirs, with a hash of the full path in every name (--flatten)
+	rules      *fileNameRules // nil: the platform default
+	linkPrefix string         // Link saved pages as linkPrefix + path instead of relative paths; "" is relative
+
+	mutex sync.Mutex
+	names map[string]string // Full URL path -> local path, so a name taken once stays taken
+	taken map[string]bool
+}
+
+// parseLinkStyle parses --convert-links: relative (the default), or absolute-prefix=PATH for a
+// mirror directory published under PATH. It returns the prefix, "" for relative links.
+func parseLinkStyle(value string) (string, error) {
+	if value == "" || value == "relative" {
+		return "", nil
+	}
+	prefix, ok := strings.CutPrefix(value, "absolute-prefix=")
+	if !ok || prefix == "" {
+		return "", fmt.Errorf("want relative or absolute-prefix=PATH, got '%s'", value)
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix, nil
+}
+
+// linkPrefixName is the file in a mirror directory recording the --convert-links absolute prefix
+// its pages link with, so `wget verify` resolves their links like the run that saved them
+const linkPrefixName = ".wget-link-prefix"
+
+// saveLinkPrefix records prefix in dir, or removes the record for relative links
+func saveLinkPrefix(dir, prefix string) error {
+	path := filepath.Join(dir, linkPrefixName)
+	if prefix == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(prefix+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", linkPrefixName, err)
+	}
+	return nil
+}
+
+// loadLinkPrefix returns the prefix recorded in dir, "" for relative links
+func loadLinkPrefix(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, linkPrefixName))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// prefix returns the --convert-links absolute prefix, or "" for relative links
+func (l *mirrorLayout) prefix() string {
+	if l == nil {
+		return ""
+	}
+	return l.linkPrefix
+}
+
+// hostDirs reports whether the mirror nests under a directory named after the host
+func (l *mirrorLayout) hostDirs() bool {
+	return l == nil || (!l.noHostDirs && !l.noDirs && !l.flatten)
+}
+
+// hostDir names the directory of a mirrored host: its Unicode form, or the punycode one when
+// --restrict-file-names=ascii is given
+func (l *mirrorLayout) hostDir(host string) string {
+	if l == nil || l.rules == nil || !l.rules.ascii {
+		if name, err := idna.ToUnicode(host); err == nil {
+			host = name
+		}
+	}
+	return l.restrict(host)
+}
+
+// restrict applies --restrict-file-names to each component of a slash-separated path
+func (l *mirrorLayout) restrict(rel string) string {
+	var rules *fileNameRules
+	if l != nil {
+		rules = l.rules
+	}
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		parts[i] = rules.escape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// filePath returns the path of u with each component decoded on its own, so that
+// /a%20b/c%C3%A9 is saved as "a b/cé" and an encoded slash (%2F) stays inside its name
+// instead of starting a directory
+func filePath(u *url.URL) string {
+	parts := strings.Split(u.EscapedPath(), "/")
+	for i, part := range parts {
+		if name, err := url.PathUnescape(part); err == nil {
+			parts[i] = strings.ReplaceAll(name, "/", "%2F")
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// linkPath turns a relative file path back into a link to that file. Components are
+// percent-encoded, so names holding spaces, '%', '?' or '#' round-trip to the same file.
+func linkPath(rel string) string {
+	link := (&url.URL{Path: filepath.ToSlash(rel)}).EscapedPath()
+	if first, _, _ := strings.Cut(link, "/"); strings.Contains(first, ":") {
+		link = "./" + link // Keep "a:b.html" from reading as a URL with scheme "a"
+	}
+	return link
+}
+
+// pagePath returns where the resource at urlPath is saved, relative to the mirror directory.
+// Directory URLs and extensionless paths become index.html files.
+func (l *mirrorLayout) pagePath(urlPath string) string {
+	rel := strings.TrimPrefix(urlPath, "/")
+	if strings.HasSuffix(rel, "/") || filepath.Ext(rel) == "" {
+		rel = path.Join(rel, "index.html")
+	}
+	return l.place(rel)
+}
+
+// place maps a slash-separated path inside the site to one inside the mirror directory. Once
+// directories are cut or dropped, or names escaped, two paths can end up with the same name;
+// the later one is kept apart as name.1, name.2, ... like wget does. --flatten names carry a
+// hash of the path instead, as in index-1a2b3c4d.html. Names differing only in
+// case count as the same on Windows.
+func (l *mirrorLayout) place(rel string) string {
+	if l == nil {
+		return filepath.FromSlash(l.restrict(rel))
+	}
+
+	dir, file := path.Split(l.restrict(rel))
+	if l.flatten {
+		// The same path always gets the same name, whatever order the crawl finds it in
+		sum := sha256.Sum256([]byte(rel))
+		ext := path.Ext(file)
+		file = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(file, ext), hex.EncodeToString(sum[:4]), ext)
+	}
+	var parts []string
+	if dir = strings.Trim(dir, "/"); dir != "" && !l.noDirs && !l.flatten {
+		parts = strings.Split(dir, "/")
+		parts = parts[min(l.cutDirs, len(parts)):]
+	}
+	candidate := path.Join(append(parts, file)...)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if name, ok := l.names[rel]; ok {
+		return filepath.FromSlash(name)
+	}
+	if l.names == nil {
+		l.names, l.taken = make(map[string]string), make(map[string]bool)
+	}
+	folded := l.rules.effective().windows
+	key := func(name string) string {
+		if folded {
+			return strings.ToLower(name)
+		}
+		return name
+	}
+	name := candidate
+	for i := 1; l.taken[key(name)]; i++ {
+		name = fmt.Sprintf("%s.%d", candidate, i)
+	}
+	l.names[rel], l.taken[key(name)] = name, true
+	return filepath.FromSlash(name)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// mirrorLockName is the lock file created inside a mirror directory while a run writes to it
+const mirrorLockName = ".wget-lock"
+
+// mirrorLock records who holds a mirror directory
+type mirrorLock struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	URL       string    `json:"url"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// acquireMirrorLock takes the lock on dir for urlStr. A lock left by a process that is no
+// longer running on this host is taken over; any other lock needs force to be stolen.
+func acquireMirrorLock(dir, urlStr string, force bool) (func(), error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory '%s': %w", dir, err)
+	}
+	lockPath := filepath.Join(dir, mirrorLockName)
+	host, _ := os.Hostname()
+	lock := mirrorLock{PID: os.Getpid(), Host: host, URL: urlStr, StartedAt: time.Now()}
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, werr := file.Write(data)
+			cerr := file.Close()
+			if werr != nil || cerr != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("failed to write lock file '%s'", lockPath)
+			}
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file '%s': %w", lockPath, err)
+		}
+
+		var holder mirrorLock
+		content, _ := os.ReadFile(lockPath)
+		parsed := json.Unmarshal(content, &holder) == nil
+		stale := parsed && holder.Host == host && !processAlive(holder.PID)
+
+		switch {
+		case force:
+			fmt.Printf("Stealing lock on '%s' (held by PID %d on %s since %s)\n",
+				dir, holder.PID, holder.Host, holder.StartedAt.Format("2006-01-02 15:04:05"))
+		case stale:
+			fmt.Printf("Removing stale lock on '%s' left by PID %d\n", dir, holder.PID)
+		case parsed:
+			return nil, fmt.Errorf("'%s' is locked by PID %d on %s (mirroring %s since %s); use --force-lock to steal the lock",
+				dir, holder.PID, holder.Host, holder.URL, holder.StartedAt.Format("2006-01-02 15:04:05"))
+		default:
+			return nil, fmt.Errorf("'%s' is locked by an unreadable lock file %s; use --force-lock to steal the lock", dir, lockPath)
+		}
+		if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove lock file '%s': %w", lockPath, err)
+		}
+	}
+	return nil, fmt.Errorf("failed to acquire lock on '%s': another run took it", dir)
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -14,6 +16,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,30 +26,114 @@ import (
 	"golang.org/x/net/html"
 )
 
+// Bounds and default for --buffer-size
+const (
+	defaultBufferSize = 32 * 1024
+	minBufferSize     = 4 * 1024
+	maxBufferSize     = 64 * 1024 * 1024
+)
+
 // WgetClone represents the main application
 type WgetClone struct {
 	client        *http.Client
+	transport     *http.Transport // Base transport under any wrapping RoundTrippers
+	serviceClient *http.Client    // For webhooks and --sync-to uploads: no cookies, headers or credentials
+	cookies       *persistentJar
+	ctx           context.Context // Cancelled on the first interrupt to stop all transfers
+	cancel        context.CancelFunc
 	interrupted   bool
 	mutex         sync.RWMutex
 	mirrorBaseDir string
-	visitedMutex  sync.RWMutex // For visited map synchronization
+	verifyLinks   bool       // Check rewritten local links after mirroring
+	extractData   bool       // Save data: URIs found while mirroring as separate files
+	forceLock     bool       // Take over the mirror directory lock even if another run holds it
+	sizes         sizeFilter // Skip resources outside --min-filesize/--max-filesize
+	mimes         mimeFilter // Skip resources by Content-Type (--accept-mime/--reject-mime)
+	bloomRate     float64    // Track visited URLs in a Bloom filter with this false-positive rate; 0 keeps them all
+	bloomCapacity int        // URLs the Bloom filter is sized for
+	memoryLimit   int64      // Bytes of bodies and queued URLs a mirror may hold before it slows down; 0 is unlimited
+	maxPages      int        // Stop queueing new mirror URLs once this many were queued; 0 is unlimited
+	status        *StatusTracker
+	pause         PauseGate // Suspends transfer reads while paused
+
+	continueDownloads bool   // Resume .part files left by earlier runs
+	followLog         bool   // Stream the log of a -B download instead of returning immediately
+	queueFile         string // Persist -i progress here so re-runs skip completed URLs
+	relativeOnly      bool   // Follow only links without a scheme or host while mirroring (--relative)
+	ignoreRobotsMeta  bool   // Follow and save pages whatever their robots meta tags and X-Robots-Tag say
+	ignoreCrawlDelay  bool   // Don't space out mirror requests by robots.txt Crawl-delay
+	canonical         bool   // Save pages under the same-host rel=canonical URL they declare
+	sitemap           bool   // Write sitemap.xml and sitemap.html for the saved pages after mirroring
+	sitemapURL        string // Where the mirror is republished, for sitemap URLs; "" is the mirrored site
+	changeReport      string // Write what changed since the last mirror run here as JSON, and list it (--change-report)
+	crawlOrder        string // Order mirrored URLs are fetched in: bfs, dfs or priority
+	contentOnError    bool   // Save the body of 4xx/5xx responses instead of discarding it
+	deleteAfter       bool   // Remove each file once downloaded, e.g. to prime a caching proxy
+	noConvert         bool   // Save pages as served, without rewriting their links (--no-convert-links)
+	backupConverted   bool   // Keep the original of every page whose links were rewritten as .orig (-K)
+	ignoreLength      bool   // Distrust Content-Length for progress and completeness (--ignore-length)
+	autoDecompress    bool   // Unpack .gz, .bz2, .xz and .zst downloads as they arrive (--auto-decompress)
+	extract           bool   // Extract downloaded archives (--extract)
+	extractDir        string // Where --extract puts them; "" is a directory named after each archive
+	deleteArchive     bool   // Remove archives once extracted (--delete-archive)
+	bufferSize        int    // Bytes per read from the network and per batched write to disk; 0 means the default
+	tries             int    // Attempts per download, counting the first (--tries)
+
+	writtenMutex sync.Mutex
+	writtenFiles map[string]bool // Files saved by this run, tracked for --checksum-manifest when non-nil
+
+	signature *signatureCheck // Verify downloads against a detached OpenPGP signature when set
+	stats     *runStats       // Totals for --stats and --stats-json; nil when not requested
+	telemetry *telemetry      // OTLP trace and metrics export; nil when not requested
+	timing    bool            // Print a DNS/connect/TLS/server/transfer breakdown after each download
+	layout    *mirrorLayout   // Where mirrored files go under mirrorBaseDir; nil is host/path/...
+	rewrites  urlRewriter     // --rewrite-rule rules applied to discovered URLs
+	delays    *crawlDelays    // robots.txt Crawl-delay per host while mirroring; nil with --ignore-crawl-delay
+	aliases   *linkAliases    // Local paths of pages saved under another name, for link rewriting
+	urlMap    *urlMap         // Outcome and local path of every URL, for --url-map; nil when not requested
+	hosts     *hostStats      // Per-host requests, bytes, errors and latency for the mirror summary
+	excludeRE urlPatterns     // Skip discovered URLs matching any --exclude-regex
+	sync      *mirrorSync     // Uploads mirrored files to --sync-to as they are saved; nil when not requested
+	oauth     *oauthTransport // Sends the --oauth-token-url bearer token to the start URLs' hosts; nil when not requested
+	dns       *dnsPrefetcher  // Resolves the hosts of queued URLs ahead of their requests; nil with --no-dns-prefetch
+
+	entryHeaders map[string]*entryHeaders // Headers of -i lines, by URL
+
+	crawlTimeout time.Duration // Start no new mirror URLs after this long and save the rest for a re-run; 0 is no limit
 }
 
 // NewWgetClone creates a new instance
 func NewWgetClone() *WgetClone {
+	// s3:// and gs:// URLs are served by the same client so they share the download machinery
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	objectStore := &objectStoreTransport{base: transport}
+	transport.RegisterProtocol("s3", objectStore)
+	transport.RegisterProtocol("gs", objectStore)
+
+	jar := newPersistentJar()
 	client := &http.Client{
+		Transport: transport,
+		Jar:       jar, // Keep session cookies across requests, e.g. after --login-url
 		// No timeout - let downloads run as long as needed
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &WgetClone{
-		client: client,
-		// visitedMutex is automatically initialized as zero value
+		client:        client,
+		transport:     transport,
+		serviceClient: &http.Client{Transport: transport},
+		cookies:       jar,
+		ctx:           ctx,
+		cancel:        cancel,
+		status:        NewStatusTracker(),
 	}
 }
 
-// SetupSignalHandling sets up graceful shutdown
+// SetupSignalHandling sets up graceful shutdown.
+// The first signal cancels in-flight transfers and lets them clean up; the second one force-quits.
 func (w *WgetClone) SetupSignalHandling() {
-	c := make(chan os.Signal, 1)
+	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
@@ -54,6 +141,10 @@ func (w *WgetClone) SetupSignalHandling() {
 		w.mutex.Lock()
 		w.interrupted = true
 		w.mutex.Unlock()
+		w.cancel()
+		fmt.Println("\nInterrupt received, stopping downloads (press Ctrl+C again to force quit)")
+
+		<-c
 		fmt.Println("\nDownload interrupted by user")
 		os.Exit(1)
 	}()
@@ -68,13 +159,20 @@ func (w *WgetClone) IsInterrupted() bool {
 
 // ProgressWriter wraps an io.Writer to show download progress
 type ProgressWriter struct {
-	writer      io.Writer
-	total       int64
-	written     int64
-	filename    string
-	startTime   time.Time
-	lastUpdate  time.Time
-	barWidth    int
+	writer     io.Writer
+	total      int64
+	written    int64 // Guarded by stdoutMutex once the transfer starts
+	filename   string
+	startTime  time.Time
+	lastUpdate time.Time
+	speed      speedWindow
+	statsWidth int // Widest stats text so far, so the bar doesn't jitter as numbers change
+
+	// Dot and line style progress state
+	dotsStarted bool
+	dotsShown   int64     // Bytes already drawn as dots
+	lineStart   time.Time // When the current line of dots (or status line) began
+	lineBytes   int64     // Bytes received when the current line began
 	isMirroring bool
 }
 
@@ -85,98 +183,111 @@ func NewProgressWriter(writer io.Writer, total int64, filename string, isMirrori
 		filename:    filename,
 		startTime:   time.Now(),
 		lastUpdate:  time.Now(),
-		barWidth:    50,
 		isMirroring: isMirroring,
 	}
 }
 
 func (p *ProgressWriter) Write(data []byte) (int, error) {
 	n, err := p.writer.Write(data)
-	p.written += int64(n)
-
-	if !p.isMirroring { // Only show real-time progress for single non-mirror downloads
-		// Update progress every 100ms
-		if time.Since(p.lastUpdate) > 100*time.Millisecond {
-			p.showProgress()
-			p.lastUpdate = time.Now()
-		}
-	}
-
+	p.Add(int64(n))
 	return n, err
 }
 
 var stdoutMutex sync.Mutex // Mutex for stdout synchronization
 
-func (p *ProgressWriter) showProgress() {
-	if p.isMirroring {
-		// For mirroring, we just want to print a final message, not continuous updates
+// Add counts n more bytes and redraws the bar, at most every 100ms
+func (p *ProgressWriter) Add(n int64) {
+	if p.isMirroring { // Only show real-time progress for single non-mirror downloads
+		p.written += n
 		return
 	}
 
 	// Lock stdout to prevent concurrent writes from interfering
 	stdoutMutex.Lock()
 	defer stdoutMutex.Unlock()
+	// Set up before counting n, so bytes resumed from earlier runs aren't counted as received
+	if currentProgressStyle.name == "dot" && !p.dotsStarted {
+		p.startDots()
+	}
+	if p.lineStart.IsZero() {
+		p.lineStart, p.lineBytes = p.startTime, p.written
+	}
+	p.written += n
+	switch currentProgressStyle.name {
+	case "dot":
+		p.renderDots()
+	case "bar":
+		if time.Since(p.lastUpdate) > 100*time.Millisecond {
+			p.render()
+			p.lastUpdate = time.Now()
+		}
+	case "lines":
+		if time.Since(p.lastUpdate) >= progressLineInterval {
+			p.renderLine()
+			p.lastUpdate = time.Now()
+		}
+	}
+}
+
+// render draws the in-progress line sized to the terminal; stdoutMutex must be held
+func (p *ProgressWriter) render() {
+	watchTerminalResize()
+	activeProgress = p
+
+	now := time.Now()
+	p.speed.add(now, p.written)
+	rate := formatSpeed(p.speed.rate())
 
 	fmt.Print("\r\033[K")
-	if p.total > 0 {
-		percentage := float64(p.written) / float64(p.total) * 100
-		elapsed := time.Since(p.startTime)
-		speed := float64(p.written) / elapsed.Seconds()
-
-		// Visual progress bar
-		barProgress := int(float64(p.barWidth) * percentage / 100)
-		bar := strings.Repeat("=", barProgress)
-		if barProgress < p.barWidth {
-			bar += ">" + strings.Repeat(" ", p.barWidth-barProgress-1)
-		}
-
-		fmt.Printf("%s %3.0f%% [%s] %s/%s %.2fKB/s",
-			p.filename,
-			percentage,
-			bar,
-			formatBytes(p.written),
-			formatBytes(p.total),
-			speed/1024)
-	} else {
-		elapsed := time.Since(p.startTime)
-		speed := float64(p.written) / elapsed.Seconds()
-		fmt.Printf("%s %s %.2fKB/s",
-			p.filename,
-			formatBytes(p.written),
-			speed/1024)
+	if p.total <= 0 {
+		fmt.Print(fitProgressLine(p.filename, "", -1, fmt.Sprintf(" %s %s", formatBytes(p.written), rate)))
+		return
+	}
+
+	fraction := float64(p.written) / float64(p.total)
+	stats := fmt.Sprintf(" %s/%s %s", formatBytes(p.written), formatBytes(p.total), rate)
+	if bps := p.speed.rate(); bps > 0 && p.written < p.total {
+		stats += " eta " + formatETA(time.Duration(float64(p.total-p.written)/bps*float64(time.Second)))
 	}
+	p.statsWidth = max(p.statsWidth, len(stats))
+	stats = fmt.Sprintf("%-*s", p.statsWidth, stats)
+	fmt.Print(fitProgressLine(p.filename, fmt.Sprintf(" %3.0f%%", fraction*100), fraction, stats))
 }
 
 func (p *ProgressWriter) Finish() {
 	stdoutMutex.Lock()
 	defer stdoutMutex.Unlock()
+	if activeProgress == p {
+		activeProgress = nil
+	}
+
+	switch {
+	case p.isMirroring:
+		// For mirroring, just print a simple line completion
+		fmt.Printf("Downloaded: %s\n", p.filename)
+	case currentProgressStyle.name == "dot":
+		p.renderDots()
+		p.endDotLine(true)
+	case currentProgressStyle.name == "bar" || currentProgressStyle.name == "lines":
+		// Clear the current line and show final progress with the average speed
+		elapsed := time.Since(p.startTime)
+		done := formatBytes(p.written)
+		if p.total > 0 {
+			done += "/" + formatBytes(p.total)
+		}
+		stats := fmt.Sprintf(" %s %s in %s", done, formatSpeed(float64(p.written)/elapsed.Seconds()), formatETA(elapsed))
 
-	if !p.isMirroring {
-		// Clear the current line and show final progress
+		if currentProgressStyle.name == "lines" {
+			fmt.Println(p.filename + p.percent() + stats)
+			return
+		}
 		fmt.Print("\r\033[K")
 		if p.total > 0 {
-			percentage := float64(p.written) / float64(p.total) * 100
-			elapsed := time.Since(p.startTime)
-			speed := float64(p.written) / elapsed.Seconds()
-
-			fmt.Printf("%s %3.0f%% [%s] %s/%s %.2fKB/s\n",
-				p.filename,
-				percentage,
-				strings.Repeat("=", p.barWidth),
-				formatBytes(p.written),
-				formatBytes(p.total),
-				speed/1024)
+			fraction := float64(p.written) / float64(p.total)
+			fmt.Println(fitProgressLine(p.filename, fmt.Sprintf(" %3.0f%%", fraction*100), fraction, stats))
 		} else {
-			elapsed := time.Since(p.startTime)
-			speed := float64(p.written) / elapsed.Seconds()
-			fmt.Printf("%s %s %.2fKB/s\n",
-				p.filename,
-				formatBytes(p.written),
-				speed/1024)
+			fmt.Println(fitProgressLine(p.filename, "", -1, stats))
 		}
-	} else {
-		// For mirroring, just print a simple line completion
-		fmt.Printf("Downloaded: %s\n", p.filename)
 	}
 }
 
@@ -223,63 +334,161 @@ func parseRateLimit(rateLimitStr string) (int64, error) {
 	return int64(value), nil
 }
 
-// RateLimitedReader wraps an io.Reader to limit read speed
-type RateLimitedReader struct {
-	reader    io.Reader
-	rateLimit int64
-	lastRead  time.Time
-}
+// outputPathFor determines where a download of urlStr is saved based on mirroring logic.
+// When mirroring, an explicit outputPath (already inside the mirror) takes precedence.
+func (w *WgetClone) outputPathFor(urlStr, outputPath, directory string, isMirroring bool) string {
+	finalOutputPath := outputPath
+	if isMirroring && outputPath == "" {
+		parsedURL, _ := url.Parse(urlStr)
+		// Combine with the base mirroring directory
+		finalOutputPath = filepath.Join(w.mirrorBaseDir, w.layout.pagePath(filePath(parsedURL)))
+	} else if outputPath == "" {
+		parsedURL, _ := url.Parse(urlStr)
+		finalOutputPath = path.Base(filePath(parsedURL))
+		if finalOutputPath == "" || finalOutputPath == "/" || finalOutputPath == "." {
+			finalOutputPath = "index.html"
+		}
+		finalOutputPath = w.layout.restrict(finalOutputPath)
+	}
 
-func NewRateLimitedReader(reader io.Reader, rateLimit int64) *RateLimitedReader {
-	return &RateLimitedReader{
-		reader:    reader,
-		rateLimit: rateLimit,
-		lastRead:  time.Now(),
+	if directory != "" && !isMirroring {
+		finalOutputPath = filepath.Join(directory, finalOutputPath)
 	}
+	return longPath(finalOutputPath)
 }
 
-func (r *RateLimitedReader) Read(p []byte) (int, error) {
-	n, err := r.reader.Read(p)
-
-	if r.rateLimit > 0 && n > 0 {
-		expectedDuration := time.Duration(int64(n)*int64(time.Second)) / time.Duration(r.rateLimit)
-		elapsed := time.Since(r.lastRead)
-
-		if elapsed < expectedDuration {
-			time.Sleep(expectedDuration - elapsed)
-		}
-		r.lastRead = time.Now()
+// copySize returns the --buffer-size, or the default when it wasn't given
+func (w *WgetClone) copySize() int {
+	if w.bufferSize > 0 {
+		return w.bufferSize
 	}
-
-	return n, err
+	return defaultBufferSize
 }
 
 // DownloadFile downloads a single file
 func (w *WgetClone) DownloadFile(urlStr, outputPath, directory string, rateLimit int64, isMirroring bool) error {
+	var err error
+	savedPath := w.outputPathFor(urlStr, outputPath, directory, isMirroring)
+	dec := w.decompressorFor(urlStr, isMirroring)
+	if dec != nil {
+		savedPath = dec.target(savedPath)
+	}
+	// A transfer cut off by --max-download-time or --read-timeout starts over, up to --tries times
+	for attempt := 1; ; attempt++ {
+		if dec != nil {
+			err = w.downloadDecompressed(urlStr, savedPath, rateLimit, dec)
+		} else {
+			err = w.downloadFile(urlStr, outputPath, directory, rateLimit, isMirroring)
+		}
+		timedOut := errors.Is(err, errTransferDeadline) || errors.Is(err, errTransferStalled)
+		if !timedOut || attempt >= w.tries || w.IsInterrupted() {
+			break
+		}
+		fmt.Printf("\nRetrying %s (attempt %d/%d) after error: %v\n", urlStr, attempt+1, w.tries, err)
+	}
+	if err == nil && w.extract && !isMirroring && !w.deleteAfter {
+		err = w.extractDownload(savedPath)
+	}
+	w.stats.fileDone(err)
+	if w.urlMap != nil {
+		if err != nil || w.deleteAfter {
+			savedPath = ""
+		}
+		w.urlMap.record(urlStr, savedPath, err)
+	}
+	return err
+}
+
+func (w *WgetClone) downloadFile(urlStr, outputPath, directory string, rateLimit int64, isMirroring bool) error {
 	// For mirroring, suppress initial download messages to avoid clutter
 	if !isMirroring {
 		startTime := time.Now()
 		fmt.Printf("Starting download at %s\n", startTime.Format("2006-01-02 15:04:05"))
 	}
 
-	req, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
+	if _, err := url.Parse(urlStr); err != nil {
 		return fmt.Errorf("invalid URL: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Go-Wget-Clone/1.0")
+	finalOutputPath := w.outputPathFor(urlStr, outputPath, directory, isMirroring)
 
-	resp, err := w.client.Do(req)
+	// Write into a .part file and only move it into place once the transfer completes,
+	// so an interrupted download never leaves a truncated file under the final name
+	partPath := finalOutputPath + ".part"
+
+	// With -c, pick up where a previous run stopped
+	var offset int64
+	var state *resumeState
+	if w.continueDownloads {
+		offset, state = loadResumeState(partPath, urlStr)
+	}
+	validator := ""
+	if state != nil {
+		validator = state.validator()
+	}
+
+	var timing *requestTiming
+	if w.timing || w.stats != nil {
+		timing = &requestTiming{url: urlStr}
+	}
+	resp, err := w.requestRange(urlStr, offset, validator, timing)
 	if err != nil {
+		if w.IsInterrupted() {
+			return fmt.Errorf("download interrupted")
+		}
 		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if !isMirroring {
+			fmt.Printf("Resuming '%s' at %s\n", partPath, formatBytes(offset))
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file already holds the whole resource
+		removeResumeState(partPath)
+		if err := os.Rename(partPath, finalOutputPath); err != nil {
+			return fmt.Errorf("failed to move '%s' into place: %w", partPath, err)
+		}
+		w.recordWritten(finalOutputPath)
+		if w.signature != nil && !isMirroring {
+			if err := w.verifySignature(finalOutputPath, urlStr); err != nil {
+				return err
+			}
+		}
+		if !isMirroring {
+			fmt.Printf("File already fully retrieved: %s\n", finalOutputPath)
+		}
+		return nil
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 && !isMirroring {
+			fmt.Println("Server ignored the resume request, restarting from zero")
+		}
+		offset = 0
+	default:
+		if w.contentOnError && resp.StatusCode >= 400 && !w.deleteAfter {
+			if err := w.saveErrorBody(resp, finalOutputPath); err != nil {
+				return err
+			}
+		}
 		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
 
+	if w.ignoreLength {
+		resp.ContentLength = -1 // Read to the end of the body whatever the header claims
+	}
 	initialContentLength := resp.ContentLength
+	announced := int64(-1)
+	if initialContentLength >= 0 {
+		announced = offset + initialContentLength
+	}
+	if err := w.sizes.check(announced); err != nil {
+		return err
+	}
+	if err := w.mimes.check(resp.Header.Get("Content-Type")); err != nil {
+		return err
+	}
 
 	// For mirroring, suppress content details
 	if !isMirroring {
@@ -289,91 +498,151 @@ func (w *WgetClone) DownloadFile(urlStr, outputPath, directory string, rateLimit
 		}
 	}
 
-	// Determine output path based on mirroring logic
-	finalOutputPath := outputPath
-	if isMirroring {
-		parsedURL, _ := url.Parse(urlStr)
-		relativeURLPath := strings.TrimPrefix(parsedURL.Path, "/")
-		if strings.HasSuffix(relativeURLPath, "/") || filepath.Ext(relativeURLPath) == "" {
-			relativeURLPath = filepath.Join(relativeURLPath, "index.html")
-		}
-		finalOutputPath = filepath.Join(w.mirrorBaseDir, parsedURL.Hostname(), relativeURLPath)
-	} else if outputPath == "" {
-		parsedURL, _ := url.Parse(urlStr)
-		finalOutputPath = path.Base(parsedURL.Path)
-		if finalOutputPath == "" || finalOutputPath == "/" {
-			finalOutputPath = "index.html"
-		}
-	}
-
-	if directory != "" && !isMirroring {
-		if err := os.MkdirAll(directory, 0o755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
-		}
-		finalOutputPath = filepath.Join(directory, finalOutputPath)
-	}
-
 	// Ensure the directory for the output path exists
 	dir := filepath.Dir(finalOutputPath)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("failed to create directory '%s': %w", dir, err)
 	}
 
-	// Create output file (before reading body to avoid re-reading for HTML rewrite)
-	file, err := os.Create(finalOutputPath)
+	var file *os.File
+	if offset > 0 {
+		file, err = os.OpenFile(partPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	} else {
+		file, err = os.Create(partPath)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create file '%s': %w", finalOutputPath, err)
+		return fmt.Errorf("failed to create file '%s': %w", partPath, err)
 	}
 	defer file.Close()
+	if err := preallocate(file, offset, initialContentLength); err != nil {
+		if offset == 0 {
+			file.Close()
+			os.Remove(partPath)
+		}
+		return fmt.Errorf("failed to allocate %s for '%s': %w", formatBytes(initialContentLength), partPath, err)
+	}
+
+	// Checkpoint the validators so a later -c run only continues the same resource
+	state = &resumeState{
+		URL:          urlStr,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Total:        offset + initialContentLength,
+		Offset:       offset,
+	}
+	if err := saveResumeState(partPath, state); err != nil {
+		return fmt.Errorf("failed to write checkpoint for '%s': %w", partPath, err)
+	}
+
+	body := &resumableBody{
+		w:         w,
+		url:       urlStr,
+		validator: state.validator(),
+		resp:      resp,
+		offset:    offset,
+		total:     announced,
+		ranges:    resp.StatusCode == http.StatusPartialContent || resp.Header.Get("Accept-Ranges") == "bytes",
+	}
+	defer body.Close()
 
 	// Set up progress tracking and rate limiting
-	var reader io.Reader = resp.Body
-	if rateLimit > 0 {
-		reader = NewRateLimitedReader(reader, rateLimit)
+	reader, done := w.status.Track(urlStr, initialContentLength, body)
+	defer done()
+	reader = NewRateLimitedReader(reader, rateLimit) // Even unlimited, so a rate change reaches it
+	if announced < 0 {
+		reader = w.sizes.limit(reader)
 	}
 
 	// Initialize progress *before* io.Copy, using the captured initialContentLength
-	progress := NewProgressWriter(file, initialContentLength, filepath.Base(finalOutputPath), isMirroring)
+	total := initialContentLength
+	if total > 0 {
+		total += offset
+	}
+	// Gather network reads into bufferSize writes, so small TLS records don't each cost a syscall
+	batched := bufio.NewWriterSize(file, w.copySize())
+	var out io.Writer = batched
+	if timing != nil {
+		out = &diskTimer{writer: batched, timing: timing}
+	}
+	progress := NewProgressWriter(out, total, filepath.Base(finalOutputPath), isMirroring)
+	progress.written = offset
 
 	// Copy with progress
-	written, err := io.Copy(progress, reader) // This will read the body and write to the file
-	progress.Finish()                         // This will print a simple "Downloaded: X" if mirroring
+	written, err := io.CopyBuffer(progress, reader, make([]byte, w.copySize())) // This will read the body and write to the file
+	progress.Finish()                                                           // This will print a simple "Downloaded: X" if mirroring
+	if timing != nil {
+		timing.finish()
+	}
 
+	flushErr := batched.Flush()          // Flush whatever was received, even on failure
+	written -= int64(batched.Buffered()) // Anything a failed flush left behind isn't in the file
+	closeErr := file.Close()
+	if closeErr == nil {
+		closeErr = flushErr
+	}
+	if err == nil && announced < 0 {
+		err = w.sizes.check(offset + written)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) && announced >= 0 {
+		err = fmt.Errorf("connection closed after %s of %s", formatBytes(offset+written), formatBytes(announced))
+	}
+	if errors.Is(err, errFiltered) {
+		os.Remove(partPath)
+		removeResumeState(partPath)
+		return err
+	}
 	if err != nil {
+		state.Offset = offset + written
+		saveResumeState(partPath, state)
 		if w.IsInterrupted() {
-			return fmt.Errorf("download interrupted")
+			return fmt.Errorf("download interrupted, partial file kept at '%s' (resume with -c)", partPath)
 		}
 		return fmt.Errorf("download failed: %w", err)
 	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to write file '%s': %w", partPath, closeErr)
+	}
+	removeResumeState(partPath)
+	if err := os.Rename(partPath, finalOutputPath); err != nil {
+		return fmt.Errorf("failed to move '%s' into place: %w", partPath, err)
+	}
+	w.recordWritten(finalOutputPath)
+	if w.signature != nil && !isMirroring {
+		if err := w.verifySignature(finalOutputPath, urlStr); err != nil {
+			return err
+		}
+	}
+
+	if w.deleteAfter {
+		if err := os.Remove(finalOutputPath); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", finalOutputPath, err)
+		}
+		if !isMirroring {
+			fmt.Printf("Removed '%s' (--delete-after)\n", finalOutputPath)
+		}
+	}
 
 	if !isMirroring {
 		endTime := time.Now()
-		fmt.Printf("Downloaded successfully: %s\n", urlStr)
+		fmt.Printf("%s %s\n", colorize(colorGreen, "Downloaded successfully:"), urlStr)
 		fmt.Printf("Finished at %s\n", endTime.Format("2006-01-02 15:04:05"))
 		fmt.Printf("Total downloaded: %s\n", formatBytes(written))
 	}
+	if timing != nil {
+		w.stats.timed(timing)
+		if w.timing {
+			fmt.Println(timing)
+		}
+	}
 
 	return nil
 }
 
-// BackgroundDownload starts a download in the background
-func (w *WgetClone) BackgroundDownload(urlStr, outputPath, directory string, rateLimit string) error {
-	logFile := "wget-log"
-
-	args := []string{os.Args[0], urlStr}
-	if outputPath != "" {
-		args = append(args, "-O", outputPath)
-	}
-	if directory != "" {
-		args = append(args, "-P", directory)
-	}
-	if rateLimit != "" {
-		args = append(args, "--rate-limit", rateLimit)
-	}
-
-	cmd := exec.Command(args[0], args[1:]...)
+// BackgroundDownload starts a download in the background by re-running this command with args
+func (w *WgetClone) BackgroundDownload(urlStr string, args []string) error {
+	cmd := exec.Command(os.Args[0], args...)
 
-	logFileHandle, err := os.Create(logFile)
+	logFileHandle, logFile, err := createJobLog("wget-log")
 	if err != nil {
 		return fmt.Errorf("failed to create log file: %w", err)
 	}
@@ -381,6 +650,7 @@ func (w *WgetClone) BackgroundDownload(urlStr, outputPath, directory string, rat
 
 	cmd.Stdout = logFileHandle
 	cmd.Stderr = logFileHandle
+	detachProcess(cmd) // Keep Ctrl+C in this terminal from reaching the download
 
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start background process: %w", err)
@@ -389,15 +659,61 @@ func (w *WgetClone) BackgroundDownload(urlStr, outputPath, directory string, rat
 	fmt.Printf("Background download started (PID: %d)\n", cmd.Process.Pid)
 	fmt.Printf("Output will be written to '%s'\n", logFile)
 
+	job, err := registerJob(cmd.Process.Pid, urlStr, args, logFile)
+	if err != nil {
+		fmt.Printf("Warning: job not tracked: %v\n", err)
+	} else {
+		fmt.Printf("Track it with './wget jobs status %d'\n", job.ID)
+	}
+
+	if w.followLog {
+		exited := make(chan struct{})
+		go func() {
+			cmd.Wait()
+			close(exited)
+		}()
+		running := func() bool {
+			select {
+			case <-exited:
+				return false
+			default:
+				return true
+			}
+		}
+
+		if err := followLog(w.ctx, logFile, running); err != nil {
+			return err
+		}
+		if running() {
+			fmt.Printf("\nStopped following '%s', download continues in background (PID: %d)\n", logFile, cmd.Process.Pid)
+		}
+	}
+
 	return nil
 }
 
 // DownloadMultipleFiles downloads multiple files concurrently
 func (w *WgetClone) DownloadMultipleFiles(urls []string, maxConcurrent int, directory string, rateLimit int64) error {
+	var queue *DownloadQueue
+	if w.queueFile != "" {
+		all := urls
+		var err error
+		queue, urls, err = OpenDownloadQueue(w.queueFile, urls)
+		if err != nil {
+			return err
+		}
+		w.recordQueuedDone(all, urls, directory)
+		if len(urls) == 0 {
+			fmt.Printf("All URLs in queue '%s' are already downloaded\n", w.queueFile)
+			queue.Close(true)
+			return nil
+		}
+	}
+
 	sem := make(chan struct{}, maxConcurrent)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	successful := 0
+	successful, skipped, failed := 0, 0, 0
 
 	fmt.Printf("Starting concurrent download of %d files with %d max concurrency...\n", len(urls), maxConcurrent)
 
@@ -408,34 +724,73 @@ func (w *WgetClone) DownloadMultipleFiles(urls []string, maxConcurrent int, dire
 		}
 
 		wg.Add(1)
+		w.status.AddPending(1)
 		go func(url string) {
 			defer wg.Done()
+			defer w.status.AddPending(-1)
+
+			w.dns.prefetch(url) // Resolved while earlier downloads hold the slots
 
 			sem <- struct{}{}        // Acquire semaphore
 			defer func() { <-sem }() // Release semaphore
 
+			if w.IsInterrupted() {
+				return // Don't start new transfers after an interrupt
+			}
+
 			// For concurrent downloads, we don't pass `isMirroring=true` to DownloadFile
 			// because they are individual files, not part of a recursive mirror.
-			if err := w.DownloadFile(url, "", directory, rateLimit, false); err != nil {
-				fmt.Printf("Error downloading %s: %v\n", url, err)
+			if err := w.DownloadFile(url, "", directory, rateLimit, false); errors.Is(err, errFiltered) {
+				fmt.Printf("%s %s: %v\n", colorize(colorYellow, "Skipped"), url, err)
+				mu.Lock()
+				skipped++
+				mu.Unlock()
+				if queue != nil {
+					queue.MarkDone(url)
+				}
+			} else if err != nil {
+				fmt.Printf("%s %s: %v\n", colorize(colorRed, "Error downloading"), url, err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
 			} else {
 				mu.Lock()
 				successful++
 				mu.Unlock()
-				fmt.Printf("Finished: %s\n", url)
+				if queue != nil {
+					if err := queue.MarkDone(url); err != nil {
+						fmt.Printf("Warning: failed to update queue file: %v\n", err)
+					}
+				}
+				fmt.Printf("%s %s\n", colorize(colorGreen, "Finished:"), url)
 			}
 		}(urlStr)
 	}
 
 	wg.Wait()
 	fmt.Printf("\nDownload summary: %d/%d files downloaded successfully\n", successful, len(urls))
+	if skipped > 0 {
+		fmt.Printf("Skipped %d files excluded by filters\n", skipped)
+	}
+
+	if queue != nil {
+		allDone := successful+skipped == len(urls)
+		queue.Close(allDone)
+		if !allDone {
+			fmt.Printf("Re-run with --queue-file %s to retry the remaining URLs\n", w.queueFile)
+		}
+	}
 
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to download", failed, len(urls))
+	}
 	return nil
 }
 
 // HTML rewriting utility
-// rewriteHTML adjusts relative/absolute paths in HTML to be local
-func rewriteHTML(content string, currentURL, baseURL string) (string, error) {
+// rewriteHTML adjusts relative/absolute paths in HTML to be local, as laid out by layout
+// and redirected by aliases
+func rewriteHTML(content string, currentURL, baseURL string, layout *mirrorLayout, rewrites urlRewriter, aliases *linkAliases) (string, error) {
 	doc, err := html.Parse(strings.NewReader(content))
 	if err != nil {
 		return "", fmt.Errorf("failed to parse HTML: %w", err)
@@ -458,31 +813,32 @@ func rewriteHTML(content string, currentURL, baseURL string) (string, error) {
 
 				if attrToRewrite {
 					originalVal := a.Val
+					if uri, err := toURI(originalVal); err == nil {
+						originalVal = uri
+					}
 					parsedLink, err := url.Parse(originalVal)
 					if err != nil {
 						continue
 					}
-					resolvedURL := currentParsedURL.ResolveReference(parsedLink)
+					// Point at where --rewrite-rule sent the crawl
+					resolvedURL := rewrites.applyURL(currentParsedURL.ResolveReference(parsedLink))
 					if resolvedURL.Hostname() == baseParsedURL.Hostname() {
-						relativePath := strings.TrimPrefix(resolvedURL.Path, "/")
-						if strings.HasSuffix(relativePath, "/") || filepath.Ext(relativePath) == "" {
-							relativePath = filepath.Join(relativePath, "index.html")
-						}
-
-						currentRelativePath := strings.TrimPrefix(currentParsedURL.Path, "/")
-						if strings.HasSuffix(currentRelativePath, "/") || filepath.Ext(currentRelativePath) == "" {
-							currentRelativePath = filepath.Join(currentRelativePath, "index.html")
-						}
+						relativePath := aliases.resolve(layout.pagePath(filePath(resolvedURL)))
+						currentRelativePath := aliases.resolve(layout.pagePath(filePath(currentParsedURL)))
 
 						// Calculate relative path from current file to target file
 						relPath, err := filepath.Rel(filepath.Dir(currentRelativePath), relativePath)
-						if err == nil {
-							a.Val = relPath
-							n.Attr[i] = a
+						if prefix := layout.prefix(); prefix != "" {
+							a.Val = prefix + strings.TrimPrefix(linkPath(relativePath), "./")
+						} else if err == nil {
+							a.Val = linkPath(relPath)
 						} else {
-							a.Val = "/" + relativePath
-							n.Attr[i] = a
+							a.Val = "/" + linkPath(relativePath)
 						}
+						if resolvedURL.Fragment != "" {
+							a.Val += "#" + resolvedURL.EscapedFragment()
+						}
+						n.Attr[i] = a
 					}
 				}
 			}
@@ -502,8 +858,8 @@ func rewriteHTML(content string, currentURL, baseURL string) (string, error) {
 	return buf.String(), nil
 }
 
-// extractLinks extracts links from HTML content
-func extractLinks(htmlContent, baseURL string) ([]string, error) {
+// extractLinks extracts links from HTML content, only those without a scheme or host when relativeOnly is set
+func extractLinks(htmlContent, baseURL string, relativeOnly bool) ([]string, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, err
@@ -526,7 +882,14 @@ func extractLinks(htmlContent, baseURL string) ([]string, error) {
 			if attrName != "" {
 				for _, attr := range n.Attr {
 					if attr.Key == attrName {
-						if fullURL, err := url.Parse(attr.Val); err == nil {
+						link := attr.Val
+						if uri, err := toURI(link); err == nil {
+							link = uri
+						}
+						if fullURL, err := url.Parse(link); err == nil {
+							if relativeOnly && (fullURL.Scheme != "" || fullURL.Host != "") {
+								break
+							}
 							if base, err := url.Parse(baseURL); err == nil {
 								resolved := base.ResolveReference(fullURL)
 
@@ -587,10 +950,9 @@ func shouldReject(urlStr string, reject, exclude []string) bool {
 	return false
 }
 
-// MirrorWebsite mirrors a website recursively
-func (w *WgetClone) MirrorWebsite(urlStr, baseURL string, visited map[string]bool, reject, exclude []string, maxDepth, currentDepth int, wg *sync.WaitGroup, sem chan struct{}) {
-	defer wg.Done()          // Decrement counter when goroutine finishes
-	defer func() { <-sem }() // Always release semaphore
+// MirrorWebsite fetches one URL of a mirror and queues the links of pages on frontier
+func (w *WgetClone) MirrorWebsite(urlStr, baseURL string, reject, exclude []string, maxDepth, currentDepth int, frontier *crawlFrontier) {
+	defer w.status.AddPending(-1)
 
 	if w.IsInterrupted() {
 		return
@@ -600,32 +962,43 @@ func (w *WgetClone) MirrorWebsite(urlStr, baseURL string, visited map[string]boo
 		return
 	}
 
-	// Check if already visited with proper locking
-	w.visitedMutex.Lock()
-	if visited[urlStr] {
-		w.visitedMutex.Unlock()
-		return
-	}
-	visited[urlStr] = true
-	w.visitedMutex.Unlock()
-
 	fmt.Printf("Mirroring: %s (Depth: %d)\n", urlStr, currentDepth)
+	result := errFetchFailed // Until the page is saved or filtered out
+	savedPath := ""
+	defer func() {
+		w.stats.fileDone(result)
+		w.urlMap.record(urlStr, savedPath, result)
+		if result == nil && savedPath != "" {
+			w.sync.add(savedPath)
+		}
+	}()
 
-	req, err := http.NewRequest("GET", urlStr, nil)
+	ctx := context.WithValue(w.ctx, crawlDepthKey{}, currentDepth) // Tags the request's span
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
 		fmt.Printf("Error forming request for %s: %v\n", urlStr, err)
 		return
 	}
+	if err := w.delays.wait(ctx, req.URL); err != nil {
+		return // Interrupted while waiting out the Crawl-delay
+	}
 
-	req.Header.Set("User-Agent", "Go-Wget-Clone/1.0")
+	req.Header.Set("User-Agent", defaultUserAgent)
 
 	resp, err := w.client.Do(req)
 	if err != nil {
-		fmt.Printf("Error accessing %s: %v\n", urlStr, err)
+		if !w.IsInterrupted() {
+			fmt.Printf("Error accessing %s: %v\n", urlStr, err)
+		}
 		return
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 400 && w.contentOnError && !w.deleteAfter {
+		if err := w.saveErrorBody(resp, w.outputPathFor(urlStr, "", "", true)); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	}
 	if resp.StatusCode == 404 {
 		fmt.Printf("404 Not Found: %s\n", urlStr)
 		return
@@ -635,49 +1008,121 @@ func (w *WgetClone) MirrorWebsite(urlStr, baseURL string, visited map[string]boo
 		return
 	}
 
+	if w.ignoreLength {
+		resp.ContentLength = -1
+	}
 	contentType := resp.Header.Get("Content-Type")
 
+	// Size limits apply to resources; pages are still needed to follow their links
+	isPage := strings.Contains(contentType, "text/html")
+	if !isPage {
+		if err := w.sizes.check(resp.ContentLength); err != nil {
+			fmt.Printf("Skipping %s: %v\n", urlStr, err)
+			result = err
+			return
+		}
+		if err := w.mimes.check(contentType); err != nil {
+			fmt.Printf("Skipping %s: %v\n", urlStr, err)
+			result = err
+			return
+		}
+	}
+
+	// Links are queued once the page is saved and its memory released, as that may wait for room
+	var criticalResources, regularPages []string
+	defer func() { w.queueLinks(frontier, criticalResources, regularPages, currentDepth) }()
+	reservation := bodyReservation(resp.ContentLength, isPage)
+	frontier.reserve(reservation)
+	defer func() { frontier.release(reservation) }()
+
 	// Read content fully into memory for processing (especially for HTML rewriting)
-	contentBytes, err := io.ReadAll(resp.Body) // Read the entire body here
+	body, done := w.status.Track(urlStr, resp.ContentLength, &resumableBody{w: w, url: urlStr, resp: resp})
+	if !isPage && resp.ContentLength < 0 {
+		body = w.sizes.limit(body)
+	}
+	contentBytes, err := io.ReadAll(body) // Read the entire body here
+	done()
+	if err == nil && !isPage && resp.ContentLength < 0 {
+		err = w.sizes.check(int64(len(contentBytes)))
+	}
+	if errors.Is(err, errFiltered) {
+		fmt.Printf("Skipping %s: %v\n", urlStr, err)
+		result = err
+		return
+	}
 	if err != nil {
-		fmt.Printf("Error reading content from %s: %v\n", urlStr, err)
+		if !w.IsInterrupted() {
+			fmt.Printf("Error reading content from %s: %v\n", urlStr, err)
+		}
+		return
+	}
+	actual := bodyReservation(int64(len(contentBytes)), isPage)
+	frontier.resize(reservation, actual)
+	reservation = actual
+
+	// Robots meta tags and X-Robots-Tag can forbid following a page's links or saving it
+	var robots robotsDirectives
+	if !w.ignoreRobotsMeta {
+		robots = robotsFromHeader(resp.Header)
+		if isPage {
+			robots = robots.merge(robotsFromHTML(string(contentBytes)))
+		}
+	}
+	if robots.noIndex && !isPage {
+		fmt.Printf("Skipping %s: %v\n", urlStr, errNoIndex)
+		result = errNoIndex
 		return
 	}
 
-	// Determine output path based on mirroring logic
-	parsedURL, _ := url.Parse(urlStr)
-	relativeURLPath := strings.TrimPrefix(parsedURL.Path, "/")
-	if strings.HasSuffix(relativeURLPath, "/") || filepath.Ext(relativeURLPath) == "" {
-		relativeURLPath = filepath.Join(relativeURLPath, "index.html")
+	// A redirected URL is saved under the name it ended up at, and with --canonical a page
+	// under the URL it names as canonical; each only once, with links to the others led there
+	pageURL := resp.Request.URL
+	savedAs, isDuplicate := urlStr, false
+	if chain := redirectChain(resp); len(chain) > 0 && pageURL.Hostname() == req.URL.Hostname() {
+		isDuplicate = w.saveUnder(frontier, chain, pageURL)
+		savedAs = pageURL.String()
+	}
+	if isPage && w.canonical && !isDuplicate {
+		if canon := canonicalURL(string(contentBytes), pageURL.String()); canon != "" && canon != savedAs {
+			if canonParsed, err := url.Parse(canon); err == nil && canonParsed.Hostname() == req.URL.Hostname() {
+				isDuplicate = w.saveUnder(frontier, []*url.URL{pageURL}, canonParsed)
+				savedAs = canon
+			}
+		}
+	}
+	if isDuplicate {
+		fmt.Printf("Not saving %s: %v %s\n", urlStr, errDuplicate, savedAs)
+		result = errDuplicate // Its links are still followed
 	}
-	// Combine with the base mirroring directory
-	localFilePath := filepath.Join(w.mirrorBaseDir, relativeURLPath)
 
-	// Ensure directory exists
-	dir := filepath.Dir(localFilePath)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		fmt.Printf("Failed to create directory '%s': %v\n", dir, err)
-		return
+	// Determine output path based on mirroring logic
+	localFilePath := w.outputPathFor(savedAs, "", "", true)
+
+	// Ensure directory exists, unless --delete-after keeps nothing
+	if !w.deleteAfter {
+		dir := filepath.Dir(localFilePath)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fmt.Printf("Failed to create directory '%s': %v\n", dir, err)
+			return
+		}
 	}
 
 	// Handle HTML content
-	if strings.Contains(contentType, "text/html") {
+	if isPage {
 		contentString := string(contentBytes)
 
 		// Extract and process links (before rewriting content for saving)
-		links, err := extractLinks(contentString, baseURL)
+		links, err := extractLinks(contentString, baseURL, w.relativeOnly)
 		if err == nil {
 			baseURLParsed, _ := url.Parse(baseURL)
 
 			// Separate critical resources from regular pages
-			var criticalResources []string
-			var regularPages []string
-
 			for _, link := range links {
 				if w.IsInterrupted() {
 					return
 				}
-				if shouldReject(link, reject, exclude) {
+				link = w.rewrites.apply(link)
+				if shouldReject(link, reject, exclude) || w.excludeRE.match(link) {
 					continue
 				}
 
@@ -689,66 +1134,64 @@ func (w *WgetClone) MirrorWebsite(urlStr, baseURL string, visited map[string]boo
 
 				// Only process links within the base domain
 				if linkParsed.Hostname() == baseURLParsed.Hostname() {
-					// Check if already visited
-					w.visitedMutex.RLock()
-					alreadyVisited := visited[link]
-					w.visitedMutex.RUnlock()
-
-					if !alreadyVisited {
-						ext := strings.ToLower(filepath.Ext(linkParsed.Path))
-						// Prioritize critical resources (CSS, JS, images)
-						if ext == ".css" || ext == ".js" || ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".gif" || ext == ".svg" {
-							criticalResources = append(criticalResources, link)
-						} else {
-							regularPages = append(regularPages, link)
-						}
+					// The frontier drops links it has queued before
+					ext := strings.ToLower(filepath.Ext(linkParsed.Path))
+					// Prioritize critical resources (CSS, JS, images)
+					if ext == ".css" || ext == ".js" || ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".gif" || ext == ".svg" {
+						criticalResources = append(criticalResources, link)
+					} else if !robots.noFollow {
+						regularPages = append(regularPages, link) // nofollow keeps the page's own resources only
 					}
 				}
 			}
 
-			// Process critical resources first with guaranteed slots
-			for _, link := range criticalResources {
-				if w.IsInterrupted() {
-					return
-				}
-
-				// For critical resources, wait for semaphore instead of skipping
-				wg.Add(1)
-				sem <- struct{}{} // Block until semaphore is available
-				go w.MirrorWebsite(link, baseURL, visited, reject, exclude, maxDepth, currentDepth+1, wg, sem)
-			}
-
-			// Process regular pages with non-blocking approach
-			for _, link := range regularPages {
-				if w.IsInterrupted() {
-					return
-				}
-
-				select {
-				case sem <- struct{}{}:
-					wg.Add(1)
-					go w.MirrorWebsite(link, baseURL, visited, reject, exclude, maxDepth, currentDepth+1, wg, sem)
-				default:
-					// Only skip regular pages, not critical resources
-					if currentDepth < maxDepth {
-						fmt.Printf("Semaphore full, queueing for later: %s\n", link)
-						// Could implement a queue here for deferred processing
-					}
-				}
-			}
 		} else {
 			fmt.Printf("Error extracting links from %s: %v\n", urlStr, err)
 		}
 
-		// Rewrite HTML content after links have been processed
-		rewrittenContent, rewriteErr := rewriteHTML(contentString, urlStr, baseURL)
+		// Rewrite HTML content after links have been processed, unless left to a later convert-links pass
+		rewrittenContent, rewriteErr := contentString, error(nil)
+		if !w.noConvert && !w.deleteAfter {
+			rewrittenContent, rewriteErr = rewriteHTML(contentString, pageURL.String(), baseURL, w.layout, w.rewrites, w.aliases)
+		}
 		if rewriteErr != nil {
 			fmt.Printf("Error rewriting HTML for %s: %v\n", urlStr, rewriteErr)
 			// Continue saving original if rewrite fails
 		} else {
+			if w.backupConverted && rewrittenContent != contentString {
+				// -K keeps the server's version next to the rewritten one
+				if err := os.WriteFile(localFilePath+".orig", contentBytes, 0o644); err != nil {
+					fmt.Printf("Failed to save original of '%s': %v\n", localFilePath, err)
+				} else {
+					w.recordWritten(localFilePath + ".orig")
+				}
+			}
 			contentBytes = []byte(rewrittenContent) // Update contentBytes with rewritten content
 		}
 
+		// Optionally move inline data: URIs out into real files
+		if w.extractData && !w.deleteAfter {
+			if extracted, err := w.extractDataURIsHTML(string(contentBytes), localFilePath); err == nil {
+				contentBytes = []byte(extracted)
+			} else {
+				fmt.Printf("Error extracting data: URIs from %s: %v\n", urlStr, err)
+			}
+		}
+
+		if w.deleteAfter {
+			result = nil // Fetched and crawled; that's all --delete-after wants
+			return
+		}
+		if robots.noIndex {
+			fmt.Printf("Not saving %s: %v\n", urlStr, errNoIndex)
+			result = errNoIndex // Its links are still followed unless nofollow
+			return
+		}
+		if isDuplicate {
+			savedPath = localFilePath // Where the page was saved under its other name
+			return
+		}
+
 		// Save HTML file
 		file, err := os.Create(localFilePath)
 		if err != nil {
@@ -764,8 +1207,19 @@ func (w *WgetClone) MirrorWebsite(urlStr, baseURL string, visited map[string]boo
 
 		if err != nil {
 			fmt.Printf("Failed to write to HTML file '%s': %v\n", localFilePath, err)
+		} else {
+			w.recordWritten(localFilePath)
+			savedPath, result = localFilePath, nil
 		}
 	} else {
+		if w.deleteAfter {
+			result = nil
+			return
+		}
+		if w.extractData && strings.Contains(contentType, "text/css") {
+			contentBytes = []byte(w.extractDataURIsCSS(string(contentBytes), localFilePath))
+		}
+
 		// Save non-HTML files directly
 		file, err := os.Create(localFilePath)
 		if err != nil {
@@ -781,21 +1235,72 @@ func (w *WgetClone) MirrorWebsite(urlStr, baseURL string, visited map[string]boo
 
 		if err != nil {
 			fmt.Printf("Failed to write to file '%s': %v\n", localFilePath, err)
+		} else {
+			w.recordWritten(localFilePath)
+			savedPath, result = localFilePath, nil
 		}
 	}
 }
 
-// Mirror starts website mirroring
-func (w *WgetClone) Mirror(urlStr string, reject, exclude []string, maxDepth, maxConcurrent int) error {
-	visited := make(map[string]bool)
-	var wg sync.WaitGroup
-
-	// Increase default concurrency for better resource downloading
-	if maxConcurrent < 10 {
-		maxConcurrent = 10 // Minimum 10 for decent parallelism
+// queueLinks pushes the links found on a page at currentDepth onto frontier
+func (w *WgetClone) queueLinks(frontier *crawlFrontier, criticalResources, regularPages []string, currentDepth int) {
+	if len(criticalResources)+len(regularPages) == 0 || w.IsInterrupted() {
+		return
+	}
+	frontier.waitForRoom()
+	for _, link := range regularPages {
+		w.dns.prefetch(link) // Resolved while the queue ahead of it drains
+	}
+	// Like GNU wget, critical resources belong to their page and don't count against the depth limit
+	for _, link := range criticalResources {
+		if frontier.push(link, currentDepth) {
+			w.status.AddPending(1)
+		}
+	}
+	for _, link := range regularPages {
+		if frontier.push(link, currentDepth+1) {
+			w.status.AddPending(1)
+		}
+	}
+}
+
+// Mirror starts website mirroring into directory/<hostname>
+func (w *WgetClone) Mirror(urlStr, directory string, reject, exclude []string, maxDepth, maxConcurrent int) error {
+	var wg sync.WaitGroup
+
+	// Increase default concurrency for better resource downloading
+	if maxConcurrent < 10 {
+		maxConcurrent = 10 // Minimum 10 for decent parallelism
 	}
 
 	sem := make(chan struct{}, maxConcurrent) // Semaphore for concurrency control
+	order := w.crawlOrder
+	if order == "" {
+		order = "bfs"
+	}
+	var queued urlSet = make(exactSet)
+	if w.bloomRate > 0 {
+		capacity := w.bloomCapacity
+		if capacity <= 0 {
+			capacity = defaultBloomCapacity
+		}
+		bloom, err := newBloomSet(capacity, w.bloomRate)
+		if err != nil {
+			return fmt.Errorf("invalid --visited-bloom: %w", err)
+		}
+		fmt.Printf("Tracking visited URLs in a %s Bloom filter (%d URLs at %g false positives)\n", formatBytes(bloom.bytes()), capacity, w.bloomRate)
+		queued = bloom
+	}
+	frontier, err := newCrawlFrontier(order, queued)
+	if err != nil {
+		return err
+	}
+	frontier.limit = w.memoryLimit
+	if !w.ignoreCrawlDelay {
+		w.delays = newCrawlDelays(w.fetchRobotsTxt)
+	}
+	w.aliases = &linkAliases{}
+	frontier.maxItems, frontier.maxDepth = w.maxPages, maxDepth
 
 	// Set the base directory for mirrored files
 	parsedBaseURL, err := url.Parse(urlStr)
@@ -803,42 +1308,186 @@ func (w *WgetClone) Mirror(urlStr string, reject, exclude []string, maxDepth, ma
 		return fmt.Errorf("invalid base URL for mirroring: %w", err)
 	}
 
-	// Default mirror directory is current_dir/domain_name
-	w.mirrorBaseDir = parsedBaseURL.Hostname()
+	// Mirror directory is -P (default: current dir)/domain_name, or just -P with -nH and -nd
+	w.mirrorBaseDir = w.layout.hostDir(parsedBaseURL.Hostname())
 	if w.mirrorBaseDir == "" {
 		w.mirrorBaseDir = "mirrored_site" // Fallback if hostname is empty (e.g., file:// URLs)
 	}
+	w.mirrorBaseDir = filepath.Join(directory, w.mirrorBaseDir)
+	if !w.layout.hostDirs() {
+		w.mirrorBaseDir = filepath.Join(directory, ".")
+	}
+	if w.sync != nil {
+		w.sync.root = w.mirrorBaseDir
+	}
+	w.layout.pagePath(filePath(parsedBaseURL)) // The start page claims its name before any page it links to
 	fmt.Printf("Starting to mirror '%s' into directory '%s'\n", urlStr, w.mirrorBaseDir)
 
-	wg.Add(1)
-	sem <- struct{}{} // Acquire initial semaphore
-	go w.MirrorWebsite(urlStr, urlStr, visited, reject, exclude, maxDepth, 0, &wg, sem)
+	// Keep a second run from interleaving its writes with ours
+	unlock, err := acquireMirrorLock(w.mirrorBaseDir, urlStr, w.forceLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// WebDAV shares list their real tree, so walk it instead of scraping HTML
+	if w.isWebDAVCollection(urlStr) {
+		files, err := w.MirrorWebDAV(urlStr, reject, exclude, maxDepth, sem)
+		if err != nil {
+			return err
+		}
+		if w.IsInterrupted() {
+			fmt.Printf("\nMirroring interrupted. Downloaded %d files.\n", files)
+			return nil
+		}
+		fmt.Printf("\nMirroring completed. Downloaded %d files.\n", files)
+		return nil
+	}
 
-	wg.Wait() // Wait for all mirroring goroutines to complete
+	// A run stopped by --crawl-timeout carries on from its saved queue
+	requeued, err := resumeCrawlState(w.mirrorBaseDir, urlStr, frontier)
+	if err != nil {
+		return err
+	}
+	w.status.AddPending(int64(requeued))
+	if w.crawlTimeout > 0 {
+		timer := time.AfterFunc(w.crawlTimeout, frontier.stop)
+		defer timer.Stop()
+	}
 
-	fmt.Printf("\nMirroring completed. Visited %d URLs.\n", len(visited))
+	// Workers take URLs from the frontier in --crawl-order until it runs dry
+	if frontier.push(urlStr, 0) {
+		w.status.AddPending(1)
+	}
+	for i := 0; i < maxConcurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, ok := frontier.pop()
+				if !ok {
+					return
+				}
+				w.MirrorWebsite(item.url, urlStr, reject, exclude, maxDepth, item.depth, frontier)
+				frontier.done()
+			}
+		}()
+	}
+
+	wg.Wait() // Wait for all workers to finish
+
+	if w.IsInterrupted() {
+		fmt.Printf("\nMirroring interrupted. Visited %d URLs.\n", frontier.visited())
+		w.hosts.Print()
+		return nil
+	}
+	if left := frontier.remaining(); frontier.timedOut() && len(left) > 0 {
+		w.status.AddPending(-int64(len(left)))
+		statePath, err := saveCrawlState(w.mirrorBaseDir, urlStr, frontier)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nCrawl timeout of %s reached. Visited %d URLs; %d still queued.\n", w.crawlTimeout, frontier.visited(), len(left))
+		w.hosts.Print()
+		fmt.Printf("Saved the crawl to '%s': run the same command again to resume.\n", statePath)
+		return nil
+	}
+	if requeued > 0 {
+		os.Remove(filepath.Join(w.mirrorBaseDir, crawlStateName)) // Finished: nothing left to resume
+	}
+	fmt.Printf("\nMirroring completed. Visited %d URLs.\n", frontier.visited())
+	w.hosts.Print()
+	if refused := frontier.refused(); refused > 0 {
+		fmt.Printf("Stopped at --max-pages %d: %d more URLs found were not fetched.\n", w.maxPages, refused)
+	}
+	if !w.noConvert && !w.deleteAfter {
+		// Pages saved before a redirect or duplicate turned up still link to the name it was found under
+		relinked, err := w.aliases.relink(w.mirrorBaseDir, w.layout.prefix())
+		if err != nil {
+			return err
+		}
+		if err := saveLinkPrefix(w.mirrorBaseDir, w.layout.prefix()); err != nil {
+			return err
+		}
+		if relinked > 0 {
+			fmt.Printf("Pointed links in %d pages at redirected or canonical pages.\n", relinked)
+		}
+	}
+	if w.urlMap != nil && !w.deleteAfter && requeued == 0 {
+		if err := w.reportChanges(); err != nil {
+			return err
+		}
+	}
+	if w.sitemap && !w.deleteAfter {
+		siteURL := w.sitemapURL
+		if siteURL == "" {
+			siteURL = (&url.URL{Scheme: parsedBaseURL.Scheme, Host: parsedBaseURL.Host, Path: "/"}).String()
+		}
+		listed, err := writeSitemap(w.mirrorBaseDir, siteURL)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s and %s listing %d pages.\n", sitemapName, sitemapHTMLName, listed)
+	}
+
+	if w.verifyLinks {
+		return w.verifyMirrorLinks()
+	}
 	return nil
 }
 
 func main() {
-	var (
-		output        = flag.String("O", "", "Output filename")
-		directory     = flag.String("P", "", "Directory to save files")
-		rateLimit     = flag.String("rate-limit", "", "Rate limit (e.g., 200k, 2M)")
-		background    = flag.Bool("B", false, "Download in background")
-		inputFile     = flag.String("i", "", "File containing URLs to download")
-		mirror        = flag.Bool("mirror", false, "Mirror website")
-		reject        = flag.String("R", "", "Comma-separated file extensions to reject") // mirror option
-		exclude       = flag.String("X", "", "Comma-separated paths to exclude")          // mirror option
-		maxDepth      = flag.Int("l", 3, "Max recursion depth for mirroring")             // mirror option
-		maxConcurrent = flag.Int("max-concurrent", 5, "Maximum concurrent downloads for -i and --mirror")
-		// Possible combinations: (`-i` with `-P`, and `--rate-limit` with `-O`)
-	)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "jobs":
+			os.Exit(RunJobsCommand(os.Args[2:]))
+		case "serve":
+			os.Exit(RunServeCommand(os.Args[2:]))
+		case "verify":
+			os.Exit(RunVerifyCommand(os.Args[2:]))
+		case "convert-links":
+			os.Exit(RunConvertLinksCommand(os.Args[2:]))
+		case "completion":
+			os.Exit(RunCompletionCommand(os.Args[2:]))
+		}
 
+		// get, batch and mirror accept only the flags that apply to them
+		opts := &cliOptions{}
+		if fs, ok := newCommandFlagSet(os.Args[1], opts); ok {
+			fs.Parse(os.Args[2:])
+			if err := applyConfig(fs, opts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			args := fs.Args()
+			switch os.Args[1] {
+			case "mirror":
+				opts.mirror = true
+			case "batch":
+				if opts.inputFile == "" && len(args) > 0 {
+					opts.inputFile, args = args[0], args[1:]
+				}
+			}
+			if len(args) == 0 && opts.inputFile == "" && opts.metalink == "" {
+				fs.Usage()
+				os.Exit(1)
+			}
+			runDownload(opts, args)
+			return
+		}
+	}
+
+	// Legacy flat interface: every flag in one namespace
+	opts := &cliOptions{}
+	opts.register(flag.CommandLine, allFlags)
 	flag.Parse()
+	if err := applyConfig(flag.CommandLine, opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	args := flag.Args()
-	if len(args) == 0 && *inputFile == "" && !*mirror {
+	if len(args) == 0 && opts.inputFile == "" && !opts.mirror && opts.metalink == "" {
 
 		fmt.Println(`
 go-wget - A simple wget clone in Go for downloading files and mirroring websites.
@@ -848,6 +1497,18 @@ Usage:
   ./wget -i input-file [options]      Download multiple URLs listed in a file.
   ./wget --mirror URL [options]       Mirror an entire website recursively.
 
+Commands:
+  ./wget get [options] URL            Download a single URL.
+  ./wget batch [options] FILE         Download the URLs listed in a file.
+  ./wget mirror [options] URL         Mirror an entire website recursively.
+  ./wget jobs list|status|stop|log    Manage downloads started with -B.
+  ./wget serve [--addr ADDR] DIR      Browse a mirrored site over local HTTP.
+  ./wget verify DIR                   Check a mirror against its manifest and for dangling local links.
+  ./wget convert-links DIR [URL]      Rewrite the links of a mirror saved with --no-convert-links.
+  ./wget completion bash|zsh|fish     Print a shell completion script.
+
+Run './wget <command> -h' for the options of a command.
+
 Options:`)
 		flag.PrintDefaults()
 
@@ -861,50 +1522,457 @@ Examples:
 		os.Exit(1)
 	}
 
+	runDownload(opts, args)
+}
+
+// runDownload executes a parsed download, batch or mirror invocation
+func runDownload(opts *cliOptions, args []string) {
 	wget := NewWgetClone()
 	wget.SetupSignalHandling()
+	wget.SetupStatusReporting()
+	wget.SetupPauseHandling()
+	wget.verifyLinks = opts.verifyLinks
+	wget.extractData = opts.extractData
+	wget.forceLock = opts.forceLock
+	wget.backupConverted = opts.backupOrig
+	wget.noConvert = opts.noConvert
+	wget.deleteAfter = opts.deleteAfter
+	wget.relativeOnly = opts.relativeOnly
+	wget.ignoreRobotsMeta = opts.noRobotsMeta
+	wget.ignoreCrawlDelay = opts.noCrawlDelay
+	wget.canonical = opts.canonical
+	wget.sitemap = opts.sitemap || opts.sitemapURL != ""
+	wget.sitemapURL = opts.sitemapURL
+	wget.changeReport = opts.changeReport
+	wget.crawlOrder = opts.crawlOrder
+	wget.bloomRate = opts.bloomRate
+	wget.bloomCapacity = opts.bloomCapacity
+	wget.maxPages = opts.maxPages
+	rewrites, rewriteErr := parseRewriteRules(opts.rewriteRules)
+	if rewriteErr != nil {
+		fmt.Printf("Error: %v\n", rewriteErr)
+		os.Exit(1)
+	}
+	wget.rewrites = rewrites
+	excludeRE, excludeErr := parseURLPatterns(opts.excludeRegex)
+	if excludeErr != nil {
+		fmt.Printf("Error: %v\n", excludeErr)
+		os.Exit(1)
+	}
+	wget.excludeRE = excludeRE
+	if opts.crawlTimeout != "" {
+		timeout, parseErr := time.ParseDuration(opts.crawlTimeout)
+		if parseErr != nil || timeout <= 0 {
+			fmt.Printf("Error: invalid --crawl-timeout '%s' (want a duration like 2h or 90m)\n", opts.crawlTimeout)
+			os.Exit(1)
+		}
+		wget.crawlTimeout = timeout
+	}
+	wget.contentOnError = opts.errorContent
+	if opts.deleteAfter && opts.manifest {
+		fmt.Println("Error: --delete-after leaves no files for --checksum-manifest")
+		os.Exit(1)
+	}
+	var nameRules *fileNameRules
+	if opts.restrictNames != "" {
+		rules, err := parseFileNameRules(opts.restrictNames)
+		if err != nil {
+			fmt.Printf("Error parsing --restrict-file-names: %v\n", err)
+			os.Exit(1)
+		}
+		nameRules = rules
+	}
+	// On Windows names are always sanitized, so track them to keep clashes apart
+	linkPrefix, styleErr := parseLinkStyle(opts.convertLinks)
+	if styleErr != nil {
+		fmt.Printf("Error parsing --convert-links: %v\n", styleErr)
+		os.Exit(1)
+	}
+	if opts.noHostDirs || opts.cutDirs > 0 || opts.noDirs || opts.flatten || nameRules != nil || linkPrefix != "" || runtime.GOOS == "windows" {
+		wget.layout = &mirrorLayout{noHostDirs: opts.noHostDirs, cutDirs: opts.cutDirs, noDirs: opts.noDirs, flatten: opts.flatten, rules: nameRules, linkPrefix: linkPrefix}
+	}
+	// Unicode hosts and paths are accepted and sent as punycode and percent-encoded UTF-8
+	for i, arg := range args {
+		uri, err := toURI(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		args[i] = uri
+	}
+	wget.mimes = mimeFilter{accept: parseMimeList(opts.acceptMime), reject: parseMimeList(opts.rejectMime)}
+	var sizeErr error
+	if wget.sizes.min, sizeErr = parseByteSize(opts.minFileSize); sizeErr != nil {
+		fmt.Printf("Error parsing --min-filesize: %v\n", sizeErr)
+		os.Exit(1)
+	}
+	if wget.sizes.max, sizeErr = parseByteSize(opts.maxFileSize); sizeErr != nil {
+		fmt.Printf("Error parsing --max-filesize: %v\n", sizeErr)
+		os.Exit(1)
+	}
+	if wget.memoryLimit, sizeErr = parseByteSize(opts.memoryLimit); sizeErr != nil {
+		fmt.Printf("Error parsing --memory-limit: %v\n", sizeErr)
+		os.Exit(1)
+	}
+	// The limit can change while running: SIGUSR2 steps through --rate-presets
+	liveRate.configured, _ = parseRateLimit(opts.rateLimit)
+	liveRate.current = liveRate.configured
+	presets, presetErr := parseRatePresets(opts.ratePresets)
+	if presetErr != nil {
+		fmt.Printf("Error parsing --rate-presets: %v\n", presetErr)
+		os.Exit(1)
+	}
+	wget.SetupRateControl(presets)
+	if opts.rateBurst != "" {
+		burst, err := parseRateLimit(opts.rateBurst)
+		if err != nil || burst <= 0 {
+			fmt.Printf("Error parsing --rate-burst: %s\n", opts.rateBurst)
+			os.Exit(1)
+		}
+		rateBurst = burst
+	}
+	if opts.bufferSize != "" {
+		size, err := parseByteSize(opts.bufferSize)
+		if err != nil || size < minBufferSize || size > maxBufferSize {
+			fmt.Printf("Error: --buffer-size must be between %s and %s\n", formatBytes(minBufferSize), formatBytes(maxBufferSize))
+			os.Exit(1)
+		}
+		wget.bufferSize = int(size)
+	}
+	slice, rangeErr := parseByteRange(opts.byteRange, opts.startPos, opts.endPos)
+	if rangeErr != nil {
+		fmt.Printf("Error: %v\n", rangeErr)
+		os.Exit(1)
+	}
+	if slice != nil && (opts.mirror || opts.inputFile != "" || opts.metalink != "" || opts.continueDl) {
+		fmt.Println("Error: --start-pos, --end-pos and --range apply to a single URL and can't be combined with -c")
+		os.Exit(1)
+	}
+	splitSize, splitErr := parseByteSize(opts.splitSize)
+	if splitErr != nil || splitSize < 0 || (opts.splitSize != "" && splitSize == 0) {
+		fmt.Printf("Error parsing --split-size: %s\n", opts.splitSize)
+		os.Exit(1)
+	}
+	if opts.pipeTo != "" && (opts.mirror || opts.inputFile != "" || opts.metalink != "" || opts.continueDl || slice != nil || splitSize > 0) {
+		fmt.Println("Error: --pipe-to applies to a single URL and can't be combined with -c, a byte range or --split-size")
+		os.Exit(1)
+	}
+	if splitSize > 0 && (opts.mirror || opts.inputFile != "" || opts.metalink != "" || opts.continueDl || slice != nil) {
+		fmt.Println("Error: --split-size applies to a single URL and can't be combined with -c or a byte range")
+		os.Exit(1)
+	}
+	if err := setupProgress(opts.progress); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := setupColor(opts.color); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	wget.continueDownloads = opts.continueDl
+	wget.followLog = opts.followLog
+	wget.queueFile = opts.queueFile
+	wget.ignoreLength = opts.ignoreLength
+	wget.autoDecompress = opts.decompress
+	wget.extract, wget.extractDir = opts.extract.enabled, opts.extract.dir
+	wget.deleteArchive = opts.deleteArchive
+	if opts.manifest && !opts.mirror {
+		wget.writtenFiles = make(map[string]bool)
+	}
+	if opts.syncTo != "" {
+		if !opts.mirror || opts.deleteAfter {
+			fmt.Println("Error: --sync-to uploads the files of a mirror; it needs --mirror and files to keep")
+			os.Exit(1)
+		}
+		target, err := wget.parseSyncTarget(opts.syncTo)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !opts.background && opts.schedule == "" {
+			wget.sync = newMirrorSync(wget.ctx, target, opts.syncTo, opts.syncDelete)
+		}
+	} else if opts.syncDelete {
+		fmt.Println("Error: --sync-delete needs --sync-to")
+		os.Exit(1)
+	}
+	if opts.keyring != "" {
+		if opts.signatureURL != "" && (opts.mirror || opts.inputFile != "") {
+			fmt.Println("Error: --signature-url only applies to single-file downloads")
+			os.Exit(1)
+		}
+		keyring, err := loadKeyring(opts.keyring)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		wget.signature = &signatureCheck{keyring: keyring, url: opts.signatureURL, deleteBad: opts.deleteBadSig}
+	} else if opts.signatureURL != "" {
+		fmt.Println("Error: --signature-url requires --keyring")
+		os.Exit(1)
+	}
+	if opts.proxy != "" {
+		proxyURL, err := url.Parse(opts.proxy)
+		if err != nil || proxyURL.Host == "" {
+			fmt.Printf("Error: invalid proxy URL: %s\n", opts.proxy)
+			os.Exit(1)
+		}
+		wget.transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	eyeballs, tuneErr := tuneTransport(wget.transport, opts)
+	if tuneErr != nil {
+		fmt.Printf("Error: %v\n", tuneErr)
+		os.Exit(1)
+	}
+	if err := routeOnion(wget.transport, opts.torProxy); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(opts.connectTo) > 0 {
+		targets, err := parseConnectTo(opts.connectTo)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		connectTo(wget.transport, targets)
+	}
+	if opts.hostName != "" {
+		presentHost(wget.transport, opts.hostName)
+	}
+	if opts.pinnedKey != "" {
+		pins, err := parsePins(opts.pinnedKey)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		pinPublicKey(wget.transport, pins)
+	}
+	if opts.certMinDays < 0 {
+		fmt.Println("Error: --cert-min-days must not be negative")
+		os.Exit(1)
+	}
+	if opts.showCert || opts.certMinDays > 0 {
+		certs := &certInspector{show: opts.showCert, minDays: opts.certMinDays, shown: make(map[string]bool)}
+		addConnectionCheck(wget.transport, certs.check)
+	}
+	if opts.unixSocket != "" {
+		dialUnixSocket(wget.transport, opts.unixSocket)
+	} else if !opts.noDNSPrefetch {
+		wget.dns = newDNSPrefetcher(wget.ctx, wget.transport, eyeballs)
+		eyeballs.prefetched = wget.dns
+	}
+	if err := resolvePasswords(opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// The read timeout and download deadline sit below retries, so every attempt of a retried
+	// request gets the whole budget
+	if opts.readTimeout != "" {
+		timeout, parseErr := time.ParseDuration(opts.readTimeout)
+		if parseErr != nil || timeout <= 0 {
+			fmt.Printf("Error: invalid --read-timeout '%s' (want a duration like 30s)\n", opts.readTimeout)
+			os.Exit(1)
+		}
+		wget.client.Transport = &stallTransport{base: wget.client.Transport, timeout: timeout}
+	}
+	if opts.downloadTime != "" {
+		budget, parseErr := time.ParseDuration(opts.downloadTime)
+		if parseErr != nil || budget <= 0 {
+			fmt.Printf("Error: invalid --max-download-time '%s' (want a duration like 10m)\n", opts.downloadTime)
+			os.Exit(1)
+		}
+		wget.client.Transport = &deadlineTransport{base: wget.client.Transport, budget: budget}
+	}
+	if opts.hostName != "" {
+		wget.client.Transport = &hostTransport{base: wget.client.Transport, host: opts.hostName}
+	}
+	if opts.debug {
+		wget.client.Transport = &debugTransport{base: wget.client.Transport}
+	}
+	// What wget sends on its own behalf leaves here, before the layers meant for the sites downloaded
+	wget.serviceClient.Transport = wget.client.Transport
+	// -i lines can carry their own headers, which the same transport adds
+	if len(opts.headers) > 0 || opts.language != "" || opts.dnt || opts.inputFile != "" {
+		headers, err := parseHeaders(opts.headers, opts.language, opts.dnt)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		wget.client.Transport = &headerTransport{base: wget.client.Transport, headers: headers}
+	}
+	// Runs before the header transport, so a User-Agent given with --header still wins
+	agents, agentErr := newUserAgents(opts.userAgent, opts.userAgentFile, opts.rotateAgent)
+	if agentErr != nil {
+		fmt.Printf("Error: %v\n", agentErr)
+		os.Exit(1)
+	}
+	if agents != nil {
+		wget.client.Transport = &userAgentTransport{base: wget.client.Transport, agents: agents}
+	}
+	wget.timing = opts.timing
+	if opts.stats || opts.statsJSON != "" {
+		wget.stats = newRunStats(wget.status)
+		wget.client.Transport = &statsTransport{base: wget.client.Transport, stats: wget.stats}
+	}
+	if opts.mirror {
+		wget.hosts = newHostStats()
+		wget.client.Transport = &hostStatsTransport{base: wget.client.Transport, stats: wget.hosts}
+	}
+	// Mirrors with either also keep a snapshot of the URLs they saved, to report changes on the next run
+	if opts.urlMap != "" || opts.changeReport != "" {
+		wget.urlMap = newURLMap()
+		wget.client.Transport = &urlMapTransport{base: wget.client.Transport, urls: wget.urlMap}
+	}
+	// Like notifications, spans come from the child runs of -B and --schedule
+	if opts.otlpEndpoint != "" && !opts.background && opts.schedule == "" {
+		wget.telemetry = newTelemetry(opts.otlpEndpoint, opts.otlpService, wget.status)
+		wget.client.Transport = &telemetryTransport{base: wget.client.Transport, telemetry: wget.telemetry}
+	}
+	if opts.proxyUser != "" {
+		wget.client.Transport = newProxyAuthTransport(wget.client.Transport, wget.transport, opts.proxyUser, opts.proxyPassword)
+		wget.serviceClient.Transport = newProxyAuthTransport(wget.serviceClient.Transport, wget.transport, opts.proxyUser, opts.proxyPassword)
+	}
+	switch opts.auth {
+	case "", "basic":
+		if opts.user != "" {
+			wget.client.Transport = &basicAuthTransport{base: wget.client.Transport, user: opts.user, password: opts.password}
+		}
+	case "negotiate":
+		wget.client.Transport = &negotiateAuthTransport{base: wget.client.Transport}
+	case "ntlm":
+		if opts.user == "" {
+			fmt.Println("Error: --auth ntlm needs --user (as DOMAIN\\user or user@domain)")
+			os.Exit(1)
+		}
+		wget.client.Transport = &ntlmAuthTransport{base: wget.client.Transport, user: opts.user, password: opts.password}
+	default:
+		fmt.Printf("Error: unknown --auth '%s' (want basic, negotiate or ntlm)\n", opts.auth)
+		os.Exit(1)
+	}
+	if opts.oauthTokenURL != "" {
+		token, oauthErr := newOAuthToken(wget.client.Transport, opts.oauthTokenURL, opts.oauthClientID, opts.oauthSecret, opts.oauthScope)
+		if oauthErr == nil {
+			_, oauthErr = token.get(wget.ctx, "") // Bad credentials fail the run before anything starts
+		}
+		if oauthErr != nil {
+			fmt.Printf("Error: %v\n", oauthErr)
+			os.Exit(1)
+		}
+		wget.oauth = &oauthTransport{base: wget.client.Transport, token: token, hosts: make(map[string]bool)}
+		wget.oauth.allow(args...)
+		wget.oauth.allow(opts.sources...)
+		wget.client.Transport = wget.oauth
+	}
+	// Retries sit above authentication so every attempt is answered, and below the
+	// adaptive limiter so a backing-off request doesn't hold a slot
+	if opts.tries > 1 || opts.retryCodes != "" {
+		retry := &retryTransport{base: wget.client.Transport, tries: opts.tries}
+		if opts.retryCodes != "" {
+			codes, err := parseStatusCodes(opts.retryCodes)
+			if err != nil {
+				fmt.Printf("Error parsing --retry-on-http-error: %v\n", err)
+				os.Exit(1)
+			}
+			retry.codes = codes
+			if retry.tries == 0 {
+				retry.tries = defaultHTTPRetries
+			}
+		}
+		wget.client.Transport = retry
+		wget.tries = retry.tries
+	}
+	if opts.adaptive {
+		hostLimit := opts.maxConcurrent
+		if opts.connsPerHost > 0 {
+			hostLimit = min(hostLimit, opts.connsPerHost)
+		}
+		wget.client.Transport = NewAdaptiveTransport(wget.client.Transport, hostLimit)
+	}
+	if opts.cacheDir != "" {
+		cache, err := newCacheTransport(wget.client.Transport, opts.cacheDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		wget.client.Transport = cache
+	}
+	if opts.loadCookies != "" {
+		if err := wget.cookies.Load(opts.loadCookies); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	var err error
 
-	if *mirror {
+	if opts.startAt != "" && !opts.background {
+		if err := wget.WaitForStart(opts.startAt); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	removeStatusFifo := func() {}
+	if opts.statusFifo != "" && !opts.background && opts.schedule == "" {
+		if removeStatusFifo, err = wget.ServeStatusFifo(opts.statusFifo); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Background and scheduled runs log in from the child process that does the work
+	if opts.loginURL != "" && !opts.background && opts.schedule == "" {
+		if err := wget.Login(opts.loginURL, opts.loginData); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	started := time.Now()
+	if opts.schedule != "" && !opts.background {
+		err = wget.RunScheduled(opts.schedule, stripFlags(os.Args[1:], "schedule", "start-at"))
+	} else if opts.mirror {
 		if len(args) == 0 {
 			fmt.Println("URL required for mirroring")
 			os.Exit(1)
 		}
 
 		var rejectList, excludeList []string
-		if *reject != "" {
+		if opts.reject != "" {
 			// Split by comma and trim spaces for extensions
-			rejectList = strings.Split(*reject, ",")
+			rejectList = strings.Split(opts.reject, ",")
 			for i := range rejectList {
 				rejectList[i] = strings.TrimSpace(rejectList[i])
 			}
 		}
-		if *exclude != "" {
+		if opts.exclude != "" {
 			// Split by comma and trim spaces for paths
-			excludeList = strings.Split(*exclude, ",")
+			excludeList = strings.Split(opts.exclude, ",")
 			for i := range excludeList {
 				excludeList[i] = strings.TrimSpace(excludeList[i])
 			}
 		}
 
-		err = wget.Mirror(args[0], rejectList, excludeList, *maxDepth, *maxConcurrent)
+		err = wget.Mirror(args[0], opts.directory, rejectList, excludeList, opts.maxDepth, opts.maxConcurrent)
 
-	} else if *inputFile != "" {
-		file, err := os.Open(*inputFile)
+	} else if opts.inputFile != "" {
+		var urls []string
+		var entries map[string]*entryHeaders
+		urls, entries, err = readInputURLs(opts.inputFile, opts.forceHTML, opts.baseURL)
 		if err != nil {
-			fmt.Printf("Error opening input file: %v\n", err)
+			fmt.Printf("Error reading input file: %v\n", err)
 			os.Exit(1)
 		}
-		defer file.Close()
-
-		var urls []string
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line != "" {
-				urls = append(urls, line)
-			}
+		if urls, err = expandURLPatterns(urls); err == nil {
+			err = expandEntryHeaders(entries)
+		}
+		if err != nil {
+			fmt.Printf("Error expanding URL pattern: %v\n", err)
+			os.Exit(1)
+		}
+		wget.entryHeaders = entries
+		if len(args) == 0 {
+			wget.oauth.allow(urls...) // The batch's URLs are where it starts
 		}
 
 		if len(urls) == 0 {
@@ -913,36 +1981,166 @@ Examples:
 		}
 
 		// Parse rate limit here
-		rateLimitBytes, parseErr := parseRateLimit(*rateLimit)
+		rateLimitBytes, parseErr := parseRateLimit(opts.rateLimit)
 		if parseErr != nil {
 			fmt.Printf("Error parsing rate limit: %v\n", parseErr)
 			os.Exit(1)
 		}
 
-		err = wget.DownloadMultipleFiles(urls, *maxConcurrent, *directory, rateLimitBytes)
-		if err != nil {
-			fmt.Printf("Error downloading files: %v\n", err)
+		err = wget.DownloadMultipleFiles(urls, opts.maxConcurrent, opts.directory, rateLimitBytes)
+
+	} else if opts.metalink != "" {
+		name, urls, expected, loadErr := LoadMetalink(opts.metalink)
+		if loadErr != nil {
+			fmt.Printf("Error: %v\n", loadErr)
+			os.Exit(1)
+		}
+		wget.oauth.allow(urls...)
+		outputName := opts.output
+		if outputName == "" {
+			outputName = name
+		}
+
+		rateLimitBytes, parseErr := parseRateLimit(opts.rateLimit)
+		if parseErr != nil {
+			fmt.Printf("Error parsing rate limit: %v\n", parseErr)
 			os.Exit(1)
 		}
 
+		err = wget.DownloadMultiSource(urls, outputName, opts.directory, rateLimitBytes, expected)
+
 	} else {
 		urlStr := args[0]
 
-		if *background {
-			err = wget.BackgroundDownload(urlStr, *output, *directory, *rateLimit)
+		if opts.background {
+			err = wget.BackgroundDownload(urlStr, stripBoolFlags(os.Args[1:], "B", "follow-log"))
 		} else {
-			rateLimitBytes, parseErr := parseRateLimit(*rateLimit)
+			rateLimitBytes, parseErr := parseRateLimit(opts.rateLimit)
 			if parseErr != nil {
 				fmt.Printf("Error parsing rate limit: %v\n", parseErr)
 				os.Exit(1)
 			}
 
-			err = wget.DownloadFile(urlStr, *output, *directory, rateLimitBytes, false)
+			if opts.uploadFile != "" {
+				err = wget.Upload(opts.uploadFile, urlStr, opts.uploadMethod, rateLimitBytes)
+			} else if opts.output != "" && len(args) > 1 {
+				err = wget.DownloadConcatenated(args, opts.output, opts.directory, rateLimitBytes)
+			} else if hasURLPattern(urlStr) {
+				// A [001-100] or {a,b} pattern becomes a batch download
+				urls, expandErr := ExpandURLPattern(urlStr)
+				if expandErr != nil {
+					fmt.Printf("Error expanding URL pattern: %v\n", expandErr)
+					os.Exit(1)
+				}
+				wget.oauth.allow(urls...)
+				err = wget.DownloadMultipleFiles(urls, opts.maxConcurrent, opts.directory, rateLimitBytes)
+			} else if opts.head {
+				err = wget.PrintHead(urlStr)
+			} else if slice != nil {
+				err = wget.DownloadRange(urlStr, opts.output, opts.directory, slice, rateLimitBytes)
+			} else if len(opts.sources) > 0 {
+				err = wget.DownloadMultiSource(append([]string{urlStr}, opts.sources...), opts.output, opts.directory, rateLimitBytes, nil)
+			} else if opts.zsync && fileExists(wget.outputPathFor(urlStr, opts.output, opts.directory, false)) {
+				localPath := wget.outputPathFor(urlStr, opts.output, opts.directory, false)
+				handled, zsyncErr := wget.ZsyncUpdate(urlStr, localPath, rateLimitBytes)
+				err = zsyncErr
+				if !handled {
+					err = wget.DownloadFile(urlStr, opts.output, opts.directory, rateLimitBytes, false)
+				}
+			} else if opts.pipeTo != "" {
+				err = wget.DownloadPiped(urlStr, opts.pipeTo, rateLimitBytes)
+			} else if splitSize > 0 {
+				err = wget.DownloadSplit(urlStr, opts.output, opts.directory, rateLimitBytes, splitSize)
+			} else if opts.watch != "" {
+				interval, parseErr := time.ParseDuration(opts.watch)
+				if parseErr != nil || interval <= 0 {
+					fmt.Printf("Error parsing watch interval: %s\n", opts.watch)
+					os.Exit(1)
+				}
+				err = wget.WatchURL(urlStr, opts.output, opts.directory, rateLimitBytes, interval, opts.watchStamped)
+			} else {
+				err = wget.DownloadFile(urlStr, opts.output, opts.directory, rateLimitBytes, false)
+			}
+		}
+	}
+
+	// Mirrors always keep a manifest of their tree, for `wget verify`
+	if err == nil && !opts.background && opts.schedule == "" && !wget.IsInterrupted() {
+		if opts.mirror && !opts.deleteAfter {
+			err = wget.WriteMirrorManifest(wget.mirrorBaseDir)
+		} else if wget.writtenFiles != nil {
+			manifestDir := opts.directory
+			if manifestDir == "" {
+				manifestDir = "."
+			}
+			err = wget.WriteChecksumManifest(manifestDir)
+		}
+	}
+	if syncErr := wget.sync.finish(); syncErr != nil && err == nil {
+		err = syncErr
+	}
+	if opts.urlMap != "" && !opts.background && opts.schedule == "" {
+		if mapErr := wget.urlMap.write(opts.urlMap); mapErr != nil && err == nil {
+			err = mapErr
+		}
+	}
+
+	if opts.saveCookies != "" && !opts.background && opts.schedule == "" {
+		if saveErr := wget.cookies.Save(opts.saveCookies); saveErr != nil {
+			fmt.Printf("Warning: %v\n", saveErr)
 		}
 	}
 
+	if wget.stats != nil && !opts.background && opts.schedule == "" {
+		report := wget.stats.Report()
+		if opts.stats {
+			report.Print()
+		}
+		if opts.statsJSON != "" {
+			if statsErr := report.WriteJSON(opts.statsJSON); statsErr != nil {
+				fmt.Printf("Warning: %v\n", statsErr)
+			}
+		}
+	}
+
+	if wget.telemetry != nil {
+		if otlpErr := wget.telemetry.Shutdown(wget.newRunSummary(opts, args, started, err).Command, err); otlpErr != nil {
+			fmt.Printf("Warning: %v\n", otlpErr)
+		}
+	}
+
+	// -B parents and --schedule loops leave notifying to the child runs doing the work
+	if (opts.notifyURL != "" || opts.notifyDesktop) && !opts.background && opts.schedule == "" {
+		summary := wget.newRunSummary(opts, args, started, err)
+		if opts.notifyURL != "" {
+			if notifyErr := wget.NotifyWebhook(opts.notifyURL, summary); notifyErr != nil {
+				fmt.Printf("Warning: %v\n", notifyErr)
+			}
+		}
+		if opts.notifyDesktop {
+			if notifyErr := NotifyDesktop(summary); notifyErr != nil {
+				fmt.Printf("Warning: %v\n", notifyErr)
+			}
+		}
+	}
+
+	removeStatusFifo()
+
+	if errors.Is(err, errFiltered) {
+		fmt.Printf("%s %v\n", colorize(colorYellow, "Skipped:"), err)
+		return
+	}
+	var pipeExit *pipeExitError
+	if errors.As(err, &pipeExit) {
+		fmt.Printf("%s %v\n", colorize(colorRed, "Error:"), err)
+		os.Exit(pipeExit.code)
+	}
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Printf("%s %v\n", colorize(colorRed, "Error:"), err)
+		os.Exit(1)
+	}
+	if wget.IsInterrupted() && !opts.background {
+		fmt.Println("Download interrupted by user")
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestName is the file written by --checksum-manifest, in the format of sha256sum
+const manifestName = "SHA256SUMS"
+
+// recordWritten notes a file saved by this run so it is listed in the checksum manifest
+func (w *WgetClone) recordWritten(path string) {
+	w.writtenMutex.Lock()
+	defer w.writtenMutex.Unlock()
+	if w.writtenFiles != nil {
+		w.writtenFiles[filepath.Clean(path)] = true
+	}
+}
+
+// WriteChecksumManifest writes dir/SHA256SUMS listing every file saved by this run, with paths
+// relative to dir, so the set can be checked with `sha256sum -c SHA256SUMS` from inside dir
+func (w *WgetClone) WriteChecksumManifest(dir string) error {
+	w.writtenMutex.Lock()
+	var paths []string
+	for path := range w.writtenFiles {
+		paths = append(paths, path)
+	}
+	w.writtenMutex.Unlock()
+	sort.Strings(paths)
+
+	return writeManifest(dir, func(out io.Writer) (int, error) {
+		count := 0
+		for _, path := range paths {
+			sum, err := fileSHA256(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue // Replaced or removed since it was written
+				}
+				return count, err
+			}
+			name := path
+			if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+				name = rel
+			}
+			fmt.Fprintf(out, "%s  %s\n", sum, filepath.ToSlash(name))
+			count++
+		}
+		return count, nil
+	})
+}
+
+// WriteMirrorManifest writes dir/SHA256SUMS listing every file of the mirror tree at dir as it
+// is now, so a mirror updated over several runs has one manifest of its whole tree. The files
+// are hashed as the tree is walked, keeping memory flat however large the mirror; files that
+// --sync-delete removed are listed with the hash they were uploaded with.
+func (w *WgetClone) WriteMirrorManifest(dir string) error {
+	return writeManifest(dir, func(out io.Writer) (int, error) {
+		count := 0
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if name := d.Name(); strings.HasPrefix(name, ".wget-") || strings.HasSuffix(name, ".part") {
+				return nil // wget's bookkeeping, and files still being written
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || rel == manifestName {
+				return err
+			}
+			sum, err := fileSHA256(path)
+			if os.IsNotExist(err) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%s  %s\n", sum, filepath.ToSlash(rel))
+			count++
+			return nil
+		})
+		w.sync.eachRemoved(func(path string, synced syncedFile) {
+			if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+				fmt.Fprintf(out, "%s  %s\n", synced.sha256, filepath.ToSlash(rel))
+				count++
+			}
+		})
+		return count, err
+	})
+}
+
+// writeManifest replaces dir/SHA256SUMS with the lines list writes, returning how many it wrote
+func writeManifest(dir string, list func(out io.Writer) (int, error)) error {
+	manifestPath := filepath.Join(dir, manifestName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", dir, err)
+	}
+	tmpPath := manifestPath + ".part"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to write checksum manifest: %w", err)
+	}
+	out := bufio.NewWriter(file)
+	count, err := list(out)
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write checksum manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, manifestPath); err != nil {
+		return fmt.Errorf("failed to write checksum manifest: %w", err)
+	}
+	fmt.Printf("Wrote checksum manifest %s (%d files)\n", manifestPath, count)
+	return nil
+}
+
+// readManifest reads a SHA256SUMS file into the hash of each slash-separated path it lists
+func readManifest(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		if !ok || len(sum) != sha256.Size*2 || len(name) < 2 {
+			return nil, fmt.Errorf("malformed line in %s: %s", file, line)
+		}
+		name = strings.TrimPrefix(name[1:], "*") // "  name", or " *name" in binary mode
+		sums[name] = strings.ToLower(sum)
+	}
+	return sums, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to hash '%s': %w", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// recordQueuedDone records the files of URLs a queue file marks as finished by an earlier run,
+// so a resumed batch's manifest still covers the whole set
+func (w *WgetClone) recordQueuedDone(all, remaining []string, directory string) {
+	pending := make(map[string]bool, len(remaining))
+	for _, urlStr := range remaining {
+		pending[urlStr] = true
+	}
+	for _, urlStr := range all {
+		if path := w.outputPathFor(urlStr, "", directory, false); !pending[urlStr] && fileExists(path) {
+			w.recordWritten(path)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import "fmt"
+
+const (
+	queueEntryOverhead = 64      // Bytes a queued URL costs beyond its text: the item, slice slot and set entry
+	unknownBodySize    = 1 << 20 // Reserved for a body of unknown length until it has been read
+)
+
+// entrySize estimates the memory a queued URL takes
+func entrySize(urlStr string) int64 {
+	return int64(len(urlStr)) + queueEntryOverhead
+}
+
+// bodyReservation is what a body of length bytes is expected to take while it is processed.
+// Pages are held up to three times over: as read, as a string and rewritten.
+func bodyReservation(length int64, isPage bool) int64 {
+	if length < 0 {
+		length = unknownBodySize
+	}
+	if isPage {
+		return length * 3
+	}
+	return length
+}
+
+// used is the memory charged against --memory-limit; the caller holds f.mutex
+func (f *crawlFrontier) used() int64 {
+	return f.buffered + f.pending
+}
+
+// reserve waits until n more bytes of body fit under --memory-limit and charges them. One
+// body is always let through while no other is held, so an oversized one can't stall the crawl.
+func (f *crawlFrontier) reserve(n int64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for f.limit > 0 && f.buffered > 0 && f.used()+n > f.limit {
+		f.room.Wait()
+	}
+	f.buffered += n
+}
+
+// resize corrects a reservation once the body's real size is known, without waiting
+func (f *crawlFrontier) resize(from, to int64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.buffered += to - from
+	f.room.Broadcast()
+}
+
+// release returns a reservation
+func (f *crawlFrontier) release(n int64) {
+	f.resize(n, 0)
+}
+
+// waitForRoom holds back a fetched page's links while the queue and buffers are over
+// --memory-limit, so deep crawls stop discovering URLs faster than they fetch them. It
+// waits only while another worker is still busy, which keeps the crawl moving.
+func (f *crawlFrontier) waitForRoom() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for f.limit > 0 && f.used() > f.limit && f.active-f.held > 1 {
+		if !f.paused {
+			f.paused = true
+			fmt.Printf("Memory limit of %s reached: pausing link discovery until the queue drains\n", formatBytes(f.limit))
+		}
+		f.held++
+		f.room.Wait()
+		f.held--
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minSegmentSize    = 1 << 20          // Don't split files into pieces smaller than 1 MiB
+	segmentStall      = 15 * time.Second // A segment with no bytes for this long moves to another mirror
+	maxSourceFailures = 3                // Consecutive failures before a mirror is dropped
+)
+
+// Metalink is the subset of a Metalink v4 (RFC 5854) document used for multi-source downloads
+type Metalink struct {
+	Files []struct {
+		Name   string `xml:"name,attr"`
+		Size   int64  `xml:"size"`
+		Hashes []struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"hash"`
+		URLs []struct {
+			Priority int    `xml:"priority,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"url"`
+	} `xml:"file"`
+}
+
+// ExpectedHash is a digest a multi-source download must match
+type ExpectedHash struct {
+	Type  string // Metalink hash name, e.g. "sha-256"
+	Value string // Hex digest
+}
+
+// newHash returns a hasher for a Metalink hash type, or nil if unsupported
+func (h ExpectedHash) newHash() hash.Hash {
+	switch strings.ToLower(h.Type) {
+	case "sha-512":
+		return sha512.New()
+	case "sha-256":
+		return sha256.New()
+	case "sha-1":
+		return sha1.New()
+	case "md5":
+		return md5.New()
+	}
+	return nil
+}
+
+// LoadMetalink reads the first file entry of a metalink document: its name, mirror URLs (by priority) and strongest hash
+func LoadMetalink(filename string) (string, []string, *ExpectedHash, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to read metalink: %w", err)
+	}
+
+	var doc Metalink
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return "", nil, nil, fmt.Errorf("failed to parse metalink: %w", err)
+	}
+	if len(doc.Files) == 0 {
+		return "", nil, nil, fmt.Errorf("metalink has no file entries")
+	}
+	if len(doc.Files) > 1 {
+		fmt.Printf("Warning: metalink lists %d files, only '%s' is downloaded\n", len(doc.Files), doc.Files[0].Name)
+	}
+
+	file := doc.Files[0]
+	sort.SliceStable(file.URLs, func(i, j int) bool { return file.URLs[i].Priority < file.URLs[j].Priority })
+	var urls []string
+	for _, u := range file.URLs {
+		urls = append(urls, strings.TrimSpace(u.Value))
+	}
+	if len(urls) == 0 {
+		return "", nil, nil, fmt.Errorf("metalink entry '%s' has no URLs", file.Name)
+	}
+
+	var expected *ExpectedHash
+	for _, preferred := range []string{"sha-512", "sha-256", "sha-1", "md5"} {
+		for _, h := range file.Hashes {
+			if strings.EqualFold(h.Type, preferred) && expected == nil {
+				expected = &ExpectedHash{Type: preferred, Value: strings.ToLower(strings.TrimSpace(h.Value))}
+			}
+		}
+	}
+
+	// Only the base name is trusted; a metalink must not pick arbitrary paths
+	return path.Base(file.Name), urls, expected, nil
+}
+
+// probeRanges asks a source for the first byte to learn the total size and whether ranges work
+func (w *WgetClone) probeRanges(urlStr string) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(w.ctx, "GET", urlStr, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		size, err := strconv.ParseInt(total, 10, 64)
+		if !ok || err != nil {
+			return 0, false, nil
+		}
+		return size, true, nil
+	case http.StatusOK:
+		return resp.ContentLength, false, nil
+	default:
+		return 0, false, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+}
+
+// segment is an inclusive byte range still to be downloaded
+type segment struct {
+	start, end int64
+}
+
+// multiSourceState is the work queue shared by the per-mirror workers
+type multiSourceState struct {
+	mutex    sync.Mutex
+	pending  []segment
+	active   int
+	progress *ProgressWriter // Writes only drive the display
+}
+
+// next hands out a pending segment; ok is false once nothing is pending or in flight
+func (s *multiSourceState) next() (seg segment, ok, wait bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.pending) > 0 {
+		seg = s.pending[0]
+		s.pending = s.pending[1:]
+		s.active++
+		return seg, true, false
+	}
+	return segment{}, false, s.active > 0
+}
+
+// finish marks a segment done, re-queueing whatever part of it was not received
+func (s *multiSourceState) finish(rest *segment) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.active--
+	if rest != nil {
+		s.pending = append(s.pending, *rest)
+	}
+}
+
+func (s *multiSourceState) advance(n int) {
+	s.progress.Add(int64(n))
+}
+
+// fetchSegment downloads seg from source into file, returning how far it got
+func (w *WgetClone) fetchSegment(source string, file *os.File, seg segment, rateLimit int64, state *multiSourceState) (int64, error) {
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+	stall := time.AfterFunc(segmentStall, cancel)
+	defer stall.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", source, nil)
+	if err != nil {
+		return seg.start, err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", seg.start, seg.end))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return seg.start, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return seg.start, fmt.Errorf("HTTP %d instead of a partial response", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", seg.start)) {
+		return seg.start, fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+	}
+
+	var reader io.Reader = resp.Body
+	reader = NewRateLimitedReader(reader, rateLimit)
+
+	offset := seg.start
+	buf := make([]byte, w.copySize())
+	for offset <= seg.end {
+		w.pause.Wait(w.ctx)
+		want := min(int64(len(buf)), seg.end-offset+1)
+		n, err := reader.Read(buf[:want])
+		if n > 0 {
+			stall.Reset(segmentStall)
+			if _, werr := file.WriteAt(buf[:n], offset); werr != nil {
+				return offset, werr
+			}
+			offset += int64(n)
+			state.advance(n)
+		}
+		if err != nil {
+			if err == io.EOF && offset > seg.end {
+				break
+			}
+			if ctx.Err() != nil && w.ctx.Err() == nil {
+				return offset, fmt.Errorf("stalled for %s", segmentStall)
+			}
+			return offset, err
+		}
+	}
+	return offset, nil
+}
+
+// DownloadMultiSource fetches one file from several mirrors at once, each serving different byte ranges.
+// Segments from a failing or stalled mirror are handed to the others.
+func (w *WgetClone) DownloadMultiSource(sources []string, outputPath, directory string, rateLimit int64, expected *ExpectedHash) error {
+	err := w.downloadMultiSource(sources, outputPath, directory, rateLimit, expected)
+	w.stats.fileDone(err)
+	return err
+}
+
+func (w *WgetClone) downloadMultiSource(sources []string, outputPath, directory string, rateLimit int64, expected *ExpectedHash) error {
+	fmt.Printf("Starting download at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	// Find the size using the first source that answers
+	var size int64 = -1
+	var ranges bool
+	var live []string
+	for _, source := range sources {
+		s, r, err := w.probeRanges(source)
+		if err != nil {
+			fmt.Printf("Mirror unavailable: %s (%v)\n", source, err)
+			continue
+		}
+		if size < 0 {
+			size, ranges = s, r
+		} else if s != size && r {
+			fmt.Printf("Mirror skipped, size mismatch: %s (%d != %d)\n", source, s, size)
+			continue
+		}
+		if r {
+			live = append(live, source)
+		}
+	}
+	if size < 0 {
+		return fmt.Errorf("no mirror could be reached")
+	}
+	if !ranges || size <= 0 || len(live) == 0 {
+		fmt.Println("Mirrors don't support byte ranges, downloading from a single source")
+		for _, source := range sources {
+			if err := w.DownloadFile(source, outputPath, directory, rateLimit, false); err == nil || w.IsInterrupted() {
+				return err
+			}
+		}
+		return fmt.Errorf("all mirrors failed")
+	}
+
+	finalOutputPath := w.outputPathFor(live[0], outputPath, directory, false)
+	if dir := filepath.Dir(finalOutputPath); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create directory '%s': %w", dir, err)
+		}
+	}
+	partPath := finalOutputPath + ".part"
+	file, err := os.Create(partPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%s': %w", partPath, err)
+	}
+	defer file.Close()
+	if err := preallocate(file, 0, size); err != nil {
+		return fmt.Errorf("failed to allocate %s for '%s': %w", formatBytes(size), partPath, err)
+	}
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to allocate '%s': %w", partPath, err)
+	}
+
+	fmt.Printf("Content size: %s from %d mirrors\n", formatBytes(size), len(live))
+
+	segmentSize := max(minSegmentSize, size/int64(len(live)*4))
+	state := &multiSourceState{progress: NewProgressWriter(io.Discard, size, filepath.Base(finalOutputPath), false)}
+	for start := int64(0); start < size; start += segmentSize {
+		state.pending = append(state.pending, segment{start: start, end: min(start+segmentSize, size) - 1})
+	}
+
+	perSourceLimit := int64(0)
+	if rateLimit > 0 {
+		perSourceLimit = max(1, rateLimit/int64(len(live)))
+	}
+
+	var wg sync.WaitGroup
+	for _, source := range live {
+		wg.Add(1)
+		go func(source string) {
+			defer wg.Done()
+			failures := 0
+			for !w.IsInterrupted() {
+				seg, ok, wait := state.next()
+				if !ok {
+					if !wait {
+						return
+					}
+					time.Sleep(100 * time.Millisecond) // Another mirror may hand back a segment
+					continue
+				}
+
+				reached, err := w.fetchSegment(source, file, seg, perSourceLimit, state)
+				if err == nil {
+					failures = 0
+					state.finish(nil)
+					continue
+				}
+
+				state.finish(&segment{start: reached, end: seg.end})
+				if w.IsInterrupted() {
+					return
+				}
+				failures++
+				stdoutMutex.Lock()
+				fmt.Printf("\nMirror %s failed (%v), reassigning segment\n", source, err)
+				stdoutMutex.Unlock()
+				if failures >= maxSourceFailures {
+					stdoutMutex.Lock()
+					fmt.Printf("\nDropping mirror %s after %d failures\n", source, failures)
+					stdoutMutex.Unlock()
+					return
+				}
+			}
+		}(source)
+	}
+	wg.Wait()
+	state.progress.Finish()
+
+	if w.IsInterrupted() {
+		return fmt.Errorf("download interrupted, partial file kept at '%s'", partPath)
+	}
+	if len(state.pending) > 0 {
+		return fmt.Errorf("all mirrors failed, partial file kept at '%s'", partPath)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write file '%s': %w", partPath, err)
+	}
+
+	if expected != nil {
+		if err := verifyFileHash(partPath, *expected); err != nil {
+			return err
+		}
+		fmt.Printf("Verified %s checksum\n", expected.Type)
+	}
+
+	if err := os.Rename(partPath, finalOutputPath); err != nil {
+		return fmt.Errorf("failed to move '%s' into place: %w", partPath, err)
+	}
+	if w.signature != nil {
+		if err := w.verifySignature(finalOutputPath, sources[0]); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%s %s\n", colorize(colorGreen, "Downloaded successfully:"), finalOutputPath)
+	fmt.Printf("Finished at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Total downloaded: %s\n", formatBytes(size))
+	return nil
+}
+
+// verifyFileHash checks a file against an expected digest
+func verifyFileHash(filename string, expected ExpectedHash) error {
+	h := expected.newHash()
+	if h == nil {
+		fmt.Printf("Warning: unsupported hash type %s, skipping verification\n", expected.Type)
+		return nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("failed to hash '%s': %w", filename, err)
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); got != expected.Value {
+		return fmt.Errorf("%s mismatch for '%s': expected %s, got %s", expected.Type, filename, expected.Value, got)
+	}
+	return nil
+}
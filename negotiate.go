@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// negotiateAuthTransport answers Negotiate (SPNEGO) challenges with a Kerberos ticket from the
+// user's credential cache, as left by kinit or a domain logon, for --auth negotiate. Like
+// Basic, a ticket is only sent to servers that ask for one.
+type negotiateAuthTransport struct {
+	base http.RoundTripper
+
+	once   sync.Once
+	client *client.Client
+	err    error // Why the credential cache couldn't be used
+}
+
+func (t *negotiateAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || req.Header.Get("Authorization") != "" {
+		return resp, err
+	}
+	if !hasChallenge(resp.Header, "negotiate") {
+		return resp, nil
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil // The body was consumed and can't be replayed
+	}
+	t.once.Do(func() { t.client, t.err = loadKerberosClient() })
+	if t.err != nil {
+		resp.Body.Close()
+		return nil, t.err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	if err := spnego.SetSPNEGOHeader(t.client, retry, servicePrincipal(req.URL.Hostname())); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("negotiate authentication with %s failed: %w", req.URL.Host, err)
+	}
+	resp.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+// hasChallenge reports whether the WWW-Authenticate headers of a 401 offer scheme
+func hasChallenge(header http.Header, scheme string) bool {
+	for _, value := range header.Values("WWW-Authenticate") {
+		name, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if strings.EqualFold(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// servicePrincipal names the HTTP service of host, by its canonical name as Kerberos expects
+func servicePrincipal(host string) string {
+	if name, err := net.LookupCNAME(host); err == nil && name != "" {
+		host = strings.ToLower(strings.TrimSuffix(name, "."))
+	}
+	return "HTTP/" + host
+}
+
+// loadKerberosClient reads the credential cache ($KRB5CCNAME or /tmp/krb5cc_<uid>) and the
+// Kerberos configuration ($KRB5_CONFIG or /etc/krb5.conf)
+func loadKerberosClient() (*client.Client, error) {
+	confPath := os.Getenv("KRB5_CONFIG")
+	if confPath == "" {
+		confPath = "/etc/krb5.conf"
+	}
+	conf, err := config.Load(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Kerberos configuration %s: %w", confPath, err)
+	}
+
+	cachePath := os.Getenv("KRB5CCNAME")
+	if kind, path, ok := strings.Cut(cachePath, ":"); ok && !filepath.IsAbs(cachePath) {
+		if !strings.EqualFold(kind, "FILE") {
+			return nil, fmt.Errorf("credential cache %s is not supported (only FILE: caches are)", cachePath)
+		}
+		cachePath = path
+	}
+	if cachePath == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the Kerberos credential cache: %w", err)
+		}
+		cachePath = filepath.Join(os.TempDir(), "krb5cc_"+current.Uid)
+	}
+	cache, err := credentials.LoadCCache(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Kerberos credential cache %s (run kinit first): %w", cachePath, err)
+	}
+	cl, err := client.NewFromCCache(cache, conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use Kerberos credential cache %s: %w", cachePath, err)
+	}
+	return cl, nil
+}
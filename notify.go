@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// notifyTimeout bounds how long a finished run waits on its webhook
+const notifyTimeout = 10 * time.Second
+
+// runSummary is the JSON body POSTed to --notify-url when a run ends
+type runSummary struct {
+	Status     string    `json:"status"`  // success, failed, skipped or interrupted
+	Command    string    `json:"command"` // get, batch or mirror
+	URLs       []string  `json:"urls,omitempty"`
+	InputFile  string    `json:"input_file,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Files      int       `json:"files"`
+	Bytes      int64     `json:"bytes"`
+	Host       string    `json:"host"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Seconds    float64   `json:"duration_seconds"`
+}
+
+// newRunSummary describes the outcome of a download run that started at started and ended with err
+func (w *WgetClone) newRunSummary(opts *cliOptions, args []string, started time.Time, err error) runSummary {
+	s := runSummary{Status: "success", Command: "get", URLs: args, InputFile: opts.inputFile, Output: opts.output}
+	switch {
+	case opts.mirror:
+		s.Command, s.Output = "mirror", w.mirrorBaseDir
+	case opts.inputFile != "" || (len(args) > 0 && hasURLPattern(args[0])):
+		s.Command = "batch"
+	}
+	if s.Output == "" {
+		s.Output = opts.directory
+	}
+
+	switch {
+	case errors.Is(err, errFiltered):
+		s.Status, s.Error = "skipped", err.Error()
+	case err != nil:
+		s.Status, s.Error = "failed", err.Error()
+	case w.IsInterrupted():
+		s.Status = "interrupted"
+	}
+
+	s.Files, s.Bytes = w.status.Totals()
+	s.Host, _ = os.Hostname()
+	s.StartedAt, s.FinishedAt = started, time.Now()
+	s.Seconds = s.FinishedAt.Sub(started).Seconds()
+	return s
+}
+
+// message returns the title and text of a desktop notification for the run
+func (s runSummary) message() (string, string) {
+	target := s.InputFile
+	if len(s.URLs) > 0 {
+		target = s.URLs[0]
+	}
+	title := fmt.Sprintf("wget %s %s", s.Command, s.Status)
+	if s.Error != "" {
+		return title, fmt.Sprintf("%s: %s", target, s.Error)
+	}
+	return title, fmt.Sprintf("%s: %d files, %s in %s", target, s.Files, formatBytes(s.Bytes),
+		time.Duration(s.Seconds*float64(time.Second)).Round(time.Second))
+}
+
+// NotifyWebhook POSTs the run summary as JSON to notifyURL
+func (w *WgetClone) NotifyWebhook(notifyURL string, s runSummary) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	// The run's own context is cancelled after an interrupt, which is worth reporting too
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", notifyURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid notify URL: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.serviceClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notification failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification failed: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NotifyDesktop shows the run summary as a local desktop notification using the platform's
+// own tool: notify-send, osascript or PowerShell
+func NotifyDesktop(s runSummary) error {
+	title, message := s.message()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e",
+			`display notification (system attribute "WGET_NOTIFY_BODY") with title (system attribute "WGET_NOTIFY_TITLE")`)
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms; $n = New-Object System.Windows.Forms.NotifyIcon; "+
+				"$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; "+
+				"$n.ShowBalloonTip(10000, $env:WGET_NOTIFY_TITLE, $env:WGET_NOTIFY_BODY, 'Info'); Start-Sleep -Seconds 5; $n.Dispose()")
+	default:
+		cmd = exec.Command("notify-send", "--app-name=wget", title, message)
+	}
+	// The text goes through the environment so it never needs quoting for a script
+	cmd.Env = append(os.Environ(), "WGET_NOTIFY_TITLE="+title, "WGET_NOTIFY_BODY="+message)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if out = bytes.TrimSpace(out); len(out) > 0 {
+			return fmt.Errorf("desktop notification failed: %v: %s", err, out)
+		}
+		return fmt.Errorf("desktop notification failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLM negotiate flags (MS-NLMP 2.2.2.5) used here
+const (
+	ntlmUnicode         = 0x00000001
+	ntlmRequestTarget   = 0x00000004
+	ntlmNTLM            = 0x00000200
+	ntlmAlwaysSign      = 0x00008000
+	ntlmExtendedSession = 0x00080000
+	ntlmTargetInfo      = 0x00800000
+	ntlm128             = 0x20000000
+	ntlm56              = 0x80000000
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmAuthTransport answers NTLM challenges with the --user/--password credentials, for
+// --auth ntlm. The handshake takes two round trips that must share a connection, so the
+// bodies in between are drained to hand the connection back for reuse. The user may be
+// given as DOMAIN\user or user@domain.
+type ntlmAuthTransport struct {
+	base     http.RoundTripper
+	user     string
+	password string
+}
+
+func (t *ntlmAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || req.Header.Get("Authorization") != "" {
+		return resp, err
+	}
+	if !hasChallenge(resp.Header, "ntlm") {
+		return resp, nil
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil // The body was consumed and can't be replayed
+	}
+	drainBody(resp)
+
+	// Negotiate, then answer the server's challenge on the same connection
+	negotiate, err := replayRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	negotiate.Header.Set("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(ntlmNegotiateMessage()))
+	resp, err = t.base.RoundTrip(negotiate)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge, ok := ntlmChallengeHeader(resp.Header)
+	if !ok {
+		return resp, nil // The server gave up on NTLM
+	}
+	drainBody(resp)
+
+	domain, user := splitNTLMUser(t.user)
+	authenticate, err := ntlmAuthenticateMessage(challenge, domain, user, t.password)
+	if err != nil {
+		return nil, fmt.Errorf("NTLM authentication with %s failed: %w", req.URL.Host, err)
+	}
+	retry, err := replayRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(authenticate))
+	return t.base.RoundTrip(retry)
+}
+
+// replayRequest clones req with a fresh copy of its body
+func replayRequest(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+// drainBody reads out and closes the body of a response, so its connection can be reused
+func drainBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}
+
+// ntlmChallengeHeader returns the decoded challenge message of a WWW-Authenticate: NTLM header
+func ntlmChallengeHeader(header http.Header) ([]byte, bool) {
+	for _, value := range header.Values("WWW-Authenticate") {
+		scheme, token, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if !strings.EqualFold(scheme, "ntlm") || token == "" {
+			continue
+		}
+		if message, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token)); err == nil {
+			return message, true
+		}
+	}
+	return nil, false
+}
+
+// splitNTLMUser splits DOMAIN\user or user@domain; a plain name has no domain
+func splitNTLMUser(name string) (domain, user string) {
+	if domain, user, ok := strings.Cut(name, `\`); ok {
+		return domain, user
+	}
+	if user, domain, ok := strings.Cut(name, "@"); ok {
+		return domain, user
+	}
+	return "", name
+}
+
+// ntlmNegotiateMessage builds the first message of the handshake, without domain or workstation
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmUnicode|ntlmRequestTarget|ntlmNTLM|ntlmAlwaysSign|ntlmExtendedSession|ntlm128|ntlm56)
+	return msg
+}
+
+// ntlmAuthenticateMessage answers a challenge message with an NTLMv2 response
+func ntlmAuthenticateMessage(challenge []byte, domain, user, password string) ([]byte, error) {
+	if len(challenge) < 32 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errors.New("malformed challenge")
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+	var targetInfo []byte
+	if flags&ntlmTargetInfo != 0 && len(challenge) >= 48 {
+		length := int(binary.LittleEndian.Uint16(challenge[40:]))
+		offset := int(binary.LittleEndian.Uint32(challenge[44:]))
+		if offset+length > len(challenge) {
+			return nil, errors.New("malformed challenge target info")
+		}
+		targetInfo = challenge[offset : offset+length]
+	}
+
+	// NTOWFv2 = HMAC-MD5(MD4(password), UPPER(user) + domain), all UTF-16LE
+	hash := md4.New()
+	hash.Write(utf16le(password))
+	mac := hmac.New(md5.New, hash.Sum(nil))
+	mac.Write(utf16le(strings.ToUpper(user) + domain))
+	ntowf := mac.Sum(nil)
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+	timestamp, fromServer := ntlmTimestamp(targetInfo)
+	blob := make([]byte, 0, 28+len(targetInfo)+4)
+	blob = append(blob, 1, 1, 0, 0, 0, 0, 0, 0)
+	blob = binary.LittleEndian.AppendUint64(blob, timestamp)
+	blob = append(blob, clientChallenge...)
+	blob = append(blob, 0, 0, 0, 0)
+	blob = append(blob, targetInfo...)
+	blob = append(blob, 0, 0, 0, 0)
+
+	mac = hmac.New(md5.New, ntowf)
+	mac.Write(serverChallenge)
+	mac.Write(blob)
+	ntResponse := append(mac.Sum(nil), blob...)
+
+	lmResponse := make([]byte, 24) // Left zero when the server sent a timestamp (MS-NLMP 3.1.5.1.2)
+	if !fromServer {
+		mac = hmac.New(md5.New, ntowf)
+		mac.Write(serverChallenge)
+		mac.Write(clientChallenge)
+		lmResponse = append(mac.Sum(nil), clientChallenge...)
+	}
+
+	encode := utf16le
+	if flags&ntlmUnicode == 0 {
+		encode = func(s string) []byte { return []byte(s) }
+	}
+	fields := [][]byte{lmResponse, ntResponse, encode(domain), encode(user), encode(""), nil}
+	const headerSize = 64
+	msg := make([]byte, headerSize)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	offset := headerSize
+	for i, field := range fields {
+		pos := 12 + i*8
+		binary.LittleEndian.PutUint16(msg[pos:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(msg[pos+2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(msg[pos+4:], uint32(offset))
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags&^0x40000000) // No session key exchange
+	for _, field := range fields {
+		msg = append(msg, field...)
+	}
+	return msg, nil
+}
+
+// ntlmTimestamp returns the MsvAvTimestamp of the challenge's target info, or the current
+// time, as a Windows FILETIME, and whether it came from the server
+func ntlmTimestamp(targetInfo []byte) (uint64, bool) {
+	for len(targetInfo) >= 4 {
+		id := binary.LittleEndian.Uint16(targetInfo)
+		length := int(binary.LittleEndian.Uint16(targetInfo[2:]))
+		if id == 0 || 4+length > len(targetInfo) {
+			break
+		}
+		if id == 7 && length == 8 {
+			return binary.LittleEndian.Uint64(targetInfo[4:]), true
+		}
+		targetInfo = targetInfo[4+length:]
+	}
+	const epochDelta = 116444736000000000 // 100ns ticks from 1601 to 1970
+	return uint64(time.Now().UnixNano()/100) + epochDelta, false
+}
+
+// utf16le encodes s as UTF-16 little-endian
+func utf16le(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauthSecretEnv holds the --oauth-client-secret when it isn't given on the command line
+const oauthSecretEnv = "WGET_OAUTH_CLIENT_SECRET"
+
+// oauthToken gets and caches an OAuth2 bearer token with the client-credentials grant,
+// fetching a new one shortly before the old one expires
+type oauthToken struct {
+	client   *http.Client // Below the oauth transport, so token requests carry no token
+	tokenURL string
+	clientID string
+	secret   string
+	scope    string
+
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time // Zero when the server gave no lifetime
+}
+
+// oauthTokenResponse is the JSON a token endpoint answers with (RFC 6749, section 5)
+type oauthTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// newOAuthToken sets up the token source; the secret falls back to $WGET_OAUTH_CLIENT_SECRET
+func newOAuthToken(base http.RoundTripper, tokenURL, clientID, secret, scope string) (*oauthToken, error) {
+	if clientID == "" {
+		return nil, fmt.Errorf("--oauth-token-url needs --oauth-client-id")
+	}
+	if parsed, err := url.Parse(tokenURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("invalid --oauth-token-url: %s", tokenURL)
+	}
+	if secret == "" {
+		secret = os.Getenv(oauthSecretEnv)
+	}
+	return &oauthToken{
+		client:   &http.Client{Transport: base, Timeout: 30 * time.Second},
+		tokenURL: tokenURL,
+		clientID: clientID,
+		secret:   secret,
+		scope:    scope,
+	}, nil
+}
+
+// get returns a valid token, fetching a new one when there is none, it is about to expire,
+// or stale is the token a server just turned down
+func (o *oauthToken) get(ctx context.Context, stale string) (string, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	fresh := o.expiry.IsZero() || time.Until(o.expiry) > 30*time.Second
+	if o.token != "" && o.token != stale && fresh {
+		return o.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if o.scope != "" {
+		form.Set("scope", o.scope)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.SetBasicAuth(url.QueryEscape(o.clientID), url.QueryEscape(o.secret))
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OAuth token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var answer oauthTokenResponse
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err == nil {
+		err = json.Unmarshal(body, &answer)
+	}
+	if resp.StatusCode != http.StatusOK || answer.Error != "" {
+		reason := answer.Error
+		if answer.ErrorDescription != "" {
+			reason += ": " + answer.ErrorDescription
+		}
+		if reason == "" {
+			reason = resp.Status
+		}
+		return "", fmt.Errorf("OAuth token request failed: %s", reason)
+	}
+	if err != nil || answer.AccessToken == "" {
+		return "", fmt.Errorf("OAuth token endpoint sent no access_token")
+	}
+	if answer.TokenType != "" && !strings.EqualFold(answer.TokenType, "bearer") {
+		return "", fmt.Errorf("OAuth token type %q is not supported (want bearer)", answer.TokenType)
+	}
+
+	o.token, o.expiry = answer.AccessToken, time.Time{}
+	if answer.ExpiresIn > 0 {
+		o.expiry = time.Now().Add(time.Duration(answer.ExpiresIn) * time.Second)
+	}
+	return o.token, nil
+}
+
+// oauthTransport sends an OAuth2 bearer token with the requests to the hosts of the start
+// URLs, so redirects and links to other hosts don't carry it away. A 401 gets the request
+// one more try with a new token, for tokens revoked or expired before their time.
+type oauthTransport struct {
+	base  http.RoundTripper
+	token *oauthToken
+	hosts map[string]bool // Set up before the first request, then only read
+}
+
+// allow lets the token go to the hosts of urls
+func (t *oauthTransport) allow(urls ...string) {
+	if t == nil {
+		return
+	}
+	for _, urlStr := range urls {
+		if u, err := url.Parse(urlStr); err == nil && u.Hostname() != "" {
+			t.hosts[strings.ToLower(u.Hostname())] = true
+		}
+	}
+}
+
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req) // The request brings its own credentials
+	}
+	if !t.hosts[strings.ToLower(req.URL.Hostname())] {
+		return t.base.RoundTrip(req)
+	}
+	token, err := t.token.get(req.Context(), "")
+	if err != nil {
+		return nil, err
+	}
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", "Bearer "+token)
+	resp, err := t.base.RoundTrip(authed)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil // The body was consumed and can't be replayed
+	}
+
+	renewed, err := t.token.get(req.Context(), token)
+	if err != nil || renewed == token {
+		return resp, nil
+	}
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	resp.Body.Close()
+	retry.Header.Set("Authorization", "Bearer "+renewed)
+	return t.base.RoundTrip(retry)
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// objectStoreTransport serves s3:// and gs:// URLs by rewriting them to the providers' HTTPS
+// endpoints and authenticating with credentials from the standard environment/credential files.
+// Registered on the client transport so downloads reuse the normal progress and rate-limit path.
+type objectStoreTransport struct {
+	base http.RoundTripper
+
+	gcsMutex  sync.Mutex
+	gcsToken  string
+	gcsExpiry time.Time
+}
+
+func (t *objectStoreTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+
+	var err error
+	switch req.URL.Scheme {
+	case "s3":
+		err = signS3Request(out)
+	case "gs":
+		err = t.authorizeGCSRequest(out)
+	default:
+		err = fmt.Errorf("unsupported scheme %q", req.URL.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(out)
+}
+
+// iniSection reads one [section] of an AWS-style INI file into a map
+func iniSection(filename, section string) map[string]string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	current := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// awsCredentials is the resolved S3 configuration
+type awsCredentials struct {
+	accessKey, secretKey, sessionToken, region string
+}
+
+// loadAWSCredentials follows the usual chain: environment variables, then ~/.aws/credentials and ~/.aws/config
+func loadAWSCredentials() awsCredentials {
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	home, _ := os.UserHomeDir()
+
+	credsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credsFile == "" {
+		credsFile = filepath.Join(home, ".aws", "credentials")
+	}
+	configFile := os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		configFile = filepath.Join(home, ".aws", "config")
+	}
+	configSection := "profile " + profile
+	if profile == "default" {
+		configSection = "default"
+	}
+	fileCreds := iniSection(credsFile, profile)
+	fileConfig := iniSection(configFile, configSection)
+
+	creds := awsCredentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKey == "" {
+		creds.accessKey = fileCreds["aws_access_key_id"]
+		creds.secretKey = fileCreds["aws_secret_access_key"]
+		creds.sessionToken = fileCreds["aws_session_token"]
+	}
+
+	for _, region := range []string{os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), fileConfig["region"], "us-east-1"} {
+		if region != "" {
+			creds.region = region
+			break
+		}
+	}
+	return creds
+}
+
+// awsURIEncode percent-encodes everything except RFC 3986 unreserved characters (and '/' in paths)
+func awsURIEncode(s string, keepSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && keepSlash) {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// signS3Request rewrites s3://bucket/key to its HTTPS endpoint and signs it with AWS Signature V4.
+// Without credentials the request is sent unsigned, which works for public buckets.
+func signS3Request(req *http.Request) error {
+	bucket := req.URL.Host
+	key := req.URL.Path
+	if bucket == "" {
+		return fmt.Errorf("s3 URL has no bucket: %s", req.URL)
+	}
+	creds := loadAWSCredentials()
+
+	// AWS_ENDPOINT_URL (e.g. MinIO) uses path-style addressing; AWS itself virtual-hosted style
+	var endpoint *url.URL
+	if custom := os.Getenv("AWS_ENDPOINT_URL"); custom != "" {
+		parsed, err := url.Parse(custom)
+		if err != nil {
+			return fmt.Errorf("invalid AWS_ENDPOINT_URL: %w", err)
+		}
+		endpoint = &url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: strings.TrimSuffix(parsed.Path, "/") + "/" + bucket + key}
+	} else {
+		endpoint = &url.URL{Scheme: "https", Host: bucket + ".s3." + creds.region + ".amazonaws.com", Path: key}
+	}
+	endpoint.RawPath = awsURIEncode(endpoint.Path, true)
+	endpoint.RawQuery = req.URL.RawQuery
+	req.URL = endpoint
+	req.Host = endpoint.Host
+
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := hex.EncodeToString(sha256.New().Sum(nil)) // GET bodies are empty
+	if req.Body != nil && req.Body != http.NoBody {
+		payloadHash = "UNSIGNED-PAYLOAD" // Uploads are streamed, so their hash isn't known up front
+	}
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": endpoint.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := endpoint.Query()
+	queryKeys := make([]string, 0, len(query))
+	for k := range query {
+		queryKeys = append(queryKeys, k)
+	}
+	sort.Strings(queryKeys)
+	var canonicalQuery []string
+	for _, k := range queryKeys {
+		for _, v := range query[k] {
+			canonicalQuery = append(canonicalQuery, awsURIEncode(k, false)+"="+awsURIEncode(v, false))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		endpoint.RawPath,
+		strings.Join(canonicalQuery, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + creds.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+creds.secretKey), date)
+	signingKey = hmacSHA256(signingKey, creds.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+// gcsCredentialsFile is the subset of Google credential JSON files we understand
+type gcsCredentialsFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// gcsAccessToken resolves a bearer token: GOOGLE_OAUTH_ACCESS_TOKEN, then GOOGLE_APPLICATION_CREDENTIALS
+// or the gcloud application-default credentials. An empty token means anonymous access.
+func (t *objectStoreTransport) gcsAccessToken() (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	t.gcsMutex.Lock()
+	defer t.gcsMutex.Unlock()
+	if t.gcsToken != "" && time.Now().Before(t.gcsExpiry) {
+		return t.gcsToken, nil
+	}
+
+	credsPath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if credsPath == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", nil
+		}
+		credsPath = filepath.Join(configDir, "gcloud", "application_default_credentials.json")
+	}
+	data, err := os.ReadFile(credsPath)
+	if err != nil {
+		return "", nil
+	}
+	var creds gcsCredentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", fmt.Errorf("invalid Google credentials '%s': %w", credsPath, err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	form := url.Values{}
+	switch creds.Type {
+	case "service_account":
+		assertion, err := signServiceAccountJWT(creds)
+		if err != nil {
+			return "", err
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", creds.ClientID)
+		form.Set("client_secret", creds.ClientSecret)
+		form.Set("refresh_token", creds.RefreshToken)
+	default:
+		return "", fmt.Errorf("unsupported Google credentials type %q", creds.Type)
+	}
+
+	resp, err := t.base.RoundTrip(mustFormRequest(creds.TokenURI, form))
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: HTTP %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+
+	t.gcsToken = token.AccessToken
+	t.gcsExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return t.gcsToken, nil
+}
+
+// mustFormRequest builds a URL-encoded POST; the inputs are trusted credentials file values
+func mustFormRequest(tokenURI string, form url.Values) *http.Request {
+	req, _ := http.NewRequest("POST", tokenURI, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+// signServiceAccountJWT builds the RS256 assertion for the OAuth2 JWT bearer grant
+func signServiceAccountJWT(creds gcsCredentialsFile) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("invalid service account private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid service account private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("service account key is not RSA")
+	}
+
+	now := time.Now().Unix()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   creds.ClientEmail,
+		"scope": gcsScope,
+		"aud":   creds.TokenURI,
+		"iat":   now,
+		"exp":   now + 3600,
+	})
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign service account assertion: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// authorizeGCSRequest rewrites gs://bucket/object to the Cloud Storage XML API and adds a bearer token
+func (t *objectStoreTransport) authorizeGCSRequest(req *http.Request) error {
+	bucket := req.URL.Host
+	if bucket == "" {
+		return fmt.Errorf("gs URL has no bucket: %s", req.URL)
+	}
+
+	endpoint := &url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + bucket + req.URL.Path, RawQuery: req.URL.RawQuery}
+	req.URL = endpoint
+	req.Host = endpoint.Host
+
+	token, err := t.gcsAccessToken()
+	if err != nil {
+		return fmt.Errorf("failed to get Google credentials: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// passwordEnv and proxyPasswordEnv pass prompted passwords on to background and scheduled
+// child runs without putting them on their command line
+const (
+	passwordEnv      = "WGET_PASSWORD"
+	proxyPasswordEnv = "WGET_PROXY_PASSWORD"
+)
+
+// promptPassword asks for a secret on the terminal without echoing it
+func promptPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("cannot prompt for a password: stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(secret), nil
+}
+
+// resolvePasswords fills in the --user and --proxy-user passwords from the environment or,
+// when asked to or when none was given, an interactive prompt
+func resolvePasswords(opts *cliOptions) error {
+	if err := resolveSecret(opts.user, &opts.password, opts.askPassword, passwordEnv, "user"); err != nil {
+		return err
+	}
+	return resolveSecret(opts.proxyUser, &opts.proxyPassword, opts.askPassword, proxyPasswordEnv, "proxy user")
+}
+
+// resolveSecret resolves the password of one account; kind names it in the prompt
+func resolveSecret(user string, password *string, ask bool, envName, kind string) error {
+	if user == "" || (*password != "" && !ask) {
+		return nil
+	}
+	if env := os.Getenv(envName); env != "" && !ask {
+		*password = env
+		return nil
+	}
+
+	secret, err := promptPassword(fmt.Sprintf("Password for %s '%s': ", kind, user))
+	if err != nil {
+		return err
+	}
+	*password = secret
+	os.Setenv(envName, secret) // Inherited by -B and --schedule children
+	return nil
+}
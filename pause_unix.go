@@ -0,0 +1,38 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// SetupPauseHandling pauses transfers on SIGTSTP (Ctrl+Z) and resumes them on a second SIGTSTP or SIGCONT
+func (w *WgetClone) SetupPauseHandling() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTSTP, syscall.SIGCONT)
+
+	go func() {
+		for sig := range c {
+			var paused, changed bool
+			if sig == syscall.SIGCONT {
+				changed = w.pause.Resume()
+			} else {
+				paused, changed = w.pause.Toggle(), true
+			}
+			if !changed {
+				continue
+			}
+
+			stdoutMutex.Lock()
+			if paused {
+				fmt.Printf("\nDownloads paused (PID %d), press Ctrl+Z again or send SIGCONT to resume\n", os.Getpid())
+			} else {
+				fmt.Println("\nDownloads resumed")
+			}
+			stdoutMutex.Unlock()
+		}
+	}()
+}
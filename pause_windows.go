@@ -0,0 +1,6 @@
+//go:build windows
+
+package main
+
+// SetupPauseHandling is a no-op on Windows, which has no job control signals
+func (w *WgetClone) SetupPauseHandling() {}
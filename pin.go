@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const pinPrefix = "sha256//"
+
+// errPinMismatch fails a connection whose server key isn't one of --pinned-pubkey. It is not retried.
+var errPinMismatch = errors.New("server public key doesn't match --pinned-pubkey")
+
+// parsePins reads --pinned-pubkey: sha256//BASE64 pins separated by ';', or a file holding
+// such pins one per line, or a PEM/DER public key or PEM certificate to pin the key of.
+// It returns the set of base64 SHA-256 hashes of the accepted SubjectPublicKeyInfo.
+func parsePins(value string) (map[string]bool, error) {
+	if strings.HasPrefix(value, pinPrefix) {
+		return parsePinList(strings.Split(value, ";"))
+	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --pinned-pubkey: %w", err)
+	}
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		pins := make(map[string]bool)
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			switch block.Type {
+			case "PUBLIC KEY":
+				if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+					return nil, fmt.Errorf("invalid public key in %s: %w", value, err)
+				}
+				pins[spkiPin(block.Bytes)] = true
+			case "CERTIFICATE":
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, fmt.Errorf("invalid certificate in %s: %w", value, err)
+				}
+				pins[spkiPin(cert.RawSubjectPublicKeyInfo)] = true
+			}
+		}
+		if len(pins) == 0 {
+			return nil, fmt.Errorf("no public key or certificate in %s", value)
+		}
+		return pins, nil
+	}
+	if _, err := x509.ParsePKIXPublicKey(data); err == nil {
+		return map[string]bool{spkiPin(data): true}, nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.Split(line, ";")...)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no pins in %s", value)
+	}
+	return parsePinList(lines)
+}
+
+// parsePinList checks sha256//BASE64 pins; the prefix may be left out
+func parsePinList(list []string) (map[string]bool, error) {
+	pins := make(map[string]bool)
+	for _, pin := range list {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), pinPrefix)
+		if sum, err := base64.StdEncoding.DecodeString(pin); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin '%s' (want sha256// and a base64 SHA-256 hash)", pin)
+		}
+		pins[pin] = true
+	}
+	return pins, nil
+}
+
+// spkiPin returns the base64 SHA-256 hash of a DER SubjectPublicKeyInfo
+func spkiPin(spki []byte) string {
+	sum := sha256.Sum256(spki)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// pinPublicKey refuses TLS connections whose server certificate's key is not pinned. The usual
+// certificate checks still apply on top.
+func pinPublicKey(transport *http.Transport, pins map[string]bool) {
+	addConnectionCheck(transport, func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errPinMismatch
+		}
+		pin := spkiPin(state.PeerCertificates[0].RawSubjectPublicKeyInfo)
+		if !pins[pin] {
+			return fmt.Errorf("%w: %s presented %s%s", errPinMismatch, state.ServerName, pinPrefix, pin)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// pipeExitError reports a --pipe-to command that failed; wget exits with the same status
+type pipeExitError struct {
+	command string
+	code    int
+}
+
+func (e *pipeExitError) Error() string {
+	return fmt.Sprintf("--pipe-to command '%s' exited with status %d", e.command, e.code)
+}
+
+// shellCommand runs command through the system shell, so it can hold pipes and redirections
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("/bin/sh", "-c", command)
+}
+
+// DownloadPiped streams the body of urlStr into the standard input of command instead of a
+// file, for --pipe-to. The command's output goes to ours; progress counts the bytes it was fed.
+func (w *WgetClone) DownloadPiped(urlStr, command string, rateLimit int64) error {
+	fmt.Printf("Starting download at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	cmd := shellCommand(command)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start --pipe-to command: %w", err)
+	}
+
+	written, err := w.appendURL(stdin, filepath.Base(w.outputPathFor(urlStr, "", "", false)), urlStr, rateLimit)
+	stdin.Close()
+	waitErr := cmd.Wait()
+	w.stats.fileDone(err)
+	w.urlMap.record(urlStr, "", err)
+
+	// A command that quit early also breaks the pipe; its status says more than the write error
+	var exit *exec.ExitError
+	if errors.As(waitErr, &exit) {
+		return &pipeExitError{command: command, code: exit.ExitCode()}
+	}
+	if err != nil {
+		if w.IsInterrupted() {
+			return fmt.Errorf("download interrupted")
+		}
+		return err
+	}
+	if waitErr != nil {
+		return fmt.Errorf("--pipe-to command failed: %w", waitErr)
+	}
+
+	fmt.Printf("%s %s\n", colorize(colorGreen, "Downloaded successfully:"), urlStr)
+	fmt.Printf("Finished at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Total piped: %s\n", formatBytes(written))
+	return nil
+}
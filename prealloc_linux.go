@@ -0,0 +1,24 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves disk blocks for length bytes of file from offset without changing its size,
+// so a full disk is reported before the transfer and the file is laid out contiguously.
+// Filesystems that can't preallocate are left to allocate as data arrives.
+func preallocate(file *os.File, offset, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+	err := unix.Fallocate(int(file.Fd()), unix.FALLOC_FL_KEEP_SIZE, offset, length)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		return nil
+	}
+	return err
+}
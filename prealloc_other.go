@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// preallocate is a no-op where blocks can't be reserved without growing the file,
+// which would break resuming from the .part file's size
+func preallocate(file *os.File, offset, length int64) error {
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressLineInterval = 5 * time.Second // How often the lines style reports
+
+	speedWindowSpan = 5 * time.Second // Speed is averaged over this much recent history
+	minBarWidth     = 10              // Narrower than this, the bar is dropped
+	defaultColumns  = 80              // Used when stdout is not a terminal
+)
+
+// progressStyle is how transfers report progress, chosen with --progress
+type progressStyle struct {
+	name           string // bar, lines (bar without a terminal), dot or none
+	dotBytes       int64  // Bytes per dot
+	dotsPerCluster int
+	dotsPerLine    int
+}
+
+// parseProgressStyle parses --progress: bar, none, or wget's dot and dot:mega styles
+func parseProgressStyle(s string) (progressStyle, error) {
+	switch s {
+	case "", "bar":
+		// Without a terminal, redrawing with \r and escape codes only litters log files
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			return progressStyle{name: "lines"}, nil
+		}
+		return progressStyle{name: "bar"}, nil
+	case "bar:force":
+		return progressStyle{name: "bar"}, nil
+	case "none":
+		return progressStyle{name: "none"}, nil
+	case "dot", "dot:default":
+		return progressStyle{name: "dot", dotBytes: 1 << 10, dotsPerCluster: 10, dotsPerLine: 50}, nil
+	case "dot:mega":
+		return progressStyle{name: "dot", dotBytes: 64 << 10, dotsPerCluster: 8, dotsPerLine: 48}, nil
+	}
+	return progressStyle{}, fmt.Errorf("unknown progress style %q (use bar, bar:force, dot, dot:mega or none)", s)
+}
+
+// setupProgress applies --progress to every transfer of the run
+func setupProgress(name string) error {
+	style, err := parseProgressStyle(name)
+	if err != nil {
+		return err
+	}
+	currentProgressStyle = style
+	return nil
+}
+
+var (
+	currentProgressStyle = progressStyle{name: "bar"}
+
+	activeProgress  *ProgressWriter // Last bar drawn, redrawn when the terminal is resized; guarded by stdoutMutex
+	terminalColumns int             // Cached terminal width, 0 when it must be re-read; guarded by stdoutMutex
+)
+
+// speedSample is the byte count of a transfer at one moment
+type speedSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// speedWindow computes transfer speed over the last few seconds rather than the whole transfer
+type speedWindow struct {
+	samples []speedSample
+}
+
+func (s *speedWindow) add(at time.Time, bytes int64) {
+	s.samples = append(s.samples, speedSample{at, bytes})
+	cutoff := at.Add(-speedWindowSpan)
+	for len(s.samples) > 2 && s.samples[1].at.Before(cutoff) {
+		s.samples = s.samples[1:]
+	}
+}
+
+// rate returns bytes per second across the window, or 0 before two samples exist
+func (s *speedWindow) rate() float64 {
+	if len(s.samples) < 2 {
+		return 0
+	}
+	first, last := s.samples[0], s.samples[len(s.samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(last.bytes-first.bytes) / elapsed
+}
+
+// formatSpeed formats bytes per second, showing dashes while the speed is unknown
+func formatSpeed(bps float64) string {
+	if bps <= 0 {
+		return "--.- KB/s"
+	}
+	return formatBytes(int64(bps)) + "/s"
+}
+
+// formatETA formats a duration compactly: 42s, 3m07s, 2h15m
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// terminalWidth returns the width of the terminal on stdout; stdoutMutex must be held
+func terminalWidth() int {
+	if terminalColumns == 0 {
+		terminalColumns = defaultColumns
+		if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+			terminalColumns = width
+		}
+	}
+	return terminalColumns
+}
+
+// fitProgressLine lays out "name pct [bar] stats" within the terminal width, shrinking the
+// bar and then the file name to fit. A negative fraction draws no bar.
+func fitProgressLine(name, percent string, fraction float64, stats string) string {
+	width := terminalWidth() - 1 // Filling the last column makes some terminals wrap
+	fixed := len(percent) + len(stats)
+	if fraction < 0 {
+		return truncateName(name, width-fixed) + percent + stats
+	}
+
+	barWidth := width - fixed - utf8.RuneCountInString(name) - 3 // " [" and "]"
+	if barWidth < minBarWidth {
+		name = truncateName(name, width-fixed-3-minBarWidth)
+		barWidth = width - fixed - utf8.RuneCountInString(name) - 3
+	}
+	if barWidth < minBarWidth {
+		return truncateName(name, width-fixed) + percent + stats
+	}
+
+	filled := min(max(int(fraction*float64(barWidth)), 0), barWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return name + percent + " [" + bar + "]" + stats
+}
+
+// truncateName shortens name to at most n characters, marking the cut with "..."
+func truncateName(name string, n int) string {
+	runes := []rune(name)
+	switch {
+	case len(runes) <= n:
+		return name
+	case n <= 3:
+		return string(runes[:max(n, 0)])
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// startDots opens the first line of dots before any bytes of this run are counted. A resumed
+// transfer starts mid-line, with commas for the bytes already on disk. stdoutMutex must be held.
+func (p *ProgressWriter) startDots() {
+	style := currentProgressStyle
+	lineBytes := style.dotBytes * int64(style.dotsPerLine)
+	p.dotsStarted = true
+	p.dotsShown = p.written / style.dotBytes * style.dotBytes
+	p.lineStart, p.lineBytes = p.startTime, p.dotsShown
+	if skipped := p.dotsShown % lineBytes; skipped > 0 || p.dotsShown == 0 {
+		p.startDotLine(p.dotsShown - skipped)
+		for i := int64(0); i < skipped/style.dotBytes; i++ {
+			p.printDot(i, ",")
+		}
+	}
+}
+
+// renderDots prints a dot for every dotBytes received, wget style, ending each line with the
+// percentage, the speed over that line and the ETA; stdoutMutex must be held
+func (p *ProgressWriter) renderDots() {
+	style := currentProgressStyle
+	lineBytes := style.dotBytes * int64(style.dotsPerLine)
+	if !p.dotsStarted {
+		p.startDots()
+	}
+
+	for p.dotsShown+style.dotBytes <= p.written {
+		index := p.dotsShown % lineBytes / style.dotBytes
+		if index == 0 && p.dotsShown > 0 {
+			p.startDotLine(p.dotsShown)
+		}
+		p.printDot(index, ".")
+		p.dotsShown += style.dotBytes
+		if p.dotsShown%lineBytes == 0 {
+			p.endDotLine(false)
+		}
+	}
+}
+
+// startDotLine begins a line of dots labelled with its offset in kilobytes
+func (p *ProgressWriter) startDotLine(offset int64) {
+	fmt.Printf("%7dK", offset>>10)
+}
+
+// printDot prints one dot, separating clusters with a space
+func (p *ProgressWriter) printDot(index int64, dot string) {
+	if index%int64(currentProgressStyle.dotsPerCluster) == 0 {
+		fmt.Print(" ")
+	}
+	fmt.Print(dot)
+}
+
+// endDotLine finishes a line of dots; the last line is padded and shows the total time instead of an ETA
+func (p *ProgressWriter) endDotLine(final bool) {
+	style := currentProgressStyle
+	now := time.Now()
+	done := p.dotsShown // Lines report where they end, not how far the transfer has got since
+	if final {
+		done = p.written
+	}
+	bps := float64(done-p.lineBytes) / now.Sub(p.lineStart).Seconds()
+
+	if final {
+		lineBytes := style.dotBytes * int64(style.dotsPerLine)
+		shown := p.dotsShown % lineBytes
+		if shown == 0 && p.dotsShown > 0 {
+			p.startDotLine(p.dotsShown) // The last full line was already closed
+		}
+		for i := shown / style.dotBytes; i < int64(style.dotsPerLine); i++ {
+			p.printDot(i, " ")
+		}
+	}
+
+	if p.total > 0 {
+		fmt.Printf(" %3.0f%%", float64(done)/float64(p.total)*100)
+	}
+	fmt.Printf(" %s", formatSpeed(bps))
+	switch {
+	case final:
+		fmt.Printf("=%s", formatETA(now.Sub(p.startTime)))
+	case p.total > 0 && bps > 0:
+		fmt.Printf(" %s", formatETA(time.Duration(float64(p.total-done)/bps*float64(time.Second))))
+	}
+	fmt.Println()
+	p.lineStart, p.lineBytes = now, done
+}
+
+// renderLine prints a plain status line with the speed since the previous one, for logs
+// where the bar can't redraw in place; stdoutMutex must be held
+func (p *ProgressWriter) renderLine() {
+	now := time.Now()
+	bps := float64(p.written-p.lineBytes) / now.Sub(p.lineStart).Seconds()
+	p.lineStart, p.lineBytes = now, p.written
+
+	stats := " " + formatBytes(p.written)
+	if p.total > 0 {
+		stats += "/" + formatBytes(p.total)
+	}
+	stats += " " + formatSpeed(bps)
+	if p.total > 0 && bps > 0 && p.written < p.total {
+		stats += " eta " + formatETA(time.Duration(float64(p.total-p.written)/bps*float64(time.Second)))
+	}
+	fmt.Println(p.filename + p.percent() + stats)
+}
+
+// percent formats how much of a transfer of known size is done, or nothing when the size is unknown
+func (p *ProgressWriter) percent() string {
+	if p.total <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" %3.0f%%", float64(p.written)/float64(p.total)*100)
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var resizeOnce sync.Once
+
+// watchTerminalResize starts redrawing the active progress bar at the new width on SIGWINCH
+func watchTerminalResize() {
+	resizeOnce.Do(func() {
+		resized := make(chan os.Signal, 1)
+		signal.Notify(resized, syscall.SIGWINCH)
+		go func() {
+			for range resized {
+				stdoutMutex.Lock()
+				terminalColumns = 0
+				if activeProgress != nil {
+					activeProgress.render()
+				}
+				stdoutMutex.Unlock()
+			}
+		}()
+	})
+}
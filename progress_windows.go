@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+// watchTerminalResize makes the next redraw re-read the console width, since Windows has no SIGWINCH
+func watchTerminalResize() {
+	terminalColumns = 0
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// proxyAuthTransport authenticates to HTTP proxies with the --proxy-user/--proxy-password credentials.
+// Basic credentials are sent up front; once the proxy answers 407 with a Digest challenge, Digest is
+// used instead. HTTPS targets are authenticated on the CONNECT request through the transport's hooks.
+type proxyAuthTransport struct {
+	base     http.RoundTripper
+	proxy    func(*http.Request) (*url.URL, error)
+	user     string
+	password string
+
+	mutex      sync.Mutex
+	digest     *digestChallenge
+	nc         int // Requests made with the current nonce
+	challenges int // Bumped every time the proxy sends a new challenge
+}
+
+// newProxyAuthTransport wraps base and hooks the CONNECT handshake of transport, the *http.Transport under it
+func newProxyAuthTransport(base http.RoundTripper, transport *http.Transport, user, password string) *proxyAuthTransport {
+	t := &proxyAuthTransport{base: base, proxy: transport.Proxy, user: user, password: password}
+	transport.GetProxyConnectHeader = func(ctx context.Context, proxyURL *url.URL, target string) (http.Header, error) {
+		return http.Header{"Proxy-Authorization": {t.authorization("CONNECT", target)}}, nil
+	}
+	transport.OnProxyConnectResponse = func(ctx context.Context, proxyURL *url.URL, connectReq *http.Request, connectRes *http.Response) error {
+		if connectRes.StatusCode == http.StatusProxyAuthRequired {
+			t.learn(connectRes.Header)
+		}
+		return nil
+	}
+	return t
+}
+
+func (t *proxyAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.proxy == nil || (req.URL.Scheme != "http" && req.URL.Scheme != "https") {
+		return t.base.RoundTrip(req)
+	}
+	if proxyURL, err := t.proxy(req); err != nil || proxyURL == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	// HTTPS requests are tunnelled: the CONNECT hooks carry the credentials, and a
+	// failed handshake that taught us a new challenge is worth one more attempt
+	if req.URL.Scheme == "https" {
+		seen := t.challengeCount()
+		resp, err := t.base.RoundTrip(req)
+		if err != nil && t.challengeCount() != seen && replayable(req) {
+			if retry, ok := cloneForRetry(req); ok {
+				return t.base.RoundTrip(retry)
+			}
+		}
+		return resp, err
+	}
+
+	// Plain HTTP requests go to the proxy itself, so the header rides on the request
+	first := req.Clone(req.Context())
+	first.Header.Set("Proxy-Authorization", t.authorization(req.Method, req.URL.String()))
+	resp, err := t.base.RoundTrip(first)
+	if err != nil || resp.StatusCode != http.StatusProxyAuthRequired || !t.learn(resp.Header) || !replayable(req) {
+		return resp, err
+	}
+
+	retry, ok := cloneForRetry(req)
+	if !ok {
+		return resp, nil
+	}
+	resp.Body.Close()
+	retry.Header.Set("Proxy-Authorization", t.authorization(req.Method, req.URL.String()))
+	return t.base.RoundTrip(retry)
+}
+
+// authorization returns the Proxy-Authorization value for a request, Digest once a challenge is known
+func (t *proxyAuthTransport) authorization(method, uri string) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.digest == nil {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(t.user+":"+t.password))
+	}
+	t.nc++
+	return t.digest.authorization(t.user, t.password, method, uri, t.nc)
+}
+
+// learn records the Digest challenge in a 407 response and reports whether there was one
+func (t *proxyAuthTransport) learn(header http.Header) bool {
+	for _, value := range header.Values("Proxy-Authenticate") {
+		challenge, ok := parseDigestChallenge(value)
+		if !ok {
+			continue
+		}
+		t.mutex.Lock()
+		if t.digest == nil || t.digest.nonce != challenge.nonce {
+			t.nc = 0
+		}
+		t.digest = challenge
+		t.challenges++
+		t.mutex.Unlock()
+		return true
+	}
+	return false
+}
+
+func (t *proxyAuthTransport) challengeCount() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.challenges
+}
+
+// replayable reports whether req can be sent a second time
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// cloneForRetry copies req with a fresh body for a second attempt
+func cloneForRetry(req *http.Request) (*http.Request, bool) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		retry.Body = body
+	}
+	return retry, true
+}
+
+// digestChallenge is a parsed RFC 7616 Digest challenge
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // "auth" when the server offers it, otherwise empty (RFC 2069 style)
+}
+
+// parseDigestChallenge parses a "Digest realm=..., nonce=..." challenge, reporting false for
+// other schemes and for algorithms we can't compute
+func parseDigestChallenge(header string) (*digestChallenge, bool) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	if !strings.EqualFold(scheme, "digest") {
+		return nil, false
+	}
+
+	params := parseAuthParams(rest)
+	c := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+	}
+	if c.nonce == "" {
+		return nil, false
+	}
+	switch strings.ToUpper(c.algorithm) {
+	case "", "MD5", "MD5-SESS", "SHA-256", "SHA-256-SESS":
+	default:
+		return nil, false
+	}
+	for _, qop := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(qop) == "auth" {
+			c.qop = "auth"
+		}
+	}
+	return c, true
+}
+
+// parseAuthParams splits the comma-separated key=value and key="quoted value" pairs of a challenge
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimLeft(s, ", \t") {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, "\"") {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[key] = value.String()
+	}
+	return params
+}
+
+// authorization computes the Authorization header answering the challenge; nc counts uses of the nonce
+func (c *digestChallenge) authorization(user, password, method, uri string, nc int) string {
+	algorithm := strings.ToUpper(c.algorithm)
+	newHash := md5.New
+	if strings.HasPrefix(algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+	digest := func(parts ...string) string {
+		return hashHex(newHash(), strings.Join(parts, ":"))
+	}
+
+	cnonceBytes := make([]byte, 8)
+	rand.Read(cnonceBytes)
+	cnonce := hex.EncodeToString(cnonceBytes)
+	count := fmt.Sprintf("%08x", nc)
+
+	ha1 := digest(user, c.realm, password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = digest(ha1, c.nonce, cnonce)
+	}
+	ha2 := digest(method, uri)
+
+	var response string
+	if c.qop != "" {
+		response = digest(ha1, c.nonce, count, cnonce, c.qop, ha2)
+	} else {
+		response = digest(ha1, c.nonce, ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		user, c.realm, c.nonce, uri, response)
+	if c.algorithm != "" {
+		header += ", algorithm=" + c.algorithm
+	}
+	if c.opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, c.opaque)
+	}
+	if c.qop != "" {
+		header += fmt.Sprintf(", qop=%s, nc=%s, cnonce=\"%s\"", c.qop, count, cnonce)
+	}
+	return header
+}
+
+func hashHex(h hash.Hash, s string) string {
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
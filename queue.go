@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DownloadQueue persists batch progress so an interrupted -i run can pick up where it stopped.
+// The file is an append-only log of "pending <url>" and "done <url>" lines.
+type DownloadQueue struct {
+	path  string
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// OpenDownloadQueue loads (or creates) the queue file and returns the URLs still left to download.
+// An existing queue takes precedence over urls so a re-run resumes the original batch.
+func OpenDownloadQueue(queuePath string, urls []string) (*DownloadQueue, []string, error) {
+	var pending []string
+	done := make(map[string]bool)
+
+	existing, err := os.Open(queuePath)
+	if err == nil {
+		scanner := bufio.NewScanner(existing)
+		for scanner.Scan() {
+			state, urlStr, ok := strings.Cut(scanner.Text(), " ")
+			if !ok {
+				continue // Torn write from a crash
+			}
+			switch state {
+			case "pending":
+				pending = append(pending, urlStr)
+			case "done":
+				done[urlStr] = true
+			}
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read queue file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("failed to open queue file: %w", err)
+	}
+
+	file, err := os.OpenFile(queuePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open queue file: %w", err)
+	}
+	q := &DownloadQueue{path: queuePath, file: file}
+
+	if pending == nil {
+		// Fresh queue: record the whole batch before starting
+		var sb strings.Builder
+		for _, urlStr := range urls {
+			sb.WriteString("pending " + urlStr + "\n")
+		}
+		if _, err := file.WriteString(sb.String()); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to write queue file: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to write queue file: %w", err)
+		}
+		return q, urls, nil
+	}
+
+	var remaining []string
+	for _, urlStr := range pending {
+		if !done[urlStr] {
+			remaining = append(remaining, urlStr)
+		}
+	}
+	if skipped := len(pending) - len(remaining); skipped > 0 {
+		fmt.Printf("Resuming queue '%s': skipping %d completed URLs\n", queuePath, skipped)
+	}
+	return q, remaining, nil
+}
+
+// MarkDone records urlStr as completed, syncing so the mark survives a crash
+func (q *DownloadQueue) MarkDone(urlStr string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, err := q.file.WriteString("done " + urlStr + "\n"); err != nil {
+		return err
+	}
+	return q.file.Sync()
+}
+
+// Close releases the queue file, deleting it once every URL has been downloaded
+func (q *DownloadQueue) Close(allDone bool) {
+	q.file.Close()
+	if allDone {
+		os.Remove(q.path)
+	}
+}
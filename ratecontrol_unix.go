@@ -0,0 +1,66 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// SetupRateControl changes the rate limit on SIGUSR2: to the value left by `wget jobs rate`
+// when there is one, otherwise to the next of presets
+func (w *WgetClone) SetupRateControl(presets []int64) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR2)
+
+	go func() {
+		for range c {
+			if limit, ok := takeRateRequest(os.Getpid()); ok {
+				setLiveRate(limit)
+				announceRate(limit)
+				continue
+			}
+			stepRatePreset(presets)
+		}
+	}()
+}
+
+// rateRequestPath is where `wget jobs rate` leaves the new limit for process pid
+func rateRequestPath(pid int) (string, error) {
+	dir, err := jobsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, strconv.Itoa(pid)+".rate"), nil
+}
+
+// takeRateRequest reads and removes the rate limit requested for process pid
+func takeRateRequest(pid int) (int64, bool) {
+	path, err := rateRequestPath(pid)
+	if err != nil {
+		return 0, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	os.Remove(path)
+	limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return limit, err == nil
+}
+
+// requestRate asks the running process pid to switch to limit
+func requestRate(pid int, limit int64) error {
+	path, err := rateRequestPath(pid)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(strconv.FormatInt(limit, 10)), 0o644); err != nil {
+		return err
+	}
+	return syscall.Kill(pid, syscall.SIGUSR2)
+}
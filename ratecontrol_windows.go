@@ -0,0 +1,13 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+// SetupRateControl is a no-op on Windows, which has no SIGUSR2
+func (w *WgetClone) SetupRateControl(presets []int64) {}
+
+// requestRate is unsupported on Windows, which can't signal a running download
+func requestRate(pid int, limit int64) error {
+	return fmt.Errorf("changing the rate of a running job is not supported on Windows")
+}
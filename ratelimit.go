@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minRateBurst = 1024       // Smallest default burst, so slow limits don't read a few bytes at a time
+	maxRateBurst = 256 * 1024 // Largest default burst
+)
+
+// rateBurst is the --rate-burst size in bytes; 0 means a tenth of a second at the limit
+var rateBurst int64
+
+// liveRate is the --rate-limit in force, which SIGUSR2 and `wget jobs rate` change while
+// transfers run. A reader created with a share of the starting limit (one --source of
+// several) keeps that share of the new one.
+var liveRate struct {
+	mutex      sync.Mutex
+	configured int64 // --rate-limit at start; 0 is unlimited
+	current    int64
+	generation int // Bumped on every change so buckets notice
+}
+
+// setLiveRate changes the rate limit of every running and later transfer; 0 lifts it
+func setLiveRate(limit int64) {
+	liveRate.mutex.Lock()
+	defer liveRate.mutex.Unlock()
+	liveRate.current = limit
+	liveRate.generation++
+}
+
+// parseRatePresets parses the --rate-presets list; "0" and "off" mean unlimited
+func parseRatePresets(list string) ([]int64, error) {
+	var presets []int64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if strings.EqualFold(field, "off") {
+			presets = append(presets, 0)
+			continue
+		}
+		rate, err := parseRateLimit(field)
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, rate)
+	}
+	return presets, nil
+}
+
+// formatRate describes a rate limit for messages
+func formatRate(limit int64) string {
+	if limit <= 0 {
+		return "unlimited"
+	}
+	return formatBytes(limit) + "/s"
+}
+
+// tokenBucket lets bytes through at rate per second on average and up to burst at once.
+// Reads that overdraw it leave a debt that the next caller waits out, so the long-run rate
+// is exact however the reads are sized. A zero rate lets everything through.
+type tokenBucket struct {
+	mutex      sync.Mutex
+	base       int64 // Rate asked for when created, under liveRate.configured
+	generation int
+	rate       float64
+	burst      float64
+	tokens     float64
+	last       time.Time
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	b := &tokenBucket{base: rate, generation: -1, last: time.Now()}
+	b.follow()
+	b.tokens = b.burst
+	return b
+}
+
+// follow picks up a changed liveRate; the caller holds b.mutex or owns b
+func (b *tokenBucket) follow() {
+	liveRate.mutex.Lock()
+	defer liveRate.mutex.Unlock()
+	if b.generation == liveRate.generation {
+		return
+	}
+	b.generation = liveRate.generation
+
+	rate := b.base
+	switch {
+	case liveRate.generation == 0: // Never changed
+	case liveRate.configured > 0 && b.base > 0:
+		rate = b.base * liveRate.current / liveRate.configured
+	default:
+		rate = liveRate.current
+	}
+	burst := rateBurst
+	if burst <= 0 {
+		burst = min(max(rate/10, minRateBurst), maxRateBurst)
+	}
+	b.rate, b.burst = float64(rate), float64(burst)
+	b.tokens = min(b.tokens, b.burst)
+}
+
+// readSize is the most a single read may take, or 0 when unlimited
+func (b *tokenBucket) readSize() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.follow()
+	if b.rate <= 0 {
+		return 0
+	}
+	return int(b.burst)
+}
+
+// take spends n tokens and returns how long to wait before they are covered
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	if b.rate <= 0 {
+		b.last = now
+		return 0
+	}
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// RateLimitedReader wraps an io.Reader to limit read speed with a token bucket. It follows
+// run-time rate changes, so readers are created even for unlimited transfers.
+type RateLimitedReader struct {
+	reader io.Reader
+	bucket *tokenBucket
+}
+
+func NewRateLimitedReader(reader io.Reader, rateLimit int64) *RateLimitedReader {
+	return &RateLimitedReader{reader: reader, bucket: newTokenBucket(rateLimit)}
+}
+
+func (r *RateLimitedReader) Read(p []byte) (int, error) {
+	// Reading at most a burst at a time keeps the pace smooth within each second
+	if limit := r.bucket.readSize(); limit > 0 && len(p) > limit {
+		p = p[:limit]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if wait := r.bucket.take(n); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	return n, err
+}
+
+// stepRatePreset moves to the preset after the current rate limit and announces it
+func stepRatePreset(presets []int64) {
+	liveRate.mutex.Lock()
+	current := liveRate.current
+	liveRate.mutex.Unlock()
+
+	next := presets[0]
+	for i, preset := range presets {
+		if preset == current {
+			next = presets[(i+1)%len(presets)]
+			break
+		}
+	}
+	setLiveRate(next)
+	announceRate(next)
+}
+
+// announceRate prints the new rate limit between progress updates
+func announceRate(limit int64) {
+	stdoutMutex.Lock()
+	fmt.Printf("\nRate limit now %s\n", formatRate(limit))
+	stdoutMutex.Unlock()
+}
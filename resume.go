@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PauseGate blocks transfer reads while downloads are paused
+type PauseGate struct {
+	mutex  sync.Mutex
+	resume chan struct{} // Non-nil while paused, closed on resume
+}
+
+// Pause suspends all gated reads, returning false if already paused
+func (g *PauseGate) Pause() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.resume != nil {
+		return false
+	}
+	g.resume = make(chan struct{})
+	return true
+}
+
+// Resume releases all gated reads, returning false if not paused
+func (g *PauseGate) Resume() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.resume == nil {
+		return false
+	}
+	close(g.resume)
+	g.resume = nil
+	return true
+}
+
+// Toggle pauses running transfers or resumes paused ones, returning the new paused state
+func (g *PauseGate) Toggle() bool {
+	if g.Pause() {
+		return true
+	}
+	g.Resume()
+	return false
+}
+
+// Wait blocks while paused (or until ctx is cancelled) and reports whether it had to wait
+func (g *PauseGate) Wait(ctx context.Context) bool {
+	g.mutex.Lock()
+	ch := g.resume
+	g.mutex.Unlock()
+	if ch == nil {
+		return false
+	}
+	select {
+	case <-ch:
+	case <-ctx.Done():
+	}
+	return true
+}
+
+// resumeState is the checkpoint stored next to a .part file so a later run can continue it
+type resumeState struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Total        int64  `json:"total"`
+	Offset       int64  `json:"offset"`
+}
+
+func resumeStatePath(partPath string) string {
+	return partPath + ".json"
+}
+
+// validator returns the value to send in If-Range so a changed resource restarts from zero
+func (s *resumeState) validator() string {
+	if s.ETag != "" {
+		return s.ETag
+	}
+	return s.LastModified
+}
+
+func saveResumeState(partPath string, state *resumeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(resumeStatePath(partPath), data, 0o644)
+}
+
+// loadResumeState returns the offset to continue partPath from, along with its checkpoint if one exists
+func loadResumeState(partPath, urlStr string) (int64, *resumeState) {
+	info, err := os.Stat(partPath)
+	if err != nil || info.Size() == 0 {
+		return 0, nil
+	}
+
+	var state resumeState
+	data, err := os.ReadFile(resumeStatePath(partPath))
+	if err != nil || json.Unmarshal(data, &state) != nil || state.URL != urlStr {
+		// No usable checkpoint: trust the partial file but without validators
+		return info.Size(), nil
+	}
+	return info.Size(), &state
+}
+
+func removeResumeState(partPath string) {
+	os.Remove(resumeStatePath(partPath))
+}
+
+// requestRange sends a GET for urlStr starting at offset, guarded by validator when set.
+// When timing is non-nil, the phases of the request are recorded into it.
+func (w *WgetClone) requestRange(urlStr string, offset int64, validator string, timing *requestTiming) (*http.Response, error) {
+	ctx := w.withEntryHeaders(w.ctx, urlStr)
+	if timing != nil {
+		ctx = timing.withTrace(ctx)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	req.Header.Set("User-Agent", defaultUserAgent)
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+
+	return w.client.Do(req)
+}
+
+// maxRepairs bounds how often one download reconnects after its connection is cut short
+const maxRepairs = 5
+
+// resumableBody reads a response body through the pause gate. If the connection drops
+// after a pause, or closes before the announced length arrived, and the server supports
+// ranges, it reconnects from the current offset.
+type resumableBody struct {
+	w         *WgetClone
+	url       string
+	validator string
+	resp      *http.Response
+	offset    int64 // Absolute offset of the next byte in the resource
+	total     int64 // Announced size of the resource, or -1
+	ranges    bool  // Whether a Range request can pick the transfer back up
+	paused    bool  // A pause happened since the last (re)connect
+	repairs   int   // Reconnects after a short or failed read that wasn't caused by a pause
+}
+
+func (b *resumableBody) Read(p []byte) (int, error) {
+	if b.w.pause.Wait(b.w.ctx) {
+		b.paused = true
+	}
+	if err := b.w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := b.resp.Body.Read(p)
+	b.offset += int64(n)
+	if err == io.EOF && b.total >= 0 && b.offset < b.total {
+		err = io.ErrUnexpectedEOF // Closed before Content-Length bytes arrived
+	}
+	if err == io.ErrUnexpectedEOF && b.w.ignoreLength {
+		err = io.EOF // A bogus Content-Length; the server closing the body is the end
+	}
+
+	// Reconnecting would hand a transfer out of --max-download-time a fresh budget
+	if err != nil && err != io.EOF && b.ranges && !b.w.IsInterrupted() && (b.paused || b.repairs < maxRepairs) && !errors.Is(err, errTransferDeadline) {
+		reason := "Connection dropped while paused"
+		if !b.paused {
+			reason = "Connection closed early"
+			if errors.Is(err, errTransferStalled) {
+				reason = "Connection stalled"
+			}
+			b.repairs++
+		}
+		b.paused = false
+		// Let go of the old connection first: under --adaptive it may hold the host's only slot
+		b.resp.Body.Close()
+		resp, rerr := b.w.requestRange(b.url, b.offset, b.validator, nil)
+		if rerr == nil && resp.StatusCode == http.StatusPartialContent &&
+			strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", b.offset)) {
+			b.resp = resp
+			fmt.Printf("\n%s, resumed at %s\n", reason, formatBytes(b.offset))
+			return n, nil
+		}
+		if rerr == nil {
+			resp.Body.Close()
+		}
+	}
+
+	return n, err
+}
+
+func (b *resumableBody) Close() error {
+	return b.resp.Body.Close()
+}
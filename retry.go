@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHTTPRetries is the --tries used when --retry-on-http-error is given without one
+const defaultHTTPRetries = 5
+
+// maxRetryWait caps the pause between attempts, including one asked for with Retry-After
+const maxRetryWait = 30 * time.Second
+
+// retryTransport sends a request again after a network error or one of the listed status
+// codes, waiting 1s, 2s, 3s, ... in between (or what the server asks for with Retry-After).
+// Only requests that are safe to repeat are retried.
+type retryTransport struct {
+	base  http.RoundTripper
+	tries int          // Attempts per request, counting the first
+	codes map[int]bool // Status codes worth another attempt
+}
+
+// parseStatusCodes parses a comma-separated list of HTTP status codes such as "500,502,503"
+func parseStatusCodes(list string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, field := range strings.Split(list, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid HTTP status code '%s'", strings.TrimSpace(field))
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+	default:
+		return t.base.RoundTrip(req) // A POST that failed half-way may still have done something
+	}
+
+	for attempt := 1; ; attempt++ {
+		try := req
+		if attempt > 1 {
+			clone, ok := cloneForRetry(req)
+			if !ok || !replayable(req) {
+				return nil, fmt.Errorf("can't retry %s: request body can't be replayed", req.URL)
+			}
+			try = clone
+		}
+
+		resp, err := t.base.RoundTrip(try)
+		if attempt >= t.tries || req.Context().Err() != nil || errors.Is(err, errPinMismatch) || errors.Is(err, errCertExpiring) {
+			return resp, err
+		}
+		// DownloadFile retries these itself; retrying here too would multiply the attempts
+		if errors.Is(err, errTransferDeadline) || errors.Is(err, errTransferStalled) {
+			return resp, err
+		}
+
+		wait := time.Duration(attempt) * time.Second
+		if err != nil {
+			fmt.Printf("\nRetrying %s (attempt %d/%d) after error: %v\n", req.URL, attempt+1, t.tries, err)
+		} else if t.codes[resp.StatusCode] {
+			if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && after >= 0 {
+				wait = time.Duration(after) * time.Second
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Lets the connection be reused
+			resp.Body.Close()
+			fmt.Printf("\nRetrying %s (attempt %d/%d) after HTTP %d\n", req.URL, attempt+1, t.tries, resp.StatusCode)
+		} else {
+			return resp, nil
+		}
+
+		select {
+		case <-time.After(min(wait, maxRetryWait)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// urlRewriteRule replaces matches of pattern in a URL; the replacement may use $1 or ${name}
+type urlRewriteRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// urlRewriter is the list of --rewrite-rule rules, applied in order to every discovered URL
+type urlRewriter []urlRewriteRule
+
+// parseRewriteRules parses "regex=>replacement" rules
+func parseRewriteRules(rules []string) (urlRewriter, error) {
+	var rewriter urlRewriter
+	for _, rule := range rules {
+		expr, replacement, ok := strings.Cut(rule, "=>")
+		if !ok || expr == "" {
+			return nil, fmt.Errorf("invalid rewrite rule %q (want 'regex=>replacement')", rule)
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite rule %q: %w", rule, err)
+		}
+		rewriter = append(rewriter, urlRewriteRule{pattern: pattern, replacement: replacement})
+	}
+	return rewriter, nil
+}
+
+// apply returns urlStr after every rule
+func (r urlRewriter) apply(urlStr string) string {
+	for _, rule := range r {
+		urlStr = rule.pattern.ReplaceAllString(urlStr, rule.replacement)
+	}
+	return urlStr
+}
+
+// applyURL rewrites u, keeping its fragment out of the rules' reach. A rewrite that doesn't
+// parse leaves u as it was.
+func (r urlRewriter) applyURL(u *url.URL) *url.URL {
+	if len(r) == 0 {
+		return u
+	}
+	stripped := *u
+	stripped.Fragment, stripped.RawFragment = "", ""
+	rewritten, err := url.Parse(r.apply(stripped.String()))
+	if err != nil {
+		return u
+	}
+	rewritten.Fragment, rewritten.RawFragment = u.Fragment, u.RawFragment
+	return rewritten
+}
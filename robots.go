@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// robotsAgent is the product token of our User-Agent, as robots rules name it
+const robotsAgent = "go-wget-clone"
+
+// robotsDirectives are the parts of a robots meta tag or X-Robots-Tag header a mirror acts on
+type robotsDirectives struct {
+	noFollow bool // Don't follow the page's links
+	noIndex  bool // Don't save the page
+}
+
+// errNoIndex marks a page skipped because its robots directives say noindex
+var errNoIndex = fmt.Errorf("%w: robots noindex", errFiltered)
+
+// merge combines directives from the header and the page; either may forbid
+func (d robotsDirectives) merge(other robotsDirectives) robotsDirectives {
+	return robotsDirectives{noFollow: d.noFollow || other.noFollow, noIndex: d.noIndex || other.noIndex}
+}
+
+// parseRobotsDirectives reads a comma-separated list such as "noindex, nofollow" or "none"
+func parseRobotsDirectives(value string) robotsDirectives {
+	var d robotsDirectives
+	for _, token := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(token)) {
+		case "nofollow":
+			d.noFollow = true
+		case "noindex":
+			d.noIndex = true
+		case "none":
+			d.noFollow, d.noIndex = true, true
+		}
+	}
+	return d
+}
+
+// robotsFromHeader reads the X-Robots-Tag headers of a response. Values scoped to
+// another crawler ("googlebot: noindex") are ignored.
+func robotsFromHeader(header http.Header) robotsDirectives {
+	var d robotsDirectives
+	for _, value := range header.Values("X-Robots-Tag") {
+		if agent, rest, ok := strings.Cut(value, ":"); ok && !strings.Contains(agent, ",") {
+			agent = strings.ToLower(strings.TrimSpace(agent))
+			if agent != "unavailable_after" {
+				if agent != "*" && agent != "wget" && agent != robotsAgent {
+					continue
+				}
+				value = rest
+			}
+		}
+		d = d.merge(parseRobotsDirectives(value))
+	}
+	return d
+}
+
+// robotsFromHTML reads the <meta name="robots"> tags of a page
+func robotsFromHTML(content string) robotsDirectives {
+	var d robotsDirectives
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return d
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var name, content string
+			for _, attr := range n.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "name":
+					name = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "content":
+					content = attr.Val
+				}
+			}
+			if name == "robots" {
+				d = d.merge(parseRobotsDirectives(content))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return d
+}
+
+// parseCrawlDelay returns the Crawl-delay robots.txt sets for our agent, or else for "*"
+func parseCrawlDelay(robotsTxt io.Reader) time.Duration {
+	var ours, anyone time.Duration
+	var agents []string
+	inRules := false // A rule after User-agent lines ends the group's agent list
+	scanner := bufio.NewScanner(robotsTxt)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if key == "user-agent" {
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+			continue
+		}
+		inRules = true
+		if key != "crawl-delay" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		delay := time.Duration(seconds * float64(time.Second))
+		for _, agent := range agents {
+			switch {
+			case agent == "*":
+				anyone = delay
+			case strings.HasPrefix(robotsAgent, agent):
+				ours = delay
+			}
+		}
+	}
+	if ours > 0 {
+		return ours
+	}
+	return anyone
+}
+
+// crawlDelays spaces out the requests a mirror sends each host by the Crawl-delay of its
+// robots.txt, fetched the first time the host is seen
+type crawlDelays struct {
+	mutex sync.Mutex
+	hosts map[string]*hostPace
+	fetch func(ctx context.Context, robotsURL string) (io.ReadCloser, error)
+}
+
+// hostPace is the Crawl-delay of one host and when its next request may start
+type hostPace struct {
+	once  sync.Once
+	mutex sync.Mutex
+	delay time.Duration
+	next  time.Time
+}
+
+func newCrawlDelays(fetch func(ctx context.Context, robotsURL string) (io.ReadCloser, error)) *crawlDelays {
+	return &crawlDelays{hosts: make(map[string]*hostPace), fetch: fetch}
+}
+
+// wait blocks until a request to u's host may start, or ctx is done
+func (c *crawlDelays) wait(ctx context.Context, u *url.URL) error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	pace, ok := c.hosts[u.Host]
+	if !ok {
+		pace = &hostPace{}
+		c.hosts[u.Host] = pace
+	}
+	c.mutex.Unlock()
+
+	pace.once.Do(func() {
+		robotsURL := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}).String()
+		body, err := c.fetch(ctx, robotsURL)
+		if err != nil {
+			return // No robots.txt, no delay
+		}
+		defer body.Close()
+		if pace.delay = parseCrawlDelay(io.LimitReader(body, 512*1024)); pace.delay > 0 {
+			fmt.Printf("Waiting %s between requests to %s, as its robots.txt asks\n", pace.delay, u.Host)
+		}
+	})
+	if pace.delay <= 0 {
+		return nil
+	}
+
+	pace.mutex.Lock()
+	now := time.Now()
+	start := pace.next
+	if start.Before(now) {
+		start = now
+	}
+	pace.next = start.Add(pace.delay)
+	pace.mutex.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetchRobotsTxt gets a robots.txt for crawlDelays; anything but a 200 counts as none
+func (w *WgetClone) fetchRobotsTxt(ctx context.Context, robotsURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("robots.txt: HTTP %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
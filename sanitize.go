@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNameLength is the longest file name NTFS, SMB and most Unix filesystems accept, in bytes
+const maxNameLength = 255
+
+// windowsLongPath is where Win32 path handling stops unless the \\?\ form is used
+const windowsLongPath = 260
+
+// windowsDevices are the names Windows reserves in every directory, with any extension
+var windowsDevices = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM0": true, "COM1": true, "COM2": true, "COM3": true, "COM4": true,
+	"COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT0": true, "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true,
+	"LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// windowsName escapes what Windows won't store in a name: trailing dots and spaces, which it
+// strips, and reserved device names such as CON or nul.txt, whose first letter is escaped
+func windowsName(name string) string {
+	trimmed := strings.TrimRight(name, ". ")
+	if trimmed != name {
+		var b strings.Builder
+		b.WriteString(trimmed)
+		for _, c := range []byte(name[len(trimmed):]) {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+		name = b.String()
+	}
+
+	device, _, _ := strings.Cut(name, ".")
+	if windowsDevices[strings.ToUpper(strings.TrimRight(device, " "))] {
+		name = fmt.Sprintf("%%%02X", name[0]) + name[1:]
+	}
+	return name
+}
+
+// shortenName cuts a name longer than maxNameLength, keeping its extension and adding a hash
+// of the full name so that long names sharing a prefix stay distinct
+func shortenName(name string) string {
+	if len(name) <= maxNameLength {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > 16 {
+		ext = ""
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := "~" + hex.EncodeToString(sum[:4]) + ext
+
+	prefix := name[:maxNameLength-len(suffix)]
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1] // Don't split a UTF-8 sequence
+	}
+	return prefix + suffix
+}
+
+// longPath returns an absolute form of a path too long for Win32, which the os package then
+// opens through \\?\; other paths and platforms are returned unchanged
+func longPath(p string) string {
+	if runtime.GOOS != "windows" || len(p) < windowsLongPath-12 { // Leave room for 8.3 names
+		return p
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
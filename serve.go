@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const serveUsage = `Usage:
+  ./wget serve [--addr ADDR] DIR    Serve a mirrored site so it can be browsed locally
+
+Options:`
+
+// newServeFlagSet returns the flags of the serve subcommand
+func newServeFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.String("addr", "127.0.0.1:8000", "Address to listen on")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), serveUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// RunServeCommand implements the `serve` subcommand and returns the process exit code
+func RunServeCommand(args []string) int {
+	fs := newServeFlagSet()
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	dir := fs.Arg(0)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("Error: '%s' is not a directory\n", dir)
+		return 1
+	}
+
+	listener, err := net.Listen("tcp", fs.Lookup("addr").Value.String())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	server := &http.Server{Handler: http.FileServer(http.Dir(dir))}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	fmt.Printf("Serving '%s' at http://%s/ (Ctrl+C to stop)\n", dir, listener.Addr())
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
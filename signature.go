@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// maxSignatureSize bounds how much of a detached signature response is read
+const maxSignatureSize = 1 << 20
+
+// signatureCheck verifies downloads against an OpenPGP detached signature
+type signatureCheck struct {
+	keyring   openpgp.EntityList
+	url       string // Signature location; empty means URL.sig, then URL.asc
+	deleteBad bool   // Remove files whose signature doesn't verify
+}
+
+// loadKeyring reads public keys exported with `gpg --export` (binary) or `gpg --export --armor`
+func loadKeyring(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	var keyring openpgp.EntityList
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP")) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse keyring '%s': %w", path, err)
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("keyring '%s' holds no keys", path)
+	}
+	return keyring, nil
+}
+
+// verifySignature checks the file at path, downloaded from urlStr, against its detached signature.
+// On a bad or missing signature the file is removed if deleteBad is set.
+func (w *WgetClone) verifySignature(path, urlStr string) error {
+	s := w.signature
+	candidates := []string{s.url}
+	if s.url == "" {
+		candidates = []string{urlStr + ".sig", urlStr + ".asc"}
+	}
+
+	var signature []byte
+	var sigURL string
+	var err error
+	for _, sigURL = range candidates {
+		if signature, err = w.fetchSignature(sigURL); err == nil {
+			break
+		}
+	}
+	if err == nil {
+		err = checkDetachedSignature(s.keyring, path, signature)
+	}
+	if err != nil {
+		if s.deleteBad {
+			os.Remove(path)
+			fmt.Printf("Removed '%s'\n", path)
+		}
+		return fmt.Errorf("signature verification failed for '%s': %w", path, err)
+	}
+	return nil
+}
+
+// fetchSignature downloads a detached signature
+func (w *WgetClone) fetchSignature(sigURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(w.ctx, "GET", sigURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature URL: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signature: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch signature %s: HTTP %d", sigURL, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+}
+
+// checkDetachedSignature verifies a binary or ASCII-armored detached signature of the file at path
+func checkDetachedSignature(keyring openpgp.EntityList, path string, signature []byte) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var signer *openpgp.Entity
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN PGP SIGNATURE")) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, file, bytes.NewReader(signature))
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keyring, file, bytes.NewReader(signature))
+	}
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("key %X", signer.PrimaryKey.KeyId)
+	for identity, id := range signer.Identities {
+		if id.SelfSignature != nil && id.SelfSignature.IsPrimaryId != nil && *id.SelfSignature.IsPrimaryId {
+			name = identity
+			break
+		}
+		name = identity
+	}
+	fmt.Printf("Good signature from %s\n", name)
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	sitemapName     = "sitemap.xml"
+	sitemapHTMLName = "sitemap.html"
+	sitemapMaxURLs  = 50000 // Per sitemap file, as the protocol allows; more get a sitemap index
+)
+
+// sitemapPage is one saved page listed in the sitemap
+type sitemapPage struct {
+	Loc     string // URL of the page on the republished mirror
+	Link    string // Link to the page from the mirror directory
+	Title   string
+	LastMod string // YYYY-MM-DD the file was saved
+}
+
+// collectSitemapPages lists the HTML pages under dir, sorted by path, with their URLs under base
+func collectSitemapPages(dir string, base *url.URL) ([]sitemapPage, error) {
+	var pages []sitemapPage
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(file))
+		if d.IsDir() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if rel == sitemapHTMLName {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		title := pageTitle(file)
+		if title == "" {
+			title = filepath.ToSlash(rel)
+		}
+		rel = filepath.ToSlash(rel)
+		if path.Base(rel) == "index.html" {
+			rel = strings.TrimSuffix(rel, "index.html") // Static hosts serve dir/ from dir/index.html
+		}
+		link := "./"
+		if rel != "" {
+			link = linkPath(rel)
+		}
+		loc := base.ResolveReference(&url.URL{Path: rel})
+		pages = append(pages, sitemapPage{Loc: loc.String(), Link: link, Title: title, LastMod: info.ModTime().UTC().Format("2006-01-02")})
+		return nil
+	})
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Loc < pages[j].Loc })
+	return pages, err
+}
+
+// pageTitle returns the <title> of the HTML file at file, or "" if it has none
+func pageTitle(file string) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	tokens := html.NewTokenizer(f)
+	for {
+		switch tokens.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			if name, _ := tokens.TagName(); string(name) == "title" {
+				if tokens.Next() == html.TextToken {
+					return strings.Join(strings.Fields(string(tokens.Text())), " ")
+				}
+				return ""
+			}
+		}
+	}
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+var sitemapHTML = template.Must(template.New("sitemap").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Site map</title></head>
+<body>
+<h1>Site map</h1>
+<p>{{len .}} pages</p>
+<ul>
+{{range .}}<li><a href="{{.Link}}">{{.Title}}</a></li>
+{{end}}</ul>
+</body></html>
+`))
+
+// writeSitemap writes sitemap.xml and sitemap.html for the pages under dir, as published at
+// siteURL. Past 50,000 pages sitemap.xml becomes an index of sitemap-1.xml, sitemap-2.xml, ...
+// It returns the number of pages listed.
+func writeSitemap(dir, siteURL string) (int, error) {
+	base, err := url.Parse(siteURL)
+	if err != nil || base.Host == "" {
+		return 0, fmt.Errorf("invalid sitemap URL: %s", siteURL)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	pages, err := collectSitemapPages(dir, base)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(pages) <= sitemapMaxURLs {
+		if err := writeSitemapXML(filepath.Join(dir, sitemapName), sitemapURLs(pages)); err != nil {
+			return 0, err
+		}
+	} else {
+		index := sitemapIndex{XMLNS: sitemapXMLNS}
+		for i := 0; i*sitemapMaxURLs < len(pages); i++ {
+			name := fmt.Sprintf("sitemap-%d.xml", i+1)
+			chunk := pages[i*sitemapMaxURLs : min((i+1)*sitemapMaxURLs, len(pages))]
+			if err := writeSitemapXML(filepath.Join(dir, name), sitemapURLs(chunk)); err != nil {
+				return 0, err
+			}
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: base.ResolveReference(&url.URL{Path: name}).String()})
+		}
+		if err := writeSitemapXML(filepath.Join(dir, sitemapName), index); err != nil {
+			return 0, err
+		}
+	}
+
+	var sb strings.Builder
+	if err := sitemapHTML.Execute(&sb, pages); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, sitemapHTMLName), []byte(sb.String()), 0o644); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", sitemapHTMLName, err)
+	}
+	return len(pages), nil
+}
+
+// sitemapURLs turns pages into a <urlset>
+func sitemapURLs(pages []sitemapPage) sitemapURLSet {
+	set := sitemapURLSet{XMLNS: sitemapXMLNS}
+	for _, page := range pages {
+		set.URLs = append(set.URLs, sitemapURL{Loc: page.Loc, LastMod: page.LastMod})
+	}
+	return set
+}
+
+// writeSitemapXML writes v as an XML document to file
+func writeSitemapXML(file string, v any) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(file), err)
+	}
+	return nil
+}
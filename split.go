@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// splitWriter spreads what is written to it over base.001, base.002, ... of at most size
+// bytes each, hashing every part and the whole
+type splitWriter struct {
+	base  string
+	size  int64
+	parts []splitPart
+	file  *os.File
+	left  int64 // Room left in file
+	part  hash.Hash
+	whole hash.Hash
+}
+
+// splitPart is one finished file of a split download
+type splitPart struct {
+	path string
+	sum  string
+}
+
+func newSplitWriter(base string, size int64) *splitWriter {
+	return &splitWriter{base: base, size: size, whole: sha256.New()}
+}
+
+func (s *splitWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if s.file == nil || s.left == 0 {
+			if err := s.next(); err != nil {
+				return written, err
+			}
+		}
+		chunk := p[:min(int64(len(p)), s.left)]
+		n, err := s.file.Write(chunk)
+		s.part.Write(chunk[:n])
+		s.whole.Write(chunk[:n])
+		written += n
+		s.left -= int64(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// next closes the current part and starts the following one
+func (s *splitWriter) next() error {
+	if err := s.finishPart(); err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s.%03d", s.base, len(s.parts)+1)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%s': %w", path, err)
+	}
+	s.file, s.left, s.part = file, s.size, sha256.New()
+	return nil
+}
+
+// finishPart closes the part being written, if any, and records its hash
+func (s *splitWriter) finishPart() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.parts = append(s.parts, splitPart{path: s.file.Name(), sum: hex.EncodeToString(s.part.Sum(nil))})
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to write file '%s': %w", s.parts[len(s.parts)-1].path, err)
+	}
+	return nil
+}
+
+// remove deletes every part written so far
+func (s *splitWriter) remove() {
+	s.finishPart()
+	for _, part := range s.parts {
+		os.Remove(part.path)
+	}
+}
+
+// writeManifest closes the last part and writes base.parts, which sha256sum -c checks and
+// whose comments say how to put the file back together
+func (s *splitWriter) writeManifest() (string, error) {
+	if s.file == nil && len(s.parts) == 0 {
+		if err := s.next(); err != nil { // An empty download still gets its one (empty) part
+			return "", err
+		}
+	}
+	if err := s.finishPart(); err != nil {
+		return "", err
+	}
+	name := filepath.Base(s.base)
+	var names []string
+	for _, part := range s.parts {
+		names = append(names, filepath.Base(part.path))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s split into %d parts of at most %s\n", name, len(s.parts), formatBytes(s.size))
+	fmt.Fprintf(&sb, "# Reassemble with: cat %s > %s\n", strings.Join(names, " "), name)
+	fmt.Fprintf(&sb, "# SHA-256 of %s: %s\n", name, hex.EncodeToString(s.whole.Sum(nil)))
+	for i, part := range s.parts {
+		fmt.Fprintf(&sb, "%s  %s\n", part.sum, names[i])
+	}
+	manifest := s.base + ".parts"
+	if err := os.WriteFile(manifest, []byte(sb.String()), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write '%s': %w", manifest, err)
+	}
+	return manifest, nil
+}
+
+// DownloadSplit downloads urlStr into numbered files of at most splitSize bytes, for
+// --split-size, plus a manifest listing them. A failed download removes its parts.
+func (w *WgetClone) DownloadSplit(urlStr, outputPath, directory string, rateLimit, splitSize int64) error {
+	fmt.Printf("Starting download at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	finalOutputPath := w.outputPathFor(urlStr, outputPath, directory, false)
+	if err := os.MkdirAll(filepath.Dir(finalOutputPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", filepath.Dir(finalOutputPath), err)
+	}
+
+	parts := newSplitWriter(finalOutputPath, splitSize)
+	written, err := w.appendURL(parts, filepath.Base(finalOutputPath), urlStr, rateLimit)
+	var manifest string
+	if err == nil {
+		manifest, err = parts.writeManifest()
+	}
+	w.stats.fileDone(err)
+	if err != nil {
+		parts.remove()
+		w.urlMap.record(urlStr, "", err)
+		if w.IsInterrupted() {
+			return fmt.Errorf("download interrupted")
+		}
+		return err
+	}
+	for _, part := range parts.parts {
+		w.recordWritten(part.path)
+	}
+	w.urlMap.record(urlStr, manifest, nil)
+
+	fmt.Printf("%s %s\n", colorize(colorGreen, "Downloaded successfully:"), urlStr)
+	fmt.Printf("Saved %d parts of at most %s, listed in %s\n", len(parts.parts), formatBytes(splitSize), manifest)
+	fmt.Printf("Finished at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Total downloaded: %s\n", formatBytes(written))
+	return nil
+}
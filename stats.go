@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errFetchFailed marks a mirrored page that wasn't saved, until it is
+var errFetchFailed = errors.New("fetch failed")
+
+// runStats tallies file outcomes, response codes and speed for --stats and --stats-json.
+// A nil *runStats records nothing.
+type runStats struct {
+	mutex       sync.Mutex
+	status      *StatusTracker
+	start       time.Time
+	succeeded   int
+	failed      int
+	skipped     int
+	statusCodes map[int]int
+	peak        float64 // Fastest bytes per second seen over one sampling interval
+	downloads   []*requestTiming
+	stop        chan struct{}
+}
+
+// statsReport is the end-of-run summary, printed by --stats and written by --stats-json
+type statsReport struct {
+	WallSeconds  float64        `json:"wall_seconds"`
+	Bytes        int64          `json:"bytes"`
+	AverageSpeed float64        `json:"average_bytes_per_second"`
+	PeakSpeed    float64        `json:"peak_bytes_per_second"`
+	Succeeded    int            `json:"succeeded"`
+	Failed       int            `json:"failed"`
+	Skipped      int            `json:"skipped"`
+	StatusCodes  map[string]int `json:"status_codes"`
+
+	Downloads []*requestTiming `json:"downloads,omitempty"` // Phase breakdown of each single-file download
+}
+
+// newRunStats starts collecting statistics, sampling the bytes received by status every second
+func newRunStats(status *StatusTracker) *runStats {
+	s := &runStats{status: status, start: time.Now(), statusCodes: make(map[int]int), stop: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		_, last := status.Totals()
+		lastAt := time.Now()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				_, bytes := status.Totals()
+				bps := float64(bytes-last) / now.Sub(lastAt).Seconds()
+				last, lastAt = bytes, now
+				s.mutex.Lock()
+				s.peak = max(s.peak, bps)
+				s.mutex.Unlock()
+			}
+		}
+	}()
+	return s
+}
+
+// fileDone records the outcome of one file: saved, skipped by a filter, or failed
+func (s *runStats) fileDone(err error) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch {
+	case err == nil:
+		s.succeeded++
+	case errors.Is(err, errFiltered):
+		s.skipped++
+	default:
+		s.failed++
+	}
+}
+
+// response records the status code of one HTTP response
+func (s *runStats) response(code int) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.statusCodes[code]++
+	s.mutex.Unlock()
+}
+
+// timed records the phase breakdown of one finished download
+func (s *runStats) timed(t *requestTiming) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.downloads = append(s.downloads, t)
+	s.mutex.Unlock()
+}
+
+// Report stops sampling and returns the totals
+func (s *runStats) Report() statsReport {
+	close(s.stop)
+	_, bytes := s.status.Totals()
+	wall := time.Since(s.start)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	r := statsReport{
+		WallSeconds:  wall.Seconds(),
+		Bytes:        bytes,
+		AverageSpeed: float64(bytes) / wall.Seconds(),
+		PeakSpeed:    s.peak,
+		Succeeded:    s.succeeded,
+		Failed:       s.failed,
+		Skipped:      s.skipped,
+		StatusCodes:  make(map[string]int, len(s.statusCodes)),
+		Downloads:    s.downloads,
+	}
+	r.PeakSpeed = max(r.PeakSpeed, r.AverageSpeed) // Runs shorter than one sample
+	for code, n := range s.statusCodes {
+		r.StatusCodes[strconv.Itoa(code)] = n
+	}
+	return r
+}
+
+// Print writes the report in human-readable form
+func (r statsReport) Print() {
+	codes := make([]string, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	histogram := make([]string, len(codes))
+	for i, code := range codes {
+		histogram[i] = fmt.Sprintf("%s: %d", code, r.StatusCodes[code])
+	}
+	if len(histogram) == 0 {
+		histogram = []string{"none"}
+	}
+
+	fmt.Println("=== Run statistics ===")
+	fmt.Printf("Wall time:    %s\n", time.Duration(r.WallSeconds*float64(time.Second)).Round(time.Millisecond))
+	fmt.Printf("Downloaded:   %s (average %s, peak %s)\n", formatBytes(r.Bytes), formatSpeed(r.AverageSpeed), formatSpeed(r.PeakSpeed))
+	fmt.Printf("Files:        %d succeeded, %d failed, %d skipped\n", r.Succeeded, r.Failed, r.Skipped)
+	fmt.Printf("HTTP status:  %s\n", strings.Join(histogram, ", "))
+}
+
+// WriteJSON writes the report as JSON to path, or to stdout for "-"
+func (r statsReport) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write statistics: %w", err)
+	}
+	return nil
+}
+
+// statsTransport records the status code of every response for the run statistics
+type statsTransport struct {
+	base  http.RoundTripper
+	stats *runStats
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.stats.response(resp.StatusCode)
+	}
+	return resp, err
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// transferStatus tracks the progress of a single active transfer
+type transferStatus struct {
+	url       string
+	total     int64
+	written   atomic.Int64
+	startTime time.Time
+}
+
+// StatusTracker records active transfers and totals for on-demand status reports
+type StatusTracker struct {
+	mutex     sync.Mutex
+	active    map[*transferStatus]struct{}
+	completed int
+	bytesDone int64        // Bytes received by transfers that already finished
+	pending   atomic.Int64 // Scheduled tasks (batch entries, mirror pages) not yet finished
+	startTime time.Time
+}
+
+func NewStatusTracker() *StatusTracker {
+	return &StatusTracker{
+		active:    make(map[*transferStatus]struct{}),
+		startTime: time.Now(),
+	}
+}
+
+// statusReader counts bytes read into its transfer
+type statusReader struct {
+	reader   io.Reader
+	transfer *transferStatus
+}
+
+func (r *statusReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.transfer.written.Add(int64(n))
+	return n, err
+}
+
+// Track registers a transfer and returns a reader counting its bytes, plus a function to call when it ends
+func (s *StatusTracker) Track(urlStr string, total int64, reader io.Reader) (io.Reader, func()) {
+	t := &transferStatus{url: urlStr, total: total, startTime: time.Now()}
+
+	s.mutex.Lock()
+	s.active[t] = struct{}{}
+	s.mutex.Unlock()
+
+	done := func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if _, ok := s.active[t]; !ok {
+			return
+		}
+		delete(s.active, t)
+		s.completed++
+		s.bytesDone += t.written.Load()
+	}
+
+	return &statusReader{reader: reader, transfer: t}, done
+}
+
+// AddPending adjusts the number of scheduled tasks that have not finished yet
+func (s *StatusTracker) AddPending(delta int64) {
+	s.pending.Add(delta)
+}
+
+// Totals returns the number of finished transfers and the bytes received so far
+func (s *StatusTracker) Totals() (int, int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	bytes := s.bytesDone
+	for t := range s.active {
+		bytes += t.written.Load()
+	}
+	return s.completed, bytes
+}
+
+// WriteSnapshot prints the current state of all transfers
+func (s *StatusTracker) WriteSnapshot(out io.Writer) {
+	s.mutex.Lock()
+	transfers := make([]*transferStatus, 0, len(s.active))
+	for t := range s.active {
+		transfers = append(transfers, t)
+	}
+	completed := s.completed
+	bytesDone := s.bytesDone
+	s.mutex.Unlock()
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].startTime.Before(transfers[j].startTime)
+	})
+
+	totalBytes := bytesDone
+	for _, t := range transfers {
+		totalBytes += t.written.Load()
+	}
+	elapsed := time.Since(s.startTime)
+
+	fmt.Fprintf(out, "=== Status at %s ===\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(out, "Elapsed: %s, downloaded: %s (%.2fKB/s), completed: %d, pending: %d\n",
+		elapsed.Round(time.Second),
+		formatBytes(totalBytes),
+		float64(totalBytes)/elapsed.Seconds()/1024,
+		completed,
+		s.pending.Load())
+	fmt.Fprintf(out, "Active transfers: %d\n", len(transfers))
+
+	for _, t := range transfers {
+		written := t.written.Load()
+		speed := float64(written) / time.Since(t.startTime).Seconds()
+		if t.total > 0 {
+			fmt.Fprintf(out, "  %s %s/%s (%.0f%%) %.2fKB/s\n",
+				t.url,
+				formatBytes(written),
+				formatBytes(t.total),
+				float64(written)/float64(t.total)*100,
+				speed/1024)
+		} else {
+			fmt.Fprintf(out, "  %s %s %.2fKB/s\n", t.url, formatBytes(written), speed/1024)
+		}
+	}
+}
+
+// printStatus dumps a status snapshot to stdout without interleaving with progress output
+func (w *WgetClone) printStatus() {
+	stdoutMutex.Lock()
+	defer stdoutMutex.Unlock()
+
+	fmt.Println()
+	w.status.WriteSnapshot(os.Stdout)
+}
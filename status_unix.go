@@ -0,0 +1,62 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// SetupStatusReporting prints a status snapshot whenever SIGUSR1 is received
+func (w *WgetClone) SetupStatusReporting() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+
+	go func() {
+		for range c {
+			w.printStatus()
+		}
+	}()
+}
+
+// ServeStatusFifo creates a named pipe that yields a status snapshot to every reader (e.g. `cat fifo`).
+// An existing named pipe is reused, anything else at fifoPath is refused. The returned func removes
+// the pipe again if this run created it.
+func (w *WgetClone) ServeStatusFifo(fifoPath string) (func(), error) {
+	remove := func() { os.Remove(fifoPath) }
+	if err := syscall.Mkfifo(fifoPath, 0o600); err != nil {
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create status fifo '%s': %w", fifoPath, err)
+		}
+		// Don't write snapshots into a regular file, or through a symlink planted in its place
+		info, statErr := os.Lstat(fifoPath)
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to create status fifo '%s': %w", fifoPath, statErr)
+		}
+		if info.Mode().Type() != fs.ModeNamedPipe {
+			return nil, fmt.Errorf("status fifo '%s' exists and is not a named pipe", fifoPath)
+		}
+		remove = func() {}
+	}
+
+	go func() {
+		for {
+			// Opening for writing blocks until a reader shows up
+			fifo, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
+			if err != nil {
+				return
+			}
+			w.status.WriteSnapshot(fifo)
+			fifo.Close()
+
+			// Give the reader time to see EOF before serving the next one
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+	return remove, nil
+}
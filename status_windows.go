@@ -0,0 +1,13 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+// SetupStatusReporting is a no-op on Windows, which has no SIGUSR1
+func (w *WgetClone) SetupStatusReporting() {}
+
+// ServeStatusFifo is unsupported on Windows, which has no named pipes in the filesystem
+func (w *WgetClone) ServeStatusFifo(fifoPath string) (func(), error) {
+	return nil, fmt.Errorf("--status-fifo is not supported on Windows")
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const syncWorkers = 4 // Uploads running at once; the crawl waits when they fall behind
+
+// syncTarget uploads one file of a mirror to remote storage as name, its slash-separated path
+// relative to the mirror directory
+type syncTarget interface {
+	upload(ctx context.Context, file, name string) error
+}
+
+// parseSyncTarget parses a --sync-to destination: s3://bucket/prefix, sftp://[user@]host[:port]/path,
+// rsync://host/module/path for an rsync daemon, or [user@]host:path for rsync over SSH
+func (w *WgetClone) parseSyncTarget(dest string) (syncTarget, error) {
+	scheme, rest, ok := strings.Cut(dest, "://")
+	if !ok {
+		host, _, isRemote := strings.Cut(dest, ":")
+		if !isRemote || len(host) < 2 || strings.ContainsAny(host, `/\`) { // C:\dir is a local path
+			return nil, fmt.Errorf("invalid --sync-to '%s' (want s3://bucket/prefix, sftp://host/path, rsync://host/module or host:path)", dest)
+		}
+		return &rsyncTarget{dest: strings.TrimSuffix(dest, "/") + "/"}, nil
+	}
+	switch strings.ToLower(scheme) {
+	case "s3":
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("s3 --sync-to has no bucket: %s", dest)
+		}
+		return &s3Target{client: w.serviceClient, bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+	case "rsync":
+		return &rsyncTarget{dest: strings.TrimSuffix(dest, "/") + "/"}, nil
+	case "sftp":
+		u, err := url.Parse(dest)
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid sftp --sync-to: %s", dest)
+		}
+		return &sftpTarget{host: u.Hostname(), port: u.Port(), user: u.User.Username(), dir: strings.TrimSuffix(u.Path, "/")}, nil
+	}
+	return nil, fmt.Errorf("unsupported --sync-to scheme '%s'", scheme)
+}
+
+// s3Target PUTs files to s3://bucket/prefix/NAME through the service client, signed by objectStoreTransport
+type s3Target struct {
+	client *http.Client
+	bucket string
+	prefix string
+}
+
+func (t *s3Target) upload(ctx context.Context, file, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	key := path.Join(t.prefix, name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, (&url.URL{Scheme: "s3", Host: t.bucket, Path: "/" + key}).String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size() // S3 refuses chunked uploads
+	req.GetBody = func() (io.ReadCloser, error) { return os.Open(file) }
+	if info.Size() == 0 {
+		req.Body = http.NoBody
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PUT s3://%s/%s: %s", t.bucket, key, resp.Status)
+	}
+	return nil
+}
+
+// rsyncTarget copies files with rsync, to a daemon (rsync://) or over SSH (host:path). -R recreates
+// the file's directories under dest.
+type rsyncTarget struct {
+	dest string // Ends in /
+}
+
+func (t *rsyncTarget) upload(ctx context.Context, file, name string) error {
+	root := strings.TrimSuffix(filepath.ToSlash(file), name)
+	cmd := exec.CommandContext(ctx, "rsync", "-R", "--times", "--", "./"+name, t.dest)
+	cmd.Dir = filepath.FromSlash(root)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("rsync %s: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// sftpTarget puts files with the sftp client in batch mode, so SSH keys and ~/.ssh/config apply
+type sftpTarget struct {
+	host, port, user string
+	dir              string // Remote directory; "" is the login directory
+}
+
+func (t *sftpTarget) upload(ctx context.Context, file, name string) error {
+	remote := name
+	if t.dir != "" {
+		remote = t.dir + "/" + name
+	}
+	var dirs []string
+	for dir := path.Dir(remote); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	// "-" ignores the failure of mkdir on a directory that's already there
+	var batch strings.Builder
+	for _, dir := range dirs {
+		fmt.Fprintf(&batch, "-mkdir %s\n", sftpQuote(dir))
+	}
+	fmt.Fprintf(&batch, "put -p %s %s\n", sftpQuote(file), sftpQuote(remote))
+
+	args := []string{"-q", "-b", "-"}
+	if t.port != "" {
+		args = append(args, "-P", t.port)
+	}
+	login := t.host
+	if t.user != "" {
+		login = t.user + "@" + t.host
+	}
+	cmd := exec.CommandContext(ctx, "sftp", append(args, login)...)
+	cmd.Stdin = strings.NewReader(batch.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sftp %s: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// sftpQuote quotes a path for an sftp batch file
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// syncedFile is what was uploaded of a file
+type syncedFile struct {
+	modTime time.Time
+	size    int64
+	sha256  string // Kept for files removed after upload, which the manifest can no longer hash
+}
+
+// mirrorSync uploads the files of a mirror to a --sync-to target as they are saved, and with
+// --sync-delete removes them afterwards so the mirror never has to fit on local disk. A nil
+// *mirrorSync uploads nothing.
+type mirrorSync struct {
+	target      syncTarget
+	dest        string
+	deleteLocal bool
+	ctx         context.Context
+	root        string // The mirror directory; set before the first file is queued
+
+	queue   chan string
+	pending sync.WaitGroup // Files queued and not yet uploaded
+	workers sync.WaitGroup
+
+	mutex  sync.Mutex
+	synced map[string]syncedFile
+	kept   map[string]bool // Uploaded by finish, and not removed
+	bytes  int64
+	failed map[string]bool // Files whose last upload failed
+}
+
+func newMirrorSync(ctx context.Context, target syncTarget, dest string, deleteLocal bool) *mirrorSync {
+	s := &mirrorSync{target: target, dest: dest, deleteLocal: deleteLocal, ctx: ctx,
+		queue: make(chan string, 2*syncWorkers), synced: make(map[string]syncedFile), kept: make(map[string]bool), failed: make(map[string]bool)}
+	for i := 0; i < syncWorkers; i++ {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			for file := range s.queue {
+				s.upload(file)
+				s.pending.Done()
+			}
+		}()
+	}
+	return s
+}
+
+// add queues a saved file for upload, waiting while the queue is full
+func (s *mirrorSync) add(file string) {
+	if s == nil {
+		return
+	}
+	s.pending.Add(1)
+	s.queue <- filepath.Clean(file)
+}
+
+// upload sends one file to the target, then removes it with --sync-delete
+func (s *mirrorSync) upload(file string) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return // Replaced or removed since it was queued
+	}
+	rel, err := filepath.Rel(s.root, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return
+	}
+	sum := ""
+	if s.deleteLocal {
+		if sum, err = fileSHA256(file); err != nil {
+			return
+		}
+	}
+	if err := s.target.upload(s.ctx, file, filepath.ToSlash(rel)); err != nil {
+		fmt.Printf("Failed to sync '%s' to %s: %v\n", file, s.dest, err)
+		s.mutex.Lock()
+		s.failed[file] = true
+		s.mutex.Unlock()
+		return
+	}
+	s.mutex.Lock()
+	s.synced[file] = syncedFile{modTime: info.ModTime(), size: info.Size(), sha256: sum}
+	s.bytes += info.Size()
+	delete(s.failed, file)
+	keep := s.kept[file]
+	s.mutex.Unlock()
+	if s.deleteLocal && !keep {
+		os.Remove(file)
+	}
+}
+
+// removed returns what was uploaded of file if --sync-delete took it off the local disk
+func (s *mirrorSync) removed(file string) (syncedFile, bool) {
+	if s == nil || !s.deleteLocal {
+		return syncedFile{}, false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	synced, ok := s.synced[filepath.Clean(file)]
+	return synced, ok
+}
+
+// eachRemoved calls fn with every file --sync-delete uploaded and then removed
+func (s *mirrorSync) eachRemoved(fn func(file string, synced syncedFile)) {
+	if s == nil || !s.deleteLocal {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for file, synced := range s.synced {
+		if !fileExists(file) {
+			fn(file, synced)
+		}
+	}
+}
+
+// finish uploads what changed in the mirror directory after the crawl (pages relinked to
+// redirects, the checksum manifest, sitemaps), waits for the uploads and reports them. Files
+// found this way are kept locally, as the next run reads the manifest back.
+func (s *mirrorSync) finish() error {
+	if s == nil {
+		return nil
+	}
+	s.pending.Wait() // Until then files of the crawl would look changed
+	err := filepath.WalkDir(s.root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".wget-") || strings.HasSuffix(d.Name(), ".part") {
+			return nil // Lock, crawl state and snapshot belong to this machine's runs
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		synced, ok := s.synced[file]
+		changed := !ok || info.ModTime().After(synced.modTime) || info.Size() != synced.size
+		if changed {
+			s.kept[file] = true
+		}
+		s.mutex.Unlock()
+		if changed {
+			s.add(file)
+		}
+		return nil
+	})
+	close(s.queue)
+	s.workers.Wait()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fmt.Printf("Synced %d files (%s) to %s\n", len(s.synced), formatBytes(s.bytes), s.dest)
+	if len(s.failed) > 0 {
+		return fmt.Errorf("%d files failed to sync to %s and were kept locally", len(s.failed), s.dest)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpFastOpen enables TCP Fast Open on outgoing connections, so repeat connections to a
+// server carry the request in the SYN instead of waiting a round trip for the handshake
+var tcpFastOpen = func(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
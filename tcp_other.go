@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+import "syscall"
+
+// tcpFastOpen is nil where client-side TCP Fast Open can't be enabled on a plain dial
+var tcpFastOpen func(network, address string, c syscall.RawConn) error
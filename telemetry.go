@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// otlpInterval is how often spans and metrics are pushed while a long run is in progress
+const otlpInterval = 10 * time.Second
+
+// crawlDepthKey carries a mirrored page's link depth in its request context
+type crawlDepthKey struct{}
+
+// telemetry exports one OTLP span per HTTP request, under a root span for the whole run,
+// plus request, byte and file counters, to an OpenTelemetry collector using OTLP/HTTP JSON
+type telemetry struct {
+	endpoint string // Collector base URL; /v1/traces and /v1/metrics are appended
+	service  string
+	status   *StatusTracker
+	client   *http.Client
+	traceID  string
+	rootID   string
+	start    time.Time
+
+	mutex    sync.Mutex
+	pending  []otlpSpan    // Finished spans not yet exported
+	requests map[int]int64 // Responses by status code; 0 counts transport errors
+	received int64         // Response body bytes read
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"` // 1 internal, 3 client
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       struct {
+		Code    int    `json:"code,omitempty"` // 2 is error
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+// newTelemetry starts a run trace exported to endpoint, pushing what it has every otlpInterval
+func newTelemetry(endpoint, service string, status *StatusTracker) *telemetry {
+	t := &telemetry{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		service:  service,
+		status:   status,
+		client:   &http.Client{Timeout: notifyTimeout},
+		traceID:  randomHex(16),
+		rootID:   randomHex(8),
+		start:    time.Now(),
+		requests: make(map[int]int64),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(otlpInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				if err := t.export(); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+			}
+		}
+	}()
+	return t
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func stringAttr(key, value string) otlpAttribute {
+	return otlpAttribute{key, map[string]any{"stringValue": value}}
+}
+
+func intAttr(key string, value int64) otlpAttribute {
+	return otlpAttribute{key, map[string]any{"intValue": strconv.FormatInt(value, 10)}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// Shutdown ends the run span with the run's outcome and pushes everything still pending
+func (t *telemetry) Shutdown(command string, err error) error {
+	close(t.stop)
+	<-t.done
+
+	root := otlpSpan{TraceID: t.traceID, SpanID: t.rootID, Name: "wget " + command, Kind: 1,
+		Start: unixNano(t.start), End: unixNano(time.Now())}
+	files, bytes := t.status.Totals()
+	root.Attributes = []otlpAttribute{stringAttr("wget.command", command), intAttr("wget.files", int64(files)), intAttr("wget.bytes", bytes)}
+	if err != nil {
+		root.Status.Code, root.Status.Message = 2, err.Error()
+	}
+	t.mutex.Lock()
+	t.pending = append(t.pending, root)
+	t.mutex.Unlock()
+	return t.export()
+}
+
+// export pushes the finished spans and the current (cumulative) counters to the collector
+func (t *telemetry) export() error {
+	t.mutex.Lock()
+	spans := t.pending
+	t.pending = nil
+	now := unixNano(time.Now())
+	point := func(value int64, attrs ...otlpAttribute) map[string]any {
+		return map[string]any{"attributes": attrs, "startTimeUnixNano": unixNano(t.start), "timeUnixNano": now, "asInt": strconv.FormatInt(value, 10)}
+	}
+	var requestPoints []map[string]any
+	for code, n := range t.requests {
+		requestPoints = append(requestPoints, point(n, intAttr("http.response.status_code", int64(code))))
+	}
+	bytesPoint := point(t.received)
+	t.mutex.Unlock()
+	files, _ := t.status.Totals()
+
+	sum := func(name, unit string, points ...map[string]any) map[string]any {
+		return map[string]any{"name": name, "unit": unit,
+			"sum": map[string]any{"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": points}}
+	}
+	resource := map[string]any{"attributes": []otlpAttribute{stringAttr("service.name", t.service)}}
+	scope := map[string]any{"name": "wget"}
+
+	if len(spans) > 0 {
+		err := t.post("/v1/traces", map[string]any{"resourceSpans": []any{map[string]any{
+			"resource": resource, "scopeSpans": []any{map[string]any{"scope": scope, "spans": spans}}}}})
+		if err != nil {
+			return err
+		}
+	}
+	metrics := []any{sum("wget.http.requests", "{request}", requestPoints...), sum("wget.received", "By", bytesPoint),
+		sum("wget.files", "{file}", point(int64(files)))}
+	return t.post("/v1/metrics", map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource": resource, "scopeMetrics": []any{map[string]any{"scope": scope, "metrics": metrics}}}}})
+}
+
+func (t *telemetry) post(path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.endpoint+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("OTLP export failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP export to %s failed: HTTP %d", path, resp.StatusCode)
+	}
+	return nil
+}
+
+// telemetryTransport records a client span for every request, lasting until its body is closed,
+// and passes the trace on to the server in a W3C traceparent header
+type telemetryTransport struct {
+	base      http.RoundTripper
+	telemetry *telemetry
+}
+
+func (tt *telemetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := tt.telemetry
+	span := otlpSpan{TraceID: t.traceID, SpanID: randomHex(8), ParentSpanID: t.rootID, Name: req.Method, Kind: 3,
+		Start: unixNano(time.Now())}
+	span.Attributes = []otlpAttribute{
+		stringAttr("http.request.method", req.Method),
+		stringAttr("url.full", req.URL.Redacted()),
+		stringAttr("server.address", req.URL.Hostname()),
+	}
+	if depth, ok := req.Context().Value(crawlDepthKey{}).(int); ok {
+		span.Attributes = append(span.Attributes, intAttr("wget.crawl.depth", int64(depth)))
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("traceparent", "00-"+t.traceID+"-"+span.SpanID+"-01")
+	resp, err := tt.base.RoundTrip(req)
+	if err != nil {
+		span.Status.Code, span.Status.Message = 2, err.Error()
+		t.finish(span, 0, 0)
+		return resp, err
+	}
+	span.Attributes = append(span.Attributes, intAttr("http.response.status_code", int64(resp.StatusCode)))
+	if resp.StatusCode >= 400 {
+		span.Status.Code = 2
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, telemetry: t, span: span, code: resp.StatusCode}
+	return resp, nil
+}
+
+// finish counts a request and queues its span for export
+func (t *telemetry) finish(span otlpSpan, code int, received int64) {
+	span.End = unixNano(time.Now())
+	if code != 0 {
+		span.Attributes = append(span.Attributes, intAttr("http.response.body.size", received))
+	}
+	t.mutex.Lock()
+	t.pending = append(t.pending, span)
+	t.requests[code]++
+	t.received += received
+	t.mutex.Unlock()
+}
+
+// spanBody ends its request's span when the response body is closed
+type spanBody struct {
+	io.ReadCloser
+	telemetry *telemetry
+	span      otlpSpan
+	code      int
+	received  int64
+	once      sync.Once
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.received += int64(n)
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	b.once.Do(func() { b.telemetry.finish(b.span, b.code, b.received) })
+	return b.ReadCloser.Close()
+}
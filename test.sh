@@ -11,7 +11,7 @@ echo "=== Simple Go Wget Tests ==="
 
 # Build first
 echo "Building..."
-go build -o wget main.go
+go build -o wget .
 
 # Test 1: Basic download
 echo ""
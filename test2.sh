@@ -1,7 +1,7 @@
 #!/bin/bash
 
 # Test script for Go wget clone
-# Make sure to build the binary first: go build -o wget main.go
+# Make sure to build the binary first: go build -o wget .
 
 echo "=== Go Wget Clone Test Suite ==="
 echo ""
@@ -55,7 +55,7 @@ mkdir -p test_downloads
 mkdir -p test_mirror
 
 echo "Building wget binary..."
-if ! go build -o wget main.go; then
+if ! go build -o wget .; then
     echo -e "${RED}Failed to build binary${NC}"
     exit 1
 fi
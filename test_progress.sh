@@ -5,7 +5,7 @@ echo ""
 
 # Build the wget tool
 echo "Building wget..."
-go build -o wget main.go
+go build -o wget .
 
 echo ""
 echo "Choose a test file size:"
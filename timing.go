@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// requestTiming breaks the time of one download into phases, so slowness can be pinned on
+// name resolution, the network, the server or the local disk
+type requestTiming struct {
+	url      string
+	reused   bool          // The request went over an already open connection
+	dns      time.Duration // Resolving the host name
+	connect  time.Duration // TCP connect
+	tls      time.Duration // TLS handshake
+	wait     time.Duration // From the request being sent to the first response byte
+	transfer time.Duration // From the first response byte to the end of the body
+	disk     time.Duration // Spent writing to the output file, part of transfer
+
+	dnsStart, connectStart, tlsStart, wroteRequest, firstByte time.Time
+}
+
+// withTrace returns ctx with an httptrace hook filling in t
+func (t *requestTiming) withTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn:      func(info httptrace.GotConnInfo) { t.reused = info.Reused },
+		DNSStart:     func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone:      func(httptrace.DNSDoneInfo) { t.dns = time.Since(t.dnsStart) },
+		ConnectStart: func(string, string) { t.connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			t.connect = time.Since(t.connectStart)
+		},
+		TLSHandshakeStart: func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.tls = time.Since(t.tlsStart)
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { t.firstByte = time.Now(); t.wait = t.firstByte.Sub(t.wroteRequest) },
+	})
+}
+
+// finish records the end of the body transfer
+func (t *requestTiming) finish() {
+	if !t.firstByte.IsZero() {
+		t.transfer = time.Since(t.firstByte)
+	}
+}
+
+func (t *requestTiming) String() string {
+	phase := func(d time.Duration) string {
+		if d < 10*time.Millisecond {
+			return d.Round(10 * time.Microsecond).String() // Keep local and LAN phases readable
+		}
+		return d.Round(time.Millisecond).String()
+	}
+	var parts []string
+	if t.reused {
+		parts = append(parts, "reused connection")
+	} else {
+		parts = append(parts, "dns "+phase(t.dns), "connect "+phase(t.connect))
+		if t.tls > 0 {
+			parts = append(parts, "tls "+phase(t.tls))
+		}
+	}
+	parts = append(parts, "server "+phase(t.wait), fmt.Sprintf("transfer %s (disk %s)", phase(t.transfer), phase(t.disk)))
+	return "Timing: " + strings.Join(parts, ", ")
+}
+
+func (t *requestTiming) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return json.Marshal(struct {
+		URL      string  `json:"url"`
+		Reused   bool    `json:"reused_connection"`
+		DNS      float64 `json:"dns_ms"`
+		Connect  float64 `json:"connect_ms"`
+		TLS      float64 `json:"tls_ms"`
+		Wait     float64 `json:"ttfb_ms"`
+		Transfer float64 `json:"transfer_ms"`
+		Disk     float64 `json:"disk_ms"`
+	}{t.url, t.reused, ms(t.dns), ms(t.connect), ms(t.tls), ms(t.wait), ms(t.transfer), ms(t.disk)})
+}
+
+// diskTimer adds the time spent in writes to the output file to a timing
+type diskTimer struct {
+	writer io.Writer
+	timing *requestTiming
+}
+
+func (d *diskTimer) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := d.writer.Write(p)
+	d.timing.disk += time.Since(start)
+	return n, err
+}
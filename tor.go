@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// defaultTorProxy is where the Tor daemon takes SOCKS connections unless told otherwise
+const defaultTorProxy = "127.0.0.1:9050"
+
+// isOnion reports whether host (with or without a port) is a Tor onion service
+func isOnion(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.HasSuffix(strings.ToLower(strings.TrimSuffix(host, ".")), ".onion")
+}
+
+// routeOnion sends connections to .onion hosts through the Tor SOCKS proxy at torAddr, which
+// resolves the name itself, and keeps them away from any --proxy. When Tor can't be reached
+// the request fails rather than going out over the clearnet.
+func routeOnion(transport *http.Transport, torAddr string) error {
+	if _, _, err := net.SplitHostPort(torAddr); err != nil {
+		return fmt.Errorf("invalid --tor-proxy '%s' (want host:port)", torAddr)
+	}
+	tor, err := proxy.SOCKS5("tcp", torAddr, nil, &net.Dialer{Timeout: 30 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	dial := transport.DialContext // Set up by tuneTransport
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !isOnion(addr) {
+			return dial(ctx, network, addr)
+		}
+		conn, err := tor.(proxy.ContextDialer).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, fmt.Errorf("%s is only reachable over Tor, and the Tor proxy at %s failed: %w", addr, torAddr, err)
+		}
+		return conn, nil
+	}
+
+	if pick := transport.Proxy; pick != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if isOnion(req.URL.Host) {
+				return nil, nil
+			}
+			return pick(req)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// tuneTransport applies the connection pooling and TCP flags to the base transport, and dials
+// dual-stack hosts with Happy Eyeballs
+func tuneTransport(transport *http.Transport, opts *cliOptions) (*happyDialer, error) {
+	transport.DisableKeepAlives = opts.noKeepAlive
+	if opts.idlePerHost < 0 {
+		return nil, fmt.Errorf("--max-idle-conns-per-host must not be negative")
+	}
+	if opts.idlePerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.idlePerHost
+		// The overall pool must not be what caps a single busy host
+		transport.MaxIdleConns = max(transport.MaxIdleConns, opts.idlePerHost)
+	}
+	if opts.connsPerHost < 0 {
+		return nil, fmt.Errorf("--max-connections-per-host must not be negative")
+	}
+	transport.MaxConnsPerHost = opts.connsPerHost // Requests past it wait for a connection to free up
+
+	// Same defaults as http.DefaultTransport's dialer
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if opts.tcpKeepAlive != "" {
+		interval, err := time.ParseDuration(opts.tcpKeepAlive)
+		if err != nil || interval < 0 {
+			return nil, fmt.Errorf("invalid --tcp-keepalive: %s", opts.tcpKeepAlive)
+		}
+		dialer.KeepAlive = interval
+		if interval == 0 {
+			dialer.KeepAlive = -1 // 0 would mean the system default, the flag means off
+		}
+	}
+	if opts.tcpFastOpen {
+		if tcpFastOpen == nil {
+			return nil, fmt.Errorf("--tcp-fastopen is only supported on Linux")
+		}
+		dialer.Control = tcpFastOpen
+	}
+	delay, err := time.ParseDuration(opts.eyeballDelay)
+	if err != nil || delay < 10*time.Millisecond {
+		return nil, fmt.Errorf("invalid --happy-eyeballs-delay: %s (at least 10ms)", opts.eyeballDelay)
+	}
+	eyeballs := &happyDialer{dialer: dialer, attemptDelay: delay}
+	transport.DialContext = eyeballs.DialContext
+	return eyeballs, nil
+}
+
+// dialUnixSocket sends every connection to the Unix domain socket at socket, for --unix-socket.
+// The URL still supplies the Host header and path; proxies are left out, as nothing would reach them.
+func dialUnixSocket(transport *http.Transport, socket string) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, "unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s through socket %s: %w", addr, socket, err)
+		}
+		return conn, nil
+	}
+}
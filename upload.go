@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Upload sends the local file at filePath to urlStr with method (PUT or POST), streaming it
+// through the same progress display and rate limiting as downloads. A URL ending in "/"
+// gets the file's name appended, like curl -T does.
+func (w *WgetClone) Upload(filePath, urlStr, method string, rateLimit int64) error {
+	method = strings.ToUpper(method)
+	if method != http.MethodPut && method != http.MethodPost {
+		return fmt.Errorf("unsupported upload method '%s' (want PUT or POST)", method)
+	}
+	if strings.HasSuffix(urlStr, "/") {
+		urlStr += url.PathEscape(filepath.Base(filePath))
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", filePath, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read '%s': %w", filePath, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("'%s' is a directory", filePath)
+	}
+
+	fmt.Printf("Starting upload at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	reader, done := w.status.Track(urlStr, info.Size(), file)
+	defer done()
+	reader = NewRateLimitedReader(reader, rateLimit)
+	progress := NewProgressWriter(io.Discard, info.Size(), filepath.Base(filePath), false)
+
+	req, err := http.NewRequestWithContext(w.ctx, method, urlStr, io.NopCloser(io.TeeReader(reader, progress)))
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	req.ContentLength = info.Size()
+	// A second attempt, e.g. after an authentication challenge, re-reads the file without progress
+	req.GetBody = func() (io.ReadCloser, error) { return os.Open(filePath) }
+	req.Header.Set("User-Agent", defaultUserAgent)
+	contentType := mime.TypeByExtension(filepath.Ext(filePath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := w.client.Do(req)
+	progress.Finish()
+	if err != nil {
+		if w.IsInterrupted() {
+			return fmt.Errorf("upload interrupted")
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	fmt.Printf("Response received: %d %s\n", resp.StatusCode, resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+	fmt.Printf("%s %s\n", colorize(colorGreen, "Uploaded successfully:"), urlStr)
+	fmt.Printf("Finished at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Total uploaded: %s\n", formatBytes(info.Size()))
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxExpandedURLs caps how many URLs a single pattern may expand into
+const maxExpandedURLs = 100000
+
+var (
+	numericRangePattern = regexp.MustCompile(`^(\d+)-(\d+)(?::(\d+))?$`)
+	letterRangePattern  = regexp.MustCompile(`^([a-zA-Z])-([a-zA-Z])(?::(\d+))?$`)
+)
+
+// hasURLPattern reports whether urlStr contains a [from-to] range or {a,b} alternation
+func hasURLPattern(urlStr string) bool {
+	parts, err := parseURLPattern(urlStr)
+	return err == nil && len(parts) > 1
+}
+
+// parseURLPattern splits urlStr into literal text and the alternative values of each pattern.
+// Brackets that are not a valid range (such as IPv6 hosts) are kept literally.
+func parseURLPattern(urlStr string) ([][]string, error) {
+	var parts [][]string
+	literal := ""
+
+	for i := 0; i < len(urlStr); i++ {
+		c := urlStr[i]
+		if c != '[' && c != '{' {
+			literal += string(c)
+			continue
+		}
+
+		closing := byte(']')
+		if c == '{' {
+			closing = '}'
+		}
+		end := strings.IndexByte(urlStr[i+1:], closing)
+		if end < 0 {
+			literal += string(c)
+			continue
+		}
+		body := urlStr[i+1 : i+1+end]
+
+		var values []string
+		var err error
+		if c == '[' {
+			values, err = expandRange(body)
+		} else if strings.Contains(body, ",") {
+			values = strings.Split(body, ",")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			literal += string(c)
+			continue
+		}
+
+		parts = append(parts, []string{literal}, values)
+		literal = ""
+		i += end + 1
+	}
+	return append(parts, []string{literal}), nil
+}
+
+// expandRange expands the body of a [..] pattern: 1-10, 001-100, a-z, with an optional :step.
+// It returns nil for bodies that are not ranges.
+func expandRange(body string) ([]string, error) {
+	if m := numericRangePattern.FindStringSubmatch(body); m != nil {
+		from, _ := strconv.Atoi(m[1])
+		to, _ := strconv.Atoi(m[2])
+		step, err := rangeStep(m[3])
+		if err != nil {
+			return nil, err
+		}
+		if from > to {
+			return nil, fmt.Errorf("invalid range [%s]: start is after end", body)
+		}
+		if (to-from)/step >= maxExpandedURLs {
+			return nil, fmt.Errorf("range [%s] expands to too many URLs", body)
+		}
+		// A leading zero on the start value sets a fixed width, as in curl
+		width := 0
+		if len(m[1]) > 1 && m[1][0] == '0' {
+			width = len(m[1])
+		}
+		var values []string
+		for n := from; n <= to; n += step {
+			values = append(values, fmt.Sprintf("%0*d", width, n))
+		}
+		return values, nil
+	}
+
+	if m := letterRangePattern.FindStringSubmatch(body); m != nil {
+		from, to := m[1][0], m[2][0]
+		step, err := rangeStep(m[3])
+		if err != nil {
+			return nil, err
+		}
+		if from > to {
+			return nil, fmt.Errorf("invalid range [%s]: start is after end", body)
+		}
+		var values []string
+		for ch := int(from); ch <= int(to); ch += step {
+			values = append(values, string(rune(ch)))
+		}
+		return values, nil
+	}
+
+	return nil, nil
+}
+
+// rangeStep parses the optional :step suffix of a range
+func rangeStep(s string) (int, error) {
+	if s == "" {
+		return 1, nil
+	}
+	step, err := strconv.Atoi(s)
+	if err != nil || step < 1 {
+		return 0, fmt.Errorf("invalid range step %q", s)
+	}
+	return step, nil
+}
+
+// ExpandURLPattern expands curl-style [001-100] ranges and {a,b,c} alternations into every URL they describe
+func ExpandURLPattern(urlStr string) ([]string, error) {
+	parts, err := parseURLPattern(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 1
+	for _, values := range parts {
+		total *= len(values)
+		if total > maxExpandedURLs {
+			return nil, fmt.Errorf("pattern %s expands to more than %d URLs", urlStr, maxExpandedURLs)
+		}
+	}
+
+	urls := []string{""}
+	for _, values := range parts {
+		next := make([]string, 0, len(urls)*len(values))
+		for _, prefix := range urls {
+			for _, v := range values {
+				next = append(next, prefix+v)
+			}
+		}
+		urls = next
+	}
+	return urls, nil
+}
+
+// expandURLPatterns expands every pattern in urls, keeping plain URLs as they are
+func expandURLPatterns(urls []string) ([]string, error) {
+	var expanded []string
+	for _, urlStr := range urls {
+		if !hasURLPattern(urlStr) {
+			expanded = append(expanded, urlStr)
+			continue
+		}
+		list, err := ExpandURLPattern(urlStr)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, list...)
+	}
+	return expanded, nil
+}
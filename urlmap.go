@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// urlMapEntry is what --url-map reports about one URL
+type urlMapEntry struct {
+	URL        string `json:"url"`
+	Path       string `json:"path,omitempty"`        // Local file, slash-separated; empty when nothing was kept
+	Status     string `json:"status"`                // saved, skipped or failed
+	HTTPStatus int    `json:"http_status,omitempty"` // Of the final response, after redirects
+	SHA256     string `json:"sha256,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// urlMap collects the outcome of every URL of a run for --url-map. A nil *urlMap records nothing.
+type urlMap struct {
+	mutex   sync.Mutex
+	entries map[string]*urlMapEntry
+	codes   map[string]int // Final status code of each URL requested
+}
+
+func newURLMap() *urlMap {
+	return &urlMap{entries: make(map[string]*urlMapEntry), codes: make(map[string]int)}
+}
+
+// record notes the outcome of urlStr: the file it was saved to, if any, and the error that
+// stopped it. A later record of the same URL replaces an earlier one.
+func (m *urlMap) record(urlStr, path string, err error) {
+	if m == nil {
+		return
+	}
+	entry := &urlMapEntry{URL: urlStr, Status: "saved"}
+	if path != "" {
+		entry.Path = filepath.ToSlash(path)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, errFiltered):
+		entry.Status, entry.Error = "skipped", err.Error()
+	default:
+		entry.Status, entry.Error = "failed", err.Error()
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry.HTTPStatus = m.codes[urlStr]
+	m.entries[urlStr] = entry
+}
+
+// saved returns the file each saved URL went to
+func (m *urlMap) saved() map[string]string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	paths := make(map[string]string)
+	for urlStr, entry := range m.entries {
+		if entry.Status == "saved" && entry.Path != "" {
+			paths[urlStr] = entry.Path
+		}
+	}
+	return paths
+}
+
+// gone reports whether the server said urlStr no longer exists (404 or 410), rather than failing
+// to answer for it or not being asked this run
+func (m *urlMap) gone(urlStr string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.entries[urlStr]
+	return ok && (entry.HTTPStatus == http.StatusNotFound || entry.HTTPStatus == http.StatusGone)
+}
+
+// response notes the status code of resp for req's URL and every URL that redirected to it
+func (m *urlMap) response(req *http.Request, resp *http.Response) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for req != nil {
+		m.codes[req.URL.String()] = resp.StatusCode
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+}
+
+// write saves the map to file, as CSV if its name ends in .csv and as JSON otherwise.
+// Hashes are taken of the files as they are now.
+func (m *urlMap) write(file string) error {
+	m.mutex.Lock()
+	entries := make([]urlMapEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, *entry)
+	}
+	m.mutex.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	for i := range entries {
+		if entries[i].Path == "" {
+			continue
+		}
+		if sum, err := fileSHA256(filepath.FromSlash(entries[i].Path)); err == nil {
+			entries[i].SHA256 = sum
+		}
+	}
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		var sb strings.Builder
+		out := csv.NewWriter(&sb)
+		out.Write([]string{"url", "path", "status", "http_status", "sha256", "error"})
+		for _, e := range entries {
+			code := ""
+			if e.HTTPStatus != 0 {
+				code = strconv.Itoa(e.HTTPStatus)
+			}
+			out.Write([]string{e.URL, e.Path, e.Status, code, e.SHA256, e.Error})
+		}
+		out.Flush()
+		data = []byte(sb.String())
+	} else {
+		var err error
+		if data, err = json.MarshalIndent(entries, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write URL map: %w", err)
+	}
+	fmt.Printf("Wrote URL map %s (%d URLs)\n", file, len(entries))
+	return nil
+}
+
+// urlMapTransport records the final status code of every URL for --url-map
+type urlMapTransport struct {
+	base http.RoundTripper
+	urls *urlMap
+}
+
+func (t *urlMapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.urls.response(req, resp)
+	}
+	return resp, err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultUserAgent is sent unless --user-agent or --user-agent-file says otherwise
+	defaultUserAgent = "Go-Wget-Clone/1.0"
+	// browserUserAgent is sent for --user-agent browser: a current desktop Chrome
+	browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36"
+)
+
+// userAgents hands out the User-Agent of each request from --user-agent or --user-agent-file,
+// in turn for every request or, with perHost, one per host
+type userAgents struct {
+	mutex   sync.Mutex
+	agents  []string
+	next    int
+	perHost bool
+	hosts   map[string]string
+}
+
+// loadUserAgents reads one User-Agent per line of file, skipping blank lines and # comments.
+// "browser" stands for browserUserAgent, in the file as for --user-agent.
+func loadUserAgents(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user agents: %w", err)
+	}
+	defer f.Close()
+	var agents []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		agents = append(agents, expandUserAgent(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read user agents: %w", err)
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("no user agents in %s", file)
+	}
+	return agents, nil
+}
+
+// expandUserAgent turns the keyword "browser" into a realistic browser User-Agent
+func expandUserAgent(agent string) string {
+	if strings.EqualFold(agent, "browser") {
+		return browserUserAgent
+	}
+	return agent
+}
+
+// newUserAgents builds the rotation from the --user-agent, --user-agent-file and
+// --rotate-user-agent flags, or returns nil when neither source flag is given
+func newUserAgents(agent, file, rotate string) (*userAgents, error) {
+	if rotate != "request" && rotate != "host" {
+		return nil, fmt.Errorf("invalid --rotate-user-agent '%s' (want request or host)", rotate)
+	}
+	var agents []string
+	if agent != "" {
+		agents = append(agents, expandUserAgent(agent))
+	}
+	if file != "" {
+		loaded, err := loadUserAgents(file)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, loaded...)
+	}
+	if len(agents) == 0 {
+		return nil, nil
+	}
+	return &userAgents{agents: agents, perHost: rotate == "host", hosts: make(map[string]string)}, nil
+}
+
+// pick returns the User-Agent for a request to host
+func (u *userAgents) pick(host string) string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if agent, ok := u.hosts[host]; ok && u.perHost {
+		return agent
+	}
+	agent := u.agents[u.next%len(u.agents)]
+	u.next++
+	if u.perHost {
+		u.hosts[host] = agent
+	}
+	return agent
+}
+
+// userAgentTransport sets the User-Agent of every request from a userAgents rotation
+type userAgentTransport struct {
+	base   http.RoundTripper
+	agents *userAgents
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.agents.pick(req.URL.Host))
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DanglingLink is a local reference in a mirrored file whose target does not exist
+type DanglingLink struct {
+	File   string // File containing the reference
+	Link   string // Reference as written in the file
+	Target string // Local path the reference resolves to
+}
+
+var (
+	cssURLPattern    = regexp.MustCompile(`url\(\s*['"]?([^'")]+?)['"]?\s*\)`)
+	cssImportPattern = regexp.MustCompile(`@import\s+['"]([^'"]+)['"]`)
+)
+
+// extractCSSRefs returns the url(...) and @import references found in CSS content
+func extractCSSRefs(css string) []string {
+	var refs []string
+	for _, m := range cssURLPattern.FindAllStringSubmatch(css, -1) {
+		refs = append(refs, strings.TrimSpace(m[1]))
+	}
+	for _, m := range cssImportPattern.FindAllStringSubmatch(css, -1) {
+		refs = append(refs, strings.TrimSpace(m[1]))
+	}
+	return refs
+}
+
+// extractHTMLRefs returns the references the mirror rewrites, plus those in inline CSS
+func extractHTMLRefs(content string) ([]string, error) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var refs []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			var attrName string
+			switch n.Data {
+			case "a", "link":
+				attrName = "href"
+			case "img", "script":
+				attrName = "src"
+			}
+			for _, a := range n.Attr {
+				if a.Key == attrName {
+					refs = append(refs, a.Val)
+				}
+				if a.Key == "style" {
+					refs = append(refs, extractCSSRefs(a.Val)...)
+				}
+			}
+			if n.Data == "style" && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				refs = append(refs, extractCSSRefs(n.FirstChild.Data)...)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+
+	walk(doc)
+	return refs, nil
+}
+
+// localRefTarget resolves a reference found in file to a path inside the mirror rooted at root,
+// which links starting with prefix (--convert-links=absolute-prefix) point into. It returns
+// false for references that do not point at a local file (remote URLs, anchors, data: URIs).
+func localRefTarget(root, file, ref, prefix string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || parsed.Path == "" {
+		return "", false
+	}
+
+	refPath := parsed.Path
+	if strings.HasSuffix(refPath, "/") {
+		refPath += "index.html"
+	}
+
+	if prefix != "" && strings.HasPrefix(refPath, prefix) {
+		return filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(refPath, prefix))), true
+	}
+	// rewriteHTML falls back to root-relative links when no relative path can be computed
+	if strings.HasPrefix(refPath, "/") {
+		return filepath.Join(root, filepath.FromSlash(refPath)), true
+	}
+	return filepath.Join(filepath.Dir(file), filepath.FromSlash(refPath)), true
+}
+
+// VerifyMirror walks the saved HTML/CSS files under root and reports local references to missing files
+func VerifyMirror(root, prefix string) ([]DanglingLink, error) {
+	var dangling []DanglingLink
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".html" && ext != ".htm" && ext != ".css" {
+			return nil
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", p, err)
+		}
+
+		var refs []string
+		if ext == ".css" {
+			refs = extractCSSRefs(string(content))
+		} else {
+			refs, err = extractHTMLRefs(string(content))
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+		}
+
+		for _, ref := range refs {
+			target, ok := localRefTarget(root, p, ref, prefix)
+			if !ok {
+				continue
+			}
+			if _, err := os.Stat(target); err != nil {
+				dangling = append(dangling, DanglingLink{File: p, Link: ref, Target: target})
+			}
+		}
+		return nil
+	})
+
+	return dangling, err
+}
+
+// printMirrorVerification runs VerifyMirror over root, prints the result and returns the number of dangling links
+func printMirrorVerification(root, prefix string) (int, error) {
+	fmt.Printf("\nVerifying local links in '%s'...\n", root)
+
+	dangling, err := VerifyMirror(root, prefix)
+	if err != nil {
+		return 0, fmt.Errorf("link verification failed: %w", err)
+	}
+
+	for _, d := range dangling {
+		fmt.Printf("Dangling link in %s: %s -> %s\n", d.File, d.Link, d.Target)
+	}
+	fmt.Printf("Verification completed: %d dangling links found.\n", len(dangling))
+	return len(dangling), nil
+}
+
+// verifyMirrorLinks verifies the current mirror directory after a --verify-links run
+func (w *WgetClone) verifyMirrorLinks() error {
+	_, err := printMirrorVerification(w.mirrorBaseDir, w.layout.prefix())
+	return err
+}
+
+// IntegrityReport lists how a mirror differs from its SHA256SUMS manifest.
+// Paths are slash-separated and relative to the mirror directory.
+type IntegrityReport struct {
+	Corrupted  []string // Listed, but the contents changed
+	Missing    []string // Listed, but gone
+	Extraneous []string // Present, but not listed
+	Checked    int      // Files listed in the manifest
+}
+
+// AuditMirror re-hashes the files the manifest under root lists and looks for files it
+// doesn't. The manifest itself and wget's .wget-* bookkeeping files don't count.
+func AuditMirror(root string) (*IntegrityReport, error) {
+	sums, err := readManifest(filepath.Join(root, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	report := &IntegrityReport{Checked: len(sums)}
+	for name, sum := range sums {
+		actual, err := fileSHA256(filepath.Join(root, filepath.FromSlash(name)))
+		switch {
+		case os.IsNotExist(err):
+			report.Missing = append(report.Missing, name)
+		case err != nil:
+			return nil, err
+		case actual != sum:
+			report.Corrupted = append(report.Corrupted, name)
+		}
+	}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".wget-") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); rel != manifestName && sums[rel] == "" {
+			report.Extraneous = append(report.Extraneous, rel)
+		}
+		return nil
+	})
+	sort.Strings(report.Corrupted)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extraneous)
+	return report, err
+}
+
+// problems returns the number of files that differ from the manifest
+func (r *IntegrityReport) problems() int {
+	return len(r.Corrupted) + len(r.Missing) + len(r.Extraneous)
+}
+
+// printMirrorAudit runs AuditMirror over root, prints the result and returns the number of
+// files that differ from the manifest
+func printMirrorAudit(root string) (int, error) {
+	if !fileExists(filepath.Join(root, manifestName)) {
+		fmt.Printf("No %s in '%s' to check files against (older mirrors have none); checking links only.\n", manifestName, root)
+		return 0, nil
+	}
+	fmt.Printf("Checking '%s' against %s...\n", root, manifestName)
+	report, err := AuditMirror(root)
+	if err != nil {
+		return 0, fmt.Errorf("integrity check failed: %w", err)
+	}
+	for _, name := range report.Corrupted {
+		fmt.Printf("Corrupted: %s\n", name)
+	}
+	for _, name := range report.Missing {
+		fmt.Printf("Missing: %s\n", name)
+	}
+	for _, name := range report.Extraneous {
+		fmt.Printf("Extraneous: %s\n", name)
+	}
+	fmt.Printf("Integrity check completed: %d files checked, %d corrupted, %d missing, %d extraneous.\n",
+		report.Checked, len(report.Corrupted), len(report.Missing), len(report.Extraneous))
+	return report.problems(), nil
+}
+
+// RunVerifyCommand implements the `verify` subcommand and returns the process exit code
+func RunVerifyCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("Usage:\n  ./wget verify DIR    Check a mirrored site against its manifest and for dangling local links")
+		return 1
+	}
+	if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+		fmt.Printf("Error: '%s' is not a directory\n", args[0])
+		return 1
+	}
+
+	changed, err := printMirrorAudit(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	// Links converted with --convert-links=absolute-prefix resolve under the recorded prefix
+	prefix, err := loadLinkPrefix(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	dangling, err := printMirrorVerification(args[0], prefix)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if changed > 0 || dangling > 0 {
+		return 1
+	}
+	return 0
+}
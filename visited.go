@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// defaultBloomCapacity is the number of URLs --visited-bloom is sized for unless told otherwise
+const defaultBloomCapacity = 10_000_000
+
+// urlSet remembers the URLs a mirror has queued. Callers serialize access.
+type urlSet interface {
+	add(urlStr string) bool // Reports whether urlStr wasn't in the set yet
+}
+
+// exactSet stores every URL, so nothing is ever skipped by mistake
+type exactSet map[string]bool
+
+func (s exactSet) add(urlStr string) bool {
+	if s[urlStr] {
+		return false
+	}
+	s[urlStr] = true
+	return true
+}
+
+// bloomSet is a Bloom filter: a fixed bit array, however many URLs go in. A URL seen for the
+// first time is taken for a known one at about the chosen rate, and is then not fetched.
+// Its hashes are fixed, so a saved filter still answers for a later run.
+type bloomSet struct {
+	bits   []uint64
+	size   uint64 // Number of bits
+	hashes int
+}
+
+// newBloomSet sizes a filter for capacity URLs at falsePositive rate
+func newBloomSet(capacity int, falsePositive float64) (*bloomSet, error) {
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, fmt.Errorf("false-positive rate must be between 0 and 1, got %g", falsePositive)
+	}
+	if capacity < 1 {
+		return nil, fmt.Errorf("capacity must be positive, got %d", capacity)
+	}
+	bits := math.Ceil(-float64(capacity) * math.Log(falsePositive) / (math.Ln2 * math.Ln2))
+	size := uint64(max(bits, 64))
+	return &bloomSet{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: max(1, int(math.Round(float64(size)/float64(capacity)*math.Ln2))),
+	}, nil
+}
+
+// bytes is the memory the filter's bit array takes
+func (b *bloomSet) bytes() int64 {
+	return int64(len(b.bits)) * 8
+}
+
+func (b *bloomSet) add(urlStr string) bool {
+	// Double hashing: bit i is h1 + i*h2, which is as good as k independent hashes
+	hash := fnv.New64a()
+	hash.Write([]byte(urlStr))
+	h1 := hash.Sum64()
+	hash.Write([]byte{0}) // Extending the input gives a second, unrelated hash
+	h2 := hash.Sum64() | 1
+	added := false
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % b.size
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if b.bits[word]&mask == 0 {
+			b.bits[word] |= mask
+			added = true
+		}
+	}
+	return added
+}
+
+// bloomSnapshot is a bloomSet as saved in a crawl state file
+type bloomSnapshot struct {
+	Size   uint64 `json:"size"`
+	Hashes int    `json:"hashes"`
+	Bits   []byte `json:"bits"`
+}
+
+func (b *bloomSet) snapshot() *bloomSnapshot {
+	bits := make([]byte, len(b.bits)*8)
+	for i, word := range b.bits {
+		binary.LittleEndian.PutUint64(bits[i*8:], word)
+	}
+	return &bloomSnapshot{Size: b.size, Hashes: b.hashes, Bits: bits}
+}
+
+// restore loads a saved filter, which must have been sized the same way
+func (b *bloomSet) restore(saved *bloomSnapshot) error {
+	if saved.Size != b.size || saved.Hashes != b.hashes || len(saved.Bits) != len(b.bits)*8 {
+		return fmt.Errorf("saved Bloom filter was sized for a different --visited-bloom or --visited-bloom-capacity")
+	}
+	for i := range b.bits {
+		b.bits[i] = binary.LittleEndian.Uint64(saved.Bits[i*8:])
+	}
+	return nil
+}
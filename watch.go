@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchState carries validators and the content hash between polls
+type watchState struct {
+	etag         string
+	lastModified string
+	hash         []byte
+}
+
+// timestampedPath inserts a timestamp before the extension, e.g. feed.xml -> feed-20060102T150405.xml
+func timestampedPath(outputPath string, t time.Time) string {
+	ext := filepath.Ext(outputPath)
+	return strings.TrimSuffix(outputPath, ext) + "-" + t.Format("20060102T150405") + ext
+}
+
+// WatchURL polls urlStr every interval with conditional requests and saves a copy whenever it changes
+func (w *WgetClone) WatchURL(urlStr, outputPath, directory string, rateLimit int64, interval time.Duration, timestamped bool) error {
+	finalOutputPath := w.outputPathFor(urlStr, outputPath, directory, false)
+	if dir := filepath.Dir(finalOutputPath); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create directory '%s': %w", dir, err)
+		}
+	}
+
+	fmt.Printf("Watching %s every %s\n", urlStr, interval)
+
+	var state watchState
+	for {
+		if err := w.pollOnce(urlStr, finalOutputPath, rateLimit, timestamped, &state); err != nil {
+			if w.IsInterrupted() {
+				return nil
+			}
+			// Keep watching through transient failures
+			fmt.Printf("[%s] Error: %v\n", time.Now().Format("2006-01-02 15:04:05"), err)
+		}
+
+		if !w.sleepUntil(time.Now().Add(interval)) {
+			return nil
+		}
+	}
+}
+
+// pollOnce performs a single conditional fetch and saves the body if it differs from the last copy
+func (w *WgetClone) pollOnce(urlStr, outputPath string, rateLimit int64, timestamped bool, state *watchState) error {
+	req, err := http.NewRequestWithContext(w.ctx, "GET", urlStr, nil)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	if state.etag != "" {
+		req.Header.Set("If-None-Match", state.etag)
+	}
+	if state.lastModified != "" {
+		req.Header.Set("If-Modified-Since", state.lastModified)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	if resp.StatusCode == http.StatusNotModified {
+		fmt.Printf("[%s] Not modified\n", now)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	var reader io.Reader = resp.Body
+	reader = NewRateLimitedReader(reader, rateLimit)
+
+	// Hash while downloading so servers without validators still skip identical copies
+	partPath := outputPath + ".part"
+	file, err := os.Create(partPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%s': %w", partPath, err)
+	}
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(file, hasher), reader)
+	closeErr := file.Close()
+	if err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if closeErr != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("failed to write file '%s': %w", partPath, closeErr)
+	}
+
+	state.etag = resp.Header.Get("ETag")
+	state.lastModified = resp.Header.Get("Last-Modified")
+
+	hash := hasher.Sum(nil)
+	if bytes.Equal(hash, state.hash) {
+		os.Remove(partPath)
+		fmt.Printf("[%s] Content unchanged\n", now)
+		return nil
+	}
+
+	target := outputPath
+	if timestamped {
+		target = timestampedPath(outputPath, time.Now())
+	}
+	if err := os.Rename(partPath, target); err != nil {
+		return fmt.Errorf("failed to move '%s' into place: %w", partPath, err)
+	}
+
+	if state.hash == nil {
+		fmt.Printf("[%s] Saved %s (%s)\n", now, target, formatBytes(written))
+	} else {
+		fmt.Printf("[%s] Changed, saved %s (%s)\n", now, target, formatBytes(written))
+	}
+	state.hash = hash
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// propfindBody asks only for the properties needed to walk the tree
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/></D:prop></D:propfind>`
+
+// davMultistatus is the subset of a 207 Multi-Status response we read
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// davEntry is one member of a WebDAV collection
+type davEntry struct {
+	url          *url.URL
+	isCollection bool
+}
+
+// propfind lists collectionURL (Depth: 1), or describes only itself with depth "0"
+func (w *WgetClone) propfind(collectionURL *url.URL, depth string) ([]davEntry, error) {
+	req, err := http.NewRequestWithContext(w.ctx, "PROPFIND", collectionURL.String(), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("PROPFIND returned HTTP %d", resp.StatusCode)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("invalid PROPFIND response: %w", err)
+	}
+
+	var entries []davEntry
+	for _, r := range ms.Responses {
+		ref, err := url.Parse(strings.TrimSpace(r.Href))
+		if err != nil {
+			continue
+		}
+		entry := davEntry{url: collectionURL.ResolveReference(ref)}
+		for _, ps := range r.Propstat {
+			// Only the propstat carrying the 200 status holds real values
+			if strings.Contains(ps.Status, " 200") && ps.Prop.ResourceType.Collection != nil {
+				entry.isCollection = true
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// isWebDAVCollection reports whether urlStr is a WebDAV collection, probing with a Depth: 0 PROPFIND
+func (w *WgetClone) isWebDAVCollection(urlStr string) bool {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return false
+	}
+	entries, err := w.propfind(parsedURL, "0")
+	return err == nil && len(entries) == 1 && entries[0].isCollection
+}
+
+// davLocalPath maps a DAV resource to its file under the mirror directory, keeping names as-is
+func (w *WgetClone) davLocalPath(u *url.URL) string {
+	return longPath(filepath.Join(w.mirrorBaseDir, w.layout.place(strings.TrimPrefix(filePath(u), "/"))))
+}
+
+// MirrorWebDAV downloads the file tree of a WebDAV collection by walking it with PROPFIND
+func (w *WgetClone) MirrorWebDAV(urlStr string, reject, exclude []string, maxDepth int, sem chan struct{}) (int, error) {
+	baseURL, err := url.Parse(urlStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid base URL for mirroring: %w", err)
+	}
+	if !strings.HasSuffix(baseURL.Path, "/") {
+		baseURL.Path += "/"
+	}
+	fmt.Printf("WebDAV share detected, listing collections with PROPFIND\n")
+
+	var wg sync.WaitGroup
+	var countMutex sync.Mutex
+	files := 0
+
+	// Collections are walked breadth-first; files are downloaded concurrently as they are found
+	type level struct {
+		url   *url.URL
+		depth int
+	}
+	queue := []level{{baseURL, 0}}
+	seen := map[string]bool{baseURL.Path: true}
+
+	for len(queue) > 0 && !w.IsInterrupted() {
+		current := queue[0]
+		queue = queue[1:]
+
+		entries, err := w.propfind(current.url, "1")
+		if err != nil {
+			fmt.Printf("\nFailed to list %s: %v\n", current.url, err)
+			continue
+		}
+
+		for _, entry := range entries {
+			// Stay inside the share and skip the collection's own entry
+			if entry.url.Host != baseURL.Host || !strings.HasPrefix(entry.url.Path, baseURL.Path) {
+				continue
+			}
+			key := strings.TrimSuffix(entry.url.Path, "/")
+			if seen[key] || seen[key+"/"] {
+				continue
+			}
+			seen[key] = true
+
+			if entry.isCollection {
+				if current.depth+1 < maxDepth {
+					if !strings.HasSuffix(entry.url.Path, "/") {
+						entry.url.Path += "/"
+					}
+					queue = append(queue, level{entry.url, current.depth + 1})
+				}
+				continue
+			}
+
+			fileURL := entry.url.String()
+			if shouldReject(fileURL, reject, exclude) || w.excludeRE.match(fileURL) {
+				continue
+			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			w.status.AddPending(1)
+			go func(fileURL, localPath string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer w.status.AddPending(-1)
+				if err := w.DownloadFile(fileURL, localPath, "", 0, true); errors.Is(err, errFiltered) {
+					fmt.Printf("\nSkipping %s: %v\n", fileURL, err)
+					return
+				} else if err != nil {
+					fmt.Printf("\n%s %s: %v\n", colorize(colorRed, "Error downloading"), fileURL, err)
+					return
+				}
+				countMutex.Lock()
+				files++
+				countMutex.Unlock()
+			}(fileURL, w.davLocalPath(entry.url))
+		}
+	}
+
+	wg.Wait()
+	return files, nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/md4"
+)
+
+// fileExists reports whether a regular file exists at filename
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// zsyncControl is a parsed .zsync control file
+type zsyncControl struct {
+	blockSize     int
+	length        int64
+	url           string // Where blocks are fetched from, resolved against the control file URL
+	sha1          string
+	rsumBytes     int
+	checksumBytes int
+	rsums         []uint32 // Truncated rolling checksum per block (low rsumBytes bytes)
+	checksums     [][]byte // Truncated MD4 per block
+}
+
+// parseZsync reads a zsync 0.6 control file: "Key: value" headers, a blank line, then per-block checksums
+func parseZsync(data []byte, controlURL *url.URL) (*zsyncControl, error) {
+	reader := bufio.NewReader(bytes.NewReader(data))
+	c := &zsyncControl{rsumBytes: 4, checksumBytes: 16}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("truncated zsync header")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Blocksize":
+			c.blockSize, err = strconv.Atoi(value)
+		case "Length":
+			c.length, err = strconv.ParseInt(value, 10, 64)
+		case "URL":
+			if ref, perr := url.Parse(value); perr == nil {
+				c.url = controlURL.ResolveReference(ref).String()
+			}
+		case "SHA-1":
+			c.sha1 = strings.ToLower(value)
+		case "Hash-Lengths":
+			// seq_matches,rsum_bytes,checksum_bytes
+			parts := strings.Split(value, ",")
+			if len(parts) == 3 {
+				c.rsumBytes, err = strconv.Atoi(parts[1])
+				if err == nil {
+					c.checksumBytes, err = strconv.Atoi(parts[2])
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid zsync header %q: %w", line, err)
+		}
+	}
+
+	if c.blockSize <= 0 || c.length < 0 || c.rsumBytes < 1 || c.rsumBytes > 4 || c.checksumBytes < 1 || c.checksumBytes > 16 {
+		return nil, fmt.Errorf("unsupported zsync parameters")
+	}
+
+	blocks := int((c.length + int64(c.blockSize) - 1) / int64(c.blockSize))
+	entry := make([]byte, c.rsumBytes+c.checksumBytes)
+	for i := 0; i < blocks; i++ {
+		if _, err := io.ReadFull(reader, entry); err != nil {
+			return nil, fmt.Errorf("truncated zsync block list")
+		}
+		// The rsum is stored big-endian with its high bytes dropped
+		var full [4]byte
+		copy(full[4-c.rsumBytes:], entry[:c.rsumBytes])
+		c.rsums = append(c.rsums, binary.BigEndian.Uint32(full[:]))
+		c.checksums = append(c.checksums, append([]byte(nil), entry[c.rsumBytes:]...))
+	}
+
+	return c, nil
+}
+
+// rsumMask keeps the bytes of a rolling checksum that the control file stores
+func (c *zsyncControl) rsumMask() uint32 {
+	if c.rsumBytes == 4 {
+		return 0xffffffff
+	}
+	return 1<<(8*uint(c.rsumBytes)) - 1
+}
+
+// blockRsum computes zsync's rolling checksum: a is the byte sum, b the running sum of a
+func blockRsum(block []byte) (uint16, uint16) {
+	var a, b uint16
+	for _, c := range block {
+		a += uint16(c)
+		b += a
+	}
+	return a, b
+}
+
+// matchLocalBlocks scans an old local copy with a rolling checksum and maps target block index -> local offset
+func (c *zsyncControl) matchLocalBlocks(localPath string) (map[int]int64, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mask := c.rsumMask()
+	candidates := make(map[uint32][]int)
+	for i, r := range c.rsums {
+		candidates[r&mask] = append(candidates[r&mask], i)
+	}
+
+	bs := c.blockSize
+	reader := bufio.NewReaderSize(file, 1<<20)
+	ring := make([]byte, bs)
+	block := make([]byte, bs)
+	matches := make(map[int]int64)
+
+	// fill loads a fresh window at the current offset; false at EOF
+	fill := func() bool {
+		_, err := io.ReadFull(reader, ring)
+		return err == nil
+	}
+
+	var offset int64
+	head := 0
+	if !fill() {
+		return matches, nil
+	}
+	a, b := blockRsum(ring)
+
+	for {
+		if ids, ok := candidates[(uint32(a)<<16|uint32(b))&mask]; ok {
+			copy(block, ring[head:])
+			copy(block[bs-head:], ring[:head])
+			sum := md4.New()
+			sum.Write(block)
+			digest := sum.Sum(nil)[:c.checksumBytes]
+
+			matched := false
+			for _, id := range ids {
+				if _, seen := matches[id]; !seen && bytes.Equal(digest, c.checksums[id]) {
+					matches[id] = offset
+					matched = true
+				}
+			}
+			if matched {
+				// Jump over the matched block
+				offset += int64(bs)
+				head = 0
+				if !fill() {
+					return matches, nil
+				}
+				a, b = blockRsum(ring)
+				continue
+			}
+		}
+
+		next, err := reader.ReadByte()
+		if err != nil {
+			return matches, nil
+		}
+		old := ring[head]
+		ring[head] = next
+		head = (head + 1) % bs
+		a += uint16(next) - uint16(old)
+		b += a - uint16(bs)*uint16(old)
+		offset++
+	}
+}
+
+// ZsyncUpdate refreshes an existing local file using urlStr's .zsync control file, downloading only changed blocks.
+// It returns handled=false when no usable control file exists so the caller can do a full download.
+func (w *WgetClone) ZsyncUpdate(urlStr, localPath string, rateLimit int64) (bool, error) {
+	controlURL, err := url.Parse(urlStr + ".zsync")
+	if err != nil {
+		return false, nil
+	}
+
+	req, err := http.NewRequestWithContext(w.ctx, "GET", controlURL.String(), nil)
+	if err != nil {
+		return false, nil
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return false, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		fmt.Printf("No zsync control file at %s, downloading in full\n", controlURL)
+		return false, nil
+	}
+
+	control, err := parseZsync(data, controlURL)
+	if err != nil {
+		fmt.Printf("Unusable zsync control file (%v), downloading in full\n", err)
+		return false, nil
+	}
+	if control.url == "" {
+		control.url = urlStr
+	}
+
+	fmt.Printf("Starting zsync update of '%s' at %s\n", localPath, time.Now().Format("2006-01-02 15:04:05"))
+	matches, err := control.matchLocalBlocks(localPath)
+	if err != nil {
+		return false, nil
+	}
+
+	blocks := len(control.rsums)
+	fmt.Printf("Reusing %d/%d blocks from the local copy\n", len(matches), blocks)
+
+	partPath := localPath + ".part"
+	out, err := os.Create(partPath)
+	if err != nil {
+		return true, fmt.Errorf("failed to create file '%s': %w", partPath, err)
+	}
+	defer out.Close()
+	if err := preallocate(out, 0, control.length); err != nil {
+		return true, fmt.Errorf("failed to allocate %s for '%s': %w", formatBytes(control.length), partPath, err)
+	}
+	if err := out.Truncate(control.length); err != nil {
+		return true, fmt.Errorf("failed to allocate '%s': %w", partPath, err)
+	}
+
+	// Copy matched blocks from the old file
+	local, err := os.Open(localPath)
+	if err != nil {
+		return true, err
+	}
+	bs := int64(control.blockSize)
+	buf := make([]byte, bs)
+	for id, offset := range matches {
+		n := min(bs, control.length-int64(id)*bs)
+		if _, err := local.ReadAt(buf[:n], offset); err != nil {
+			local.Close()
+			return true, fmt.Errorf("failed to read local block: %w", err)
+		}
+		if _, err := out.WriteAt(buf[:n], int64(id)*bs); err != nil {
+			local.Close()
+			return true, fmt.Errorf("failed to write block: %w", err)
+		}
+	}
+	local.Close()
+
+	// Fetch the remaining blocks as merged ranges
+	var fetched int64
+	for id := 0; id < blocks; {
+		if _, ok := matches[id]; ok {
+			id++
+			continue
+		}
+		start := id
+		for id < blocks {
+			if _, ok := matches[id]; ok {
+				break
+			}
+			id++
+		}
+		from, to := int64(start)*bs, min(int64(id)*bs, control.length)-1
+
+		n, err := w.fetchRangeInto(control.url, out, from, to, rateLimit)
+		fetched += n
+		if err != nil {
+			return true, fmt.Errorf("failed to fetch bytes %d-%d: %w", from, to, err)
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return true, fmt.Errorf("failed to write file '%s': %w", partPath, err)
+	}
+
+	if control.sha1 != "" {
+		if err := verifyFileHash(partPath, ExpectedHash{Type: "sha-1", Value: control.sha1}); err != nil {
+			os.Remove(partPath)
+			fmt.Printf("zsync result failed verification (%v), downloading in full\n", err)
+			return false, nil
+		}
+	}
+
+	if err := os.Rename(partPath, localPath); err != nil {
+		return true, fmt.Errorf("failed to move '%s' into place: %w", partPath, err)
+	}
+
+	fmt.Printf("Updated '%s': fetched %s of %s (%.1f%% saved)\n",
+		filepath.Base(localPath),
+		formatBytes(fetched),
+		formatBytes(control.length),
+		100-float64(fetched)/float64(max(control.length, 1))*100)
+	return true, nil
+}
+
+// fetchRangeInto downloads bytes from-to (inclusive) of urlStr into file at the same offset
+func (w *WgetClone) fetchRangeInto(urlStr string, file *os.File, from, to int64, rateLimit int64) (int64, error) {
+	req, err := http.NewRequestWithContext(w.ctx, "GET", urlStr, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("HTTP %d instead of a partial response", resp.StatusCode)
+	}
+
+	var reader io.Reader = resp.Body
+	reader = NewRateLimitedReader(reader, rateLimit)
+	return io.Copy(io.NewOffsetWriter(file, from), io.LimitReader(reader, to-from+1))
+}